@@ -4,14 +4,22 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"expvar"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"news/pkg/api"
+	"news/pkg/broker"
+	newsgrpc "news/pkg/grpc"
+	"news/pkg/ingest"
+	"news/pkg/pipeline"
 	"news/pkg/rsscollector"
 	"news/pkg/storage"
+	"news/pkg/storage/deadletter"
+	"news/pkg/storage/janitor"
 	"news/pkg/storage/mongo"
+	"news/pkg/storage/outbox"
 	"news/pkg/storage/postgres"
 	"news/pkg/storage/streamwriter"
 	"os"
@@ -31,13 +39,80 @@ var (
 	rsscolName = fmt.Sprintf("%*s", logIndent, "[RSS Collector] ")
 	dwName     = fmt.Sprintf("%*s", logIndent, "[DB Writer] ")
 	apiName    = fmt.Sprintf("%*s", logIndent, "[WEB API] ")
+	janName    = fmt.Sprintf("%*s", logIndent, "[Janitor] ")
+	pipeName   = fmt.Sprintf("%*s", logIndent, "[Pipeline] ")
+	grpcName   = fmt.Sprintf("%*s", logIndent, "[gRPC API] ")
+	ingName    = fmt.Sprintf("%*s", logIndent, "[Ingest] ")
 )
 
 // config - структура для хранения конфигурации
 // передаваемой в качестве аргумента коммандной строки
 type config struct {
-	Links        []string `json:"rss"`            // массив ссылок для опроса
-	SurveyPeriod int      `json:"request_period"` // период опроса ссылок в минутах
+	Links        []string        `json:"rss"`              // массив ссылок для опроса
+	SurveyPeriod int             `json:"request_period"`   // период опроса ссылок в минутах
+	Retention    retentionConfig `json:"retention"`        // политика хранения новостей
+	Outbox       outboxConfig    `json:"outbox"`           // очередь на диске на случай недоступности БД
+	Writer       writerConfig    `json:"writer"`           // настройки пакетной записи в БД
+	DeadLetters  string          `json:"dead_letters"`     // файл для новостей, которые не удаётся записать в БД
+	DeadMax      int             `json:"dead_letters_max"` // сколько таких новостей хранить, по-умолчанию 10000
+	Pipeline     []pipeline.Spec `json:"pipeline"`         // шаги обработки новостей перед записью в БД
+	PipeWorkers  int             `json:"pipeline_workers"` // сколько новостей обрабатывать одновременно
+	GRPCAddr     string          `json:"grpc_addr"`        // адрес gRPC-сервера, по-умолчанию ":9090"
+	WatchBuffer  int             `json:"watch_buffer"`     // сколько последних новостей хранить для продолжения подписки
+}
+
+// writerConfig - настройки пакетной записи новостей в БД,
+// нулевые значения оставляют настройки по-умолчанию
+type writerConfig struct {
+	BatchSize    int `json:"batch_size"`    // сколько новостей записывать в БД за раз
+	BatchLatency int `json:"batch_latency"` // сколько миллисекунд максимум ждать наполнения пачки
+	Flushers     int `json:"flushers"`      // сколько пачек записывать одновременно
+}
+
+// outboxConfig - настройки очереди на диске, в которую
+// откладываются новости, если БД недоступна
+type outboxConfig struct {
+	Dir        string `json:"dir"`         // каталог очереди, если пусто - очередь не используется
+	MaxEntries int    `json:"max_entries"` // максимум контейнеров в очереди
+	MaxMB      int    `json:"max_mb"`      // максимальный размер очереди в мегабайтах
+	Overflow   string `json:"overflow"`    // при переполнении: "drop_oldest" или "reject_new"
+}
+
+// open открывает очередь на диске, если она настроена
+func (oc outboxConfig) open() (*outbox.Outbox, error) {
+	if oc.Dir == "" {
+		return nil, nil
+	}
+
+	policy, err := outbox.ParseOverflow(oc.Overflow)
+	if err != nil {
+		return nil, err
+	}
+
+	ob, err := outbox.Open(oc.Dir, oc.MaxEntries, int64(oc.MaxMB)<<20)
+	if err != nil {
+		return nil, err
+	}
+
+	return ob.Overflow(policy), nil
+}
+
+// retentionConfig - настройки политики хранения новостей,
+// нулевое значение поля означает отсутствие ограничения
+type retentionConfig struct {
+	MaxAgeHours  int `json:"max_age_hours"`  // сколько часов хранить новость
+	MaxPerSource int `json:"max_per_source"` // сколько новостей хранить для каждой rss-ленты
+	MaxTotal     int `json:"max_total"`      // сколько новостей хранить всего
+	Period       int `json:"period"`         // период очистки в минутах
+}
+
+// retention возвращает политику хранения для хранилища
+func (rc retentionConfig) retention() storage.Retention {
+	return storage.Retention{
+		MaxAge:       time.Hour * time.Duration(rc.MaxAgeHours),
+		MaxPerSource: rc.MaxPerSource,
+		MaxTotal:     rc.MaxTotal,
+	}
 }
 
 // readConfig функция для чтения файла конфигурации
@@ -92,10 +167,53 @@ func main() {
 	rsslog := log.New(os.Stdout, rsscolName, log.Lmsgprefix|log.LstdFlags)
 	dbwriterlog := log.New(os.Stdout, dwName, log.Lmsgprefix|log.LstdFlags)
 	apilog := log.New(os.Stdout, apiName, log.Lmsgprefix|log.LstdFlags)
+	janlog := log.New(os.Stdout, janName, log.Lmsgprefix|log.LstdFlags)
+	pipelog := log.New(os.Stdout, pipeName, log.Lmsgprefix|log.LstdFlags)
+	grpclog := log.New(os.Stdout, grpcName, log.Lmsgprefix|log.LstdFlags)
+	inglog := log.New(os.Stdout, ingName, log.Lmsgprefix|log.LstdFlags)
+
+	stages, err := pipeline.Build(config.Pipeline)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
 
 	collector := rsscollector.New(rsslog).DebugMode(true)               // RSS-обходчик
 	sw := streamwriter.NewStreamWriter(dbwriterlog, db).DebugMode(true) // объект пишуший в БД
 	webapi := api.New(db, apilog)                                       // REST API
+	brk := broker.New(config.WatchBuffer)                               // рассылка записанных новостей
+	grpcapi := newsgrpc.New(db, grpclog).Broker(brk)                    // gRPC API
+	pipe := pipeline.New(pipelog, stages...).DebugMode(true)            // обработка новостей
+	ing := ingest.New(inglog, db).Pipeline(pipe).DebugMode(true)        // приём новостей от внешних систем
+
+	pipe.Workers(config.PipeWorkers)
+	sw.Publisher(brk)
+	webapi.Broker(brk)
+	webapi.Ingester(ing)
+	grpcapi.Ingester(ing)
+
+	sw.Batching(config.Writer.BatchSize,
+		time.Millisecond*time.Duration(config.Writer.BatchLatency), config.Writer.Flushers)
+
+	ob, err := config.Outbox.open()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if ob != nil {
+		sw.Outbox(ob)
+	}
+
+	// если файл не задан, хранилище работает в памяти
+	dl, err := deadletter.Open(config.DeadLetters)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	// повторно записанные новости получают подписчики, как и новые
+	dl.Max(config.DeadMax).Publisher(brk)
+	sw.DeadLetters(dl)
+	webapi.DeadLetters(dl)
 
 	// конфигурируем сервер
 	srv := &http.Server{
@@ -105,6 +223,49 @@ func main() {
 		ReadHeaderTimeout: time.Minute,
 	}
 
+	grpcAddr := config.GRPCAddr
+	if grpcAddr == "" {
+		grpcAddr = ":9090"
+	}
+	grpcLis, err := net.Listen("tcp", grpcAddr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	// метрики gRPC-сервера доступны в /debug/vars
+	grpcMetrics := newsgrpc.NewMetrics()
+	expvar.Publish("grpc", grpcMetrics)
+
+	// API-токены через запятую. Без токенов запросы gRPC на чтение не
+	// проверяются, а приём новостей в REST и gRPC и Watch отклоняются
+	tokens := os.Getenv("NEWS_API_TOKENS")
+	if strings.TrimSpace(tokens) == "" {
+		grpclog.Println("[INFO] $NEWS_API_TOKENS is not set, read requests are not checked, ingest and watch rejected")
+	}
+	webapi.Tokens(strings.Split(tokens, ",")...)
+
+	// токены администратора через запятую, без них методы /admin/...
+	// и gRPC CreateItem, UpdateItem и DeleteItem отклоняются
+	adminTokens := os.Getenv("NEWS_ADMIN_TOKENS")
+	if strings.TrimSpace(adminTokens) == "" {
+		apilog.Println("[INFO] $NEWS_ADMIN_TOKENS is not set, admin endpoints rejected")
+	}
+	webapi.AdminTokens(strings.Split(adminTokens, ",")...)
+	interceptors := newsgrpc.NewInterceptors(grpclog).
+		Tokens(strings.Split(tokens, ",")...).
+		AdminTokens(strings.Split(adminTokens, ",")...).
+		Metrics(grpcMetrics)
+	grpcSrv := newsgrpc.NewServer(grpcapi, interceptors.ServerOptions()...)
+
+	// REST API /api/v2 - шлюз к gRPC-серверу
+	gw, err := newsgrpc.NewGateway(context.Background(), grpcLis.Addr().String())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	defer gw.Close()
+	webapi.V2(gw)
+
 	// создаем контекст для регулирования закрытия всех подсистем
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -117,7 +278,24 @@ func main() {
 	}
 
 	var wg sync.WaitGroup
-	wg.Add(3)
+	wg.Add(4)
+
+	// удаляем устаревшие новости, если хранилище это умеет
+	if pruner, ok := db.(storage.Pruner); ok {
+		// метрики удаления доступны в /debug/vars
+		janMetrics := janitor.NewMetrics()
+		expvar.Publish("janitor", janMetrics)
+		jan := janitor.New(janlog, pruner, config.Retention.retention()).Metrics(janMetrics)
+		if config.Retention.Period > 0 {
+			jan.Interval(time.Minute * time.Duration(config.Retention.Period))
+		}
+
+		wg.Add(1)
+		go func() {
+			jan.Run(ctx)
+			wg.Done()
+		}()
+	}
 
 	// читаем канал с ошибками
 	go func() {
@@ -125,35 +303,70 @@ func main() {
 		wg.Done()
 	}()
 
-	// читаем канал с новостями и пишем в БД
+	// обрабатываем новости, добавляем принятые от внешних
+	// систем, читаем канал с результатом и пишем в БД
 	go func() {
-		_, err = sw.WriteToStorage(ctx, values)
+		_, err = sw.WriteToStorage(ctx, ing.Join(pipe.Run(ctx, values)))
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
+			cancel() // писать некуда, останавливаем опрос rss-лент
 		}
 		wg.Done()
 	}()
 
-	// сервер
+	// сервер. Ошибка сервера, как и ошибки записи в БД,
+	// закрывает контекст приложения и останавливает его так же,
+	// как сигнал прерывания
 	go func() {
 		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
-			log.Fatal(err)
+			log.Println(err)
+			cancel()
 		} else {
 			log.Println(err) // server closed
 		}
 		wg.Done()
 	}()
 
+	// gRPC-сервер
+	go func() {
+		grpclog.Printf("[INFO] listening on %s", grpcLis.Addr())
+		if err := grpcSrv.Serve(grpcLis); err != nil {
+			grpclog.Printf("[ERROR] serve error=%v", err)
+			cancel()
+		}
+		grpclog.Println("[INFO] server stopped")
+		wg.Done()
+	}()
+
 	// ловим сигналы прерывания типа CTRL-C
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGHUP, syscall.SIGTERM, syscall.SIGQUIT)
 	go func() {
-		s := <-stop // получили сигнал прерывания
-		log.Println("got os signal", s)
+		select {
+		case s := <-stop: // получили сигнал прерывания
+			log.Println("got os signal", s)
+		case <-ctx.Done(): // ошибка сервера или БД
+			log.Println("shutting down after server or storage error")
+		}
+
+		// отключаем подписчиков, иначе потоки Watch, в том
+		// числе через /api/v2, и /news/stream не дадут
+		// серверам остановиться
+		brk.Close()
+
+		// контекст приложения может быть уже закрыт,
+		// поэтому серверам даётся отдельное время
+		sctx, scancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer scancel()
 
 		// закрываем сервер
-		if err := srv.Shutdown(ctx); err != nil {
-			log.Fatal(err)
+		if err := srv.Shutdown(sctx); err != nil {
+			log.Println(err)
+		}
+
+		// даём gRPC-клиентам завершить запросы
+		if err := grpcSrv.Shutdown(sctx); err != nil {
+			grpclog.Printf("[ERROR] forced stop: %v", err)
 		}
 
 		cancel() // закрываем контекст приложения
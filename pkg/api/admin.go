@@ -0,0 +1,131 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"news/pkg/storage/deadletter"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// replayResult - итог повторной записи всех новостей из хранилища dead-letter
+type replayResult struct {
+	Replayed int `json:"replayed"` // записано в БД
+	Failed   int `json:"failed"`   // осталось в хранилище
+}
+
+// deadLetterStore возвращает хранилище dead-letter или отвечает
+// ошибкой, если хранилище не подключено
+func (api *Api) deadLetterStore(w http.ResponseWriter, r *http.Request) (*deadletter.Store, bool) {
+	if api.dead == nil {
+		api.problem(w, r, http.StatusNotFound, "dead-letter store is not configured")
+		return nil, false
+	}
+	return api.dead, true
+}
+
+// deadLettersHandler возвращает все новости из хранилища dead-letter
+func (api *Api) deadLettersHandler(w http.ResponseWriter, r *http.Request) {
+
+	api.logger.Printf("[DEBUG] method=%s, path=%s, host=%s", r.Method, r.URL.Path, r.Host)
+
+	dl, ok := api.deadLetterStore(w, r)
+	if !ok {
+		return
+	}
+
+	api.respond(w, r, http.StatusOK, dl.List(), nil)
+}
+
+// replayDeadLettersHandler повторно записывает в БД
+// все новости из хранилища dead-letter
+func (api *Api) replayDeadLettersHandler(w http.ResponseWriter, r *http.Request) {
+
+	api.logger.Printf("[DEBUG] method=%s, path=%s, host=%s", r.Method, r.URL.Path, r.Host)
+
+	dl, ok := api.deadLetterStore(w, r)
+	if !ok {
+		return
+	}
+
+	var res replayResult
+
+	for _, l := range dl.List() {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		err := dl.Replay(ctx, api.db, l.ID)
+		cancel()
+
+		switch {
+		case err == nil:
+			res.Replayed++
+		case errors.Is(err, deadletter.ErrNotFound):
+			// запись удалена другим запросом
+		default:
+			res.Failed++
+		}
+	}
+
+	api.respond(w, r, http.StatusOK, res, nil)
+}
+
+// replayDeadLetterHandler повторно записывает в БД
+// новость {id} из хранилища dead-letter
+func (api *Api) replayDeadLetterHandler(w http.ResponseWriter, r *http.Request) {
+
+	api.logger.Printf("[DEBUG] method=%s, path=%s, host=%s", r.Method, r.URL.Path, r.Host)
+
+	dl, ok := api.deadLetterStore(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		api.problem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	err = dl.Replay(ctx, api.db, id)
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, deadletter.ErrNotFound):
+		api.problem(w, r, http.StatusNotFound, err.Error())
+	default:
+		// новость по-прежнему не записывается
+		api.problem(w, r, http.StatusConflict, err.Error())
+	}
+}
+
+// deleteDeadLetterHandler удаляет новость {id} из хранилища dead-letter
+func (api *Api) deleteDeadLetterHandler(w http.ResponseWriter, r *http.Request) {
+
+	api.logger.Printf("[DEBUG] method=%s, path=%s, host=%s", r.Method, r.URL.Path, r.Host)
+
+	dl, ok := api.deadLetterStore(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		api.problem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = dl.Delete(id)
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, deadletter.ErrNotFound):
+		api.problem(w, r, http.StatusNotFound, err.Error())
+	default:
+		api.problem(w, r, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"news/pkg/storage/deadletter"
+	"news/pkg/storage/memdb"
+	"reflect"
+	"testing"
+)
+
+func TestApi_deadLetters(t *testing.T) {
+
+	dl, err := deadletter.Open("")
+	if err != nil {
+		t.Fatalf("deadletter.Open() error = %v", err)
+	}
+
+	api := New(memdb.New(), log.New(io.Discard, "", 0)).DeadLetters(dl).AdminTokens("admin")
+
+	l1, _ := dl.Put(memdb.SampleItem, errors.New("db error"))
+	l2, _ := dl.Put(memdb.SampleItem, errors.New("db error"))
+	_, _ = dl.Put(memdb.SampleItem, errors.New("db error"))
+
+	serve := func(method, path string) *http.Response {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer admin")
+		api.r.ServeHTTP(rr, req)
+		return rr.Result()
+	}
+
+	t.Run("список", func(t *testing.T) {
+		resp := serve(http.MethodGet, "/admin/deadletters")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Api.deadLettersHandler() got response code = %d, want = %d", resp.StatusCode, http.StatusOK)
+		}
+
+		var letters []deadletter.Letter
+		if err := json.NewDecoder(resp.Body).Decode(&letters); err != nil {
+			t.Fatalf("Api.deadLettersHandler() got error = %v", err)
+		}
+
+		if len(letters) != 3 || !reflect.DeepEqual(letters[0], l1) {
+			t.Fatalf("Api.deadLettersHandler() got = %v, want %d letters", letters, 3)
+		}
+	})
+
+	t.Run("удаление", func(t *testing.T) {
+		resp := serve(http.MethodDelete, fmt.Sprintf("/admin/deadletters/%d", l1.ID))
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("Api.deleteDeadLetterHandler() got response code = %d, want = %d", resp.StatusCode, http.StatusNoContent)
+		}
+
+		resp = serve(http.MethodDelete, fmt.Sprintf("/admin/deadletters/%d", l1.ID))
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("Api.deleteDeadLetterHandler() got response code = %d, want = %d", resp.StatusCode, http.StatusNotFound)
+		}
+	})
+
+	t.Run("повторная_запись_одной", func(t *testing.T) {
+		resp := serve(http.MethodPost, fmt.Sprintf("/admin/deadletters/%d/replay", l2.ID))
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("Api.replayDeadLetterHandler() got response code = %d, want = %d", resp.StatusCode, http.StatusNoContent)
+		}
+
+		if dl.Len() != 1 {
+			t.Fatalf("Api.replayDeadLetterHandler() got letters = %d, want = %d", dl.Len(), 1)
+		}
+	})
+
+	t.Run("повторная_запись_всех", func(t *testing.T) {
+		resp := serve(http.MethodPost, "/admin/deadletters/replay")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Api.replayDeadLettersHandler() got response code = %d, want = %d", resp.StatusCode, http.StatusOK)
+		}
+
+		var got replayResult
+		if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+			t.Fatalf("Api.replayDeadLettersHandler() got error = %v", err)
+		}
+
+		if want := (replayResult{Replayed: 1}); got != want || dl.Len() != 0 {
+			t.Fatalf("Api.replayDeadLettersHandler() got = %v, want = %v", got, want)
+		}
+	})
+
+	t.Run("хранилище_не_подключено", func(t *testing.T) {
+		api := New(memdb.New(), log.New(io.Discard, "", 0)).AdminTokens("admin")
+		req := httptest.NewRequest(http.MethodGet, "/admin/deadletters", nil)
+		req.Header.Set("Authorization", "Bearer admin")
+		rr := httptest.NewRecorder()
+		api.r.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusNotFound {
+			t.Fatalf("Api.deadLettersHandler() got response code = %d, want = %d", rr.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("без_токена", func(t *testing.T) {
+		for _, path := range []string{"/admin/deadletters", "/api/v1/admin/deadletters"} {
+			rr := httptest.NewRecorder()
+			api.r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+			if rr.Code != http.StatusUnauthorized {
+				t.Errorf("Api.deadLettersHandler() %s got response code = %d, want = %d", path, rr.Code, http.StatusUnauthorized)
+			}
+		}
+	})
+}
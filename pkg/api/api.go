@@ -2,11 +2,20 @@ package api
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"net/url"
+	"news/pkg/broker"
+	"news/pkg/ingest"
+	"news/pkg/langdetect"
 	"news/pkg/storage"
+	"news/pkg/storage/deadletter"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -19,6 +28,13 @@ type item = storage.Item
 type Api struct {
 	r         *mux.Router
 	db        stor
+	dead      *deadletter.Store // хранилище dead-letter, по-умолчанию nil
+	ingester  *ingest.Ingester  // приём новостей от внешних систем, по-умолчанию nil
+	v2        http.Handler      // обработчик /api/v2, по-умолчанию nil
+	broker    *broker.Broker    // рассылка записанных новостей для /news/stream, по-умолчанию nil
+	heartbeat time.Duration     // период комментария в потоке /news/stream
+	tokens    [][]byte          // API-токены методов, которые изменяют новости
+	admin     [][]byte          // токены методов администрирования
 	logger    *log.Logger
 	debugMode bool
 }
@@ -28,6 +44,7 @@ func New(storage stor, logger *log.Logger) *Api {
 	api := Api{
 		r:         mux.NewRouter(),
 		db:        storage,
+		heartbeat: heartbeat,
 		logger:    logger,
 		debugMode: false,
 	}
@@ -41,19 +58,99 @@ func (api *Api) DebugMode(mode bool) *Api {
 	return api
 }
 
+// DeadLetters подключает к *Api хранилище dead-letter,
+// с которым работают методы /admin/deadletters
+func (api *Api) DeadLetters(dl *deadletter.Store) *Api {
+	api.dead = dl
+	return api
+}
+
+// Ingester подключает к *Api приём новостей,
+// с которым работает метод POST /news
+func (api *Api) Ingester(in *ingest.Ingester) *Api {
+	api.ingester = in
+	return api
+}
+
+// V2 подключает к *Api обработчик запросов /api/v2,
+// например шлюз к gRPC-сервису
+func (api *Api) V2(h http.Handler) *Api {
+	api.v2 = h
+	return api
+}
+
+// Broker подключает к *Api рассылку записанных новостей,
+// из которой метод /news/stream отправляет новости клиентам
+func (api *Api) Broker(b *broker.Broker) *Api {
+	api.broker = b
+	return api
+}
+
+// Heartbeat устанавливает, как часто в поток /news/stream
+// отправляется комментарий, чтобы прокси не закрывали
+// соединение без новостей. Не положительное d игнорируется
+func (api *Api) Heartbeat(d time.Duration) *Api {
+	if d > 0 {
+		api.heartbeat = d
+	}
+	return api
+}
+
 // Router возвращает маршрутизатор запросов.
 func (api *Api) Router() *mux.Router {
 	return api.r
 }
 
+// ограничения на количество новостей в ответе
+const (
+	defaultLimit = 20
+	maxLimit     = 1000
+)
+
 func (api *Api) endpoints() {
 	api.r.Use(api.headersMiddleware)
-	// получить n последних новостей
-	api.r.HandleFunc("/news/{n}", api.itemsHandler).Methods(http.MethodGet, http.MethodOptions)
+	// новости, записанные в БД, как Server-Sent Events.
+	// Регистрируется до /news/{n}, иначе совпадёт с ним
+	api.r.HandleFunc("/news/stream", api.streamHandler).Methods(http.MethodGet)
+	api.routes(api.r)
+
+	// версия API: ошибки и ответы в общем формате
+	v1 := api.r.PathPrefix("/api/v1").Subrouter()
+	v1.Use(api.v1Middleware)
+	v1.NotFoundHandler = http.HandlerFunc(api.notFoundHandler)
+	v1.MethodNotAllowedHandler = http.HandlerFunc(api.methodNotAllowedHandler)
+	// страница новостей: ?limit=&offset=&lang=&source=&q=&category=
+	v1.HandleFunc("/news", api.listHandler).Methods(http.MethodGet, http.MethodOptions)
+	api.routes(v1)
+
+	// последние новости в виде ленты RSS, Atom или JSON Feed
+	api.r.HandleFunc("/feed.{format:rss|atom|json}", api.feedHandler).Methods(http.MethodGet)
+
+	// REST API, которое обслуживает шлюз к gRPC-сервису
+	api.r.PathPrefix("/api/v2/").HandlerFunc(api.v2Handler)
+	// метрики приложения, см. пакет expvar. В них есть командная
+	// строка и сведения о памяти, поэтому только с токеном администратора
+	api.r.Handle("/debug/vars", api.authorize(&api.admin, expvar.Handler())).Methods(http.MethodGet)
+	// описание API в формате OpenAPI 3 и страница с ним
+	api.r.HandleFunc("/openapi.json", api.openapiHandler).Methods(http.MethodGet)
+	api.r.HandleFunc("/docs", api.docsHandler).Methods(http.MethodGet)
 	// веб-приложение
 	api.r.PathPrefix("/").Handler(http.StripPrefix("/", http.FileServer(http.Dir("./webapp"))))
 }
 
+// routes регистрирует методы, общие для / и /api/v1
+func (api *Api) routes(r *mux.Router) {
+	// получить n последних новостей, ?lang= - только на одном языке
+	r.HandleFunc("/news/{n}", api.itemsHandler).Methods(http.MethodGet, http.MethodOptions)
+	// принять новости от внешней системы, только с API-токеном
+	r.Handle("/news", api.authorize(&api.tokens, http.HandlerFunc(api.ingestHandler))).Methods(http.MethodPost, http.MethodOptions)
+	// новости, которые не удалось записать в БД, только с токеном администратора
+	r.Handle("/admin/deadletters", api.authorize(&api.admin, http.HandlerFunc(api.deadLettersHandler))).Methods(http.MethodGet, http.MethodOptions)
+	r.Handle("/admin/deadletters/replay", api.authorize(&api.admin, http.HandlerFunc(api.replayDeadLettersHandler))).Methods(http.MethodPost, http.MethodOptions)
+	r.Handle("/admin/deadletters/{id:[0-9]+}/replay", api.authorize(&api.admin, http.HandlerFunc(api.replayDeadLetterHandler))).Methods(http.MethodPost, http.MethodOptions)
+	r.Handle("/admin/deadletters/{id:[0-9]+}", api.authorize(&api.admin, http.HandlerFunc(api.deleteDeadLetterHandler))).Methods(http.MethodDelete, http.MethodOptions)
+}
+
 func (api *Api) headersMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodOptions {
@@ -65,27 +162,173 @@ func (api *Api) headersMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// itemsHandler возвращает все новости
+// itemsHandler возвращает n последних новостей
 func (api *Api) itemsHandler(w http.ResponseWriter, r *http.Request) {
 
 	api.logger.Printf("[DEBUG] method=%s, path=%s, host=%s", r.Method, r.URL.Path, r.Host)
 
 	// Считывание параметра запроса {n} из пути запроса.
-	s := mux.Vars(r)["n"]
-	limit, err := strconv.Atoi(s)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	var errs []fieldError
+	limit := intParam("n", mux.Vars(r)["n"], 0, 1, maxLimit, &errs)
+
+	// необязательный фильтр по языку: /news/10?lang=ru
+	f := storage.Filter{Lang: langParam(r.URL.Query().Get("lang"), &errs)}
+
+	if len(errs) > 0 {
+		api.problem(w, r, http.StatusBadRequest, "invalid request parameters", errs...)
+		return
+	}
+
+	api.page(w, r, limit, f)
+}
+
+// listHandler возвращает страницу новостей, подходящих под условия
+func (api *Api) listHandler(w http.ResponseWriter, r *http.Request) {
+
+	api.logger.Printf("[DEBUG] method=%s, path=%s, host=%s", r.Method, r.URL.Path, r.Host)
+
+	q := r.URL.Query()
+	var errs []fieldError
+	limit := intParam("limit", q.Get("limit"), defaultLimit, 1, maxLimit, &errs)
+	f := filterParams(q, &errs)
+	f.Offset = intParam("offset", q.Get("offset"), 0, 0, math.MaxInt32, &errs)
+
+	if len(errs) > 0 {
+		api.problem(w, r, http.StatusBadRequest, "invalid request parameters", errs...)
+		return
+	}
+
+	api.page(w, r, limit, f)
+}
+
+// page отвечает не больше чем limit новостями, подходящими под f
+func (api *Api) page(w http.ResponseWriter, r *http.Request, limit int, f storage.Filter) {
+
+	items, ok := api.find(w, r, limit, f)
+	if !ok {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	m := &meta{Limit: limit, Offset: f.Offset, Count: len(items)}
+	if len(items) == limit {
+		m.Next = nextPage(limit, f)
+	}
+
+	api.respond(w, r, http.StatusOK, items, m)
+}
+
+// find возвращает не больше чем limit новостей, подходящих под f,
+// или отвечает ошибкой, если выбрать новости не удалось
+func (api *Api) find(w http.ResponseWriter, r *http.Request, limit int, f storage.Filter) ([]item, bool) {
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
-	items, err := api.db.Items(ctx, limit)
+
+	var items []item
+	var err error
+
+	if f == (storage.Filter{}) {
+		items, err = api.db.Items(ctx, limit)
+	} else {
+		db, ok := api.db.(storage.Filterer)
+		if !ok {
+			api.problem(w, r, http.StatusNotImplemented, "filtering is not supported by storage")
+			return nil, false
+		}
+		items, err = db.FilterItems(ctx, limit, f)
+	}
+	switch {
+	case errors.Is(err, storage.ErrInvalidLimit):
+		api.problem(w, r, http.StatusBadRequest, err.Error())
+		return nil, false
+	case errors.Is(err, context.DeadlineExceeded):
+		api.problem(w, r, http.StatusGatewayTimeout, "storage timeout")
+		return nil, false
+	case err != nil:
+		api.problem(w, r, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	if items == nil {
+		items = []item{}
+	}
+
+	return items, true
+}
+
+// nextPage возвращает адрес страницы после страницы limit, f
+func nextPage(limit int, f storage.Filter) string {
+	q := url.Values{}
+	q.Set("limit", strconv.Itoa(limit))
+	q.Set("offset", strconv.Itoa(f.Offset+limit))
+	if f.Lang != "" {
+		q.Set("lang", f.Lang)
+	}
+	if f.Source != "" {
+		q.Set("source", f.Source)
+	}
+	if f.Query != "" {
+		q.Set("q", f.Query)
+	}
+	if f.Category != "" {
+		q.Set("category", f.Category)
+	}
+	return "/api/v1/news?" + q.Encode()
+}
+
+// filterParams разбирает условия выборки новостей
+// ?lang=&source=&q=&category=. Ошибки добавляются в errs
+func filterParams(q url.Values, errs *[]fieldError) storage.Filter {
+	return storage.Filter{
+		Lang:     langParam(q.Get("lang"), errs),
+		Source:   strings.TrimSpace(q.Get("source")),
+		Query:    strings.TrimSpace(q.Get("q")),
+		Category: strings.TrimSpace(q.Get("category")),
+	}
+}
+
+// intParam разбирает целый параметр запроса name из s в пределах
+// [min, max]. Если s пустая, возвращает def. Ошибка добавляется в errs
+func intParam(name, s string, def, min, max int, errs *[]fieldError) int {
+	if s == "" {
+		return def
+	}
+	n, err := strconv.Atoi(s)
 	if err != nil {
-		http.Error(w, "internal server error", http.StatusInternalServerError)
+		*errs = append(*errs, fieldError{Param: name, Message: "must be an integer"})
+		return def
+	}
+	if n < min || n > max {
+		*errs = append(*errs, fieldError{Param: name, Message: fmt.Sprintf("must be between %d and %d", min, max)})
+		return def
+	}
+	return n
+}
+
+// langParam проверяет код языка, пустой код допустим
+func langParam(s string, errs *[]fieldError) string {
+	if s == "" {
+		return ""
+	}
+	lang := langdetect.Normalize(s)
+	if lang == "" {
+		*errs = append(*errs, fieldError{Param: "lang", Message: "must be an ISO 639-1 language code"})
+	}
+	return lang
+}
+
+// v2Handler передаёт запрос обработчику /api/v2
+func (api *Api) v2Handler(w http.ResponseWriter, r *http.Request) {
+
+	api.logger.Printf("[DEBUG] method=%s, path=%s, host=%s", r.Method, r.URL.Path, r.Host)
+
+	if api.v2 == nil {
+		api.problem(w, r, http.StatusNotImplemented, "api v2 is not enabled")
 		return
 	}
-	if err := json.NewEncoder(w).Encode(items); err != nil {
-		http.Error(w, "internal server error", http.StatusInternalServerError)
+	// методы, которые изменяют новости, как и POST /news,
+	// не разрешаются страницам с других сайтов
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Del("Access-Control-Allow-Origin")
 	}
+	api.v2.ServeHTTP(w, r)
 }
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"news/pkg/storage/memdb"
+	"reflect"
 	"testing"
 )
 
@@ -35,8 +36,91 @@ func TestApi_itemsHandler(t *testing.T) {
 	}
 
 	if len(items) > 0 {
-		if items[0] != memdb.SampleItem {
+		if !reflect.DeepEqual(items[0], memdb.SampleItem) {
 			t.Errorf("Api.itemsHandler() got items[0] = %v, want = %v", items[0], memdb.SampleItem)
 		}
 	}
 }
+
+func TestApi_itemsHandler_negative(t *testing.T) {
+	api := New(memdb.New(), log.New(io.Discard, "", 0))
+
+	rr := httptest.NewRecorder()
+	api.r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/news/-1", nil))
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Api.itemsHandler() got response code = %d, want = %d", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestApi_itemsHandler_lang(t *testing.T) {
+	api := New(memdb.New(), log.New(io.Discard, "", 0))
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		wantLen  int
+	}{
+		{"язык совпадает", "?lang=en-US", http.StatusOK, 5},
+		{"язык не совпадает", "?lang=ru", http.StatusOK, 0},
+		{"некорректный язык", "?lang=%D1%80%D1%83", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/news/5"+tt.query, nil)
+			rr := httptest.NewRecorder()
+
+			api.r.ServeHTTP(rr, req)
+
+			resp := rr.Result()
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("Api.itemsHandler() got response code = %d, want = %d", resp.StatusCode, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var items []item
+			if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+				t.Fatalf("Api.itemsHandler() got error = %v", err)
+			}
+			if len(items) != tt.wantLen {
+				t.Errorf("Api.itemsHandler() got items = %d, want = %d", len(items), tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestApi_v2Handler(t *testing.T) {
+	api := New(memdb.New(), log.New(io.Discard, "", 0))
+
+	rr := httptest.NewRecorder()
+	api.r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v2/news", nil))
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("Api.v2Handler() got response code = %d, want = %d", rr.Code, http.StatusNotImplemented)
+	}
+
+	api.V2(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	rr = httptest.NewRecorder()
+	api.r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v2/news", nil))
+	if rr.Code != http.StatusTeapot {
+		t.Errorf("Api.v2Handler() got response code = %d, want = %d", rr.Code, http.StatusTeapot)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Api.v2Handler() GET got Access-Control-Allow-Origin = %q, want = %q", got, "*")
+	}
+
+	// изменения новостей другим сайтам не разрешаются
+	for _, method := range []string{http.MethodPost, http.MethodPatch, http.MethodDelete} {
+		rr = httptest.NewRecorder()
+		api.r.ServeHTTP(rr, httptest.NewRequest(method, "/api/v2/news", nil))
+		if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("Api.v2Handler() %s got Access-Control-Allow-Origin = %q, want = %q", method, got, "")
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// Tokens устанавливает API-токены методов, которые изменяют
+// новости. Токен передаётся в заголовке "Authorization: Bearer
+// <токен>". Пустые строки пропускаются, без токенов такие
+// методы отклоняются
+func (api *Api) Tokens(tokens ...string) *Api {
+	api.tokens = appendTokens(api.tokens, tokens)
+	return api
+}
+
+// AdminTokens устанавливает токены методов администрирования
+// /admin/..., они передаются так же, как API-токены. Без
+// токенов методы администрирования отклоняются
+func (api *Api) AdminTokens(tokens ...string) *Api {
+	api.admin = appendTokens(api.admin, tokens)
+	return api
+}
+
+func appendTokens(list [][]byte, tokens []string) [][]byte {
+	for _, t := range tokens {
+		if t = strings.TrimSpace(t); t != "" {
+			list = append(list, []byte(t))
+		}
+	}
+	return list
+}
+
+// authorize пропускает к next только запросы с одним из токенов
+// *tokens. Токены читаются при каждом запросе, поэтому их можно
+// установить после регистрации маршрутов. Ответы этих методов
+// не разрешаются страницам с других сайтов
+func (api *Api) authorize(tokens *[][]byte, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Del("Access-Control-Allow-Origin")
+
+		if len(*tokens) == 0 {
+			api.problem(w, r, http.StatusForbidden, "api tokens are not configured")
+			return
+		}
+
+		token := []byte(strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")))
+		for _, t := range *tokens {
+			if subtle.ConstantTimeCompare(token, t) == 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		w.Header().Set("WWW-Authenticate", `Bearer realm="news"`)
+		api.problem(w, r, http.StatusUnauthorized, "invalid or missing api token")
+	})
+}
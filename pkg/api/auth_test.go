@@ -0,0 +1,84 @@
+package api
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"news/pkg/ingest"
+	"news/pkg/storage/memdb"
+	"strings"
+	"testing"
+)
+
+func TestApi_authorize(t *testing.T) {
+
+	logger := log.New(io.Discard, "", 0)
+	in := ingest.New(logger, memdb.New())
+
+	tests := []struct {
+		name     string
+		api      *Api
+		path     string
+		header   string
+		wantCode int
+	}{
+		{"токен верный", New(memdb.New(), logger).Ingester(in).Tokens("a", " b "), "/news", "Bearer b", http.StatusOK},
+		{"токен верный /api/v1", New(memdb.New(), logger).Ingester(in).Tokens("a"), "/api/v1/news", "Bearer a", http.StatusOK},
+		{"токен неверный", New(memdb.New(), logger).Ingester(in).Tokens("a"), "/news", "Bearer b", http.StatusUnauthorized},
+		{"без токена", New(memdb.New(), logger).Ingester(in).Tokens("a"), "/api/v1/news", "", http.StatusUnauthorized},
+		{"токены не настроены", New(memdb.New(), logger).Ingester(in).Tokens("", " "), "/news", "Bearer ", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(`[]`))
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rr := httptest.NewRecorder()
+			tt.api.r.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantCode {
+				t.Errorf("Api.authorize() got response code = %d, want = %d", rr.Code, tt.wantCode)
+			}
+			if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "" {
+				t.Errorf("Api.authorize() got Access-Control-Allow-Origin = %q, want none", got)
+			}
+			if rr.Code == http.StatusUnauthorized && rr.Header().Get("WWW-Authenticate") == "" {
+				t.Errorf("Api.authorize() got no WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestApi_debugVars(t *testing.T) {
+
+	api := New(memdb.New(), log.New(io.Discard, "", 0)).AdminTokens("admin")
+
+	tests := []struct {
+		name     string
+		header   string
+		wantCode int
+	}{
+		{"токен администратора", "Bearer admin", http.StatusOK},
+		{"без токена", "", http.StatusUnauthorized},
+		{"чужой токен", "Bearer token", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/debug/vars", nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		rr := httptest.NewRecorder()
+		api.r.ServeHTTP(rr, req)
+
+		if rr.Code != tt.wantCode {
+			t.Errorf("GET /debug/vars %s got response code = %d, want = %d", tt.name, rr.Code, tt.wantCode)
+		}
+		if tt.wantCode == http.StatusOK && !strings.Contains(rr.Body.String(), `"memstats"`) {
+			t.Errorf("GET /debug/vars %s got body without memstats", tt.name)
+		}
+	}
+}
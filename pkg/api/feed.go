@@ -0,0 +1,361 @@
+package api
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"news/pkg/storage"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// feedTitle - заголовок ленты агрегатора
+const feedTitle = "Новости"
+
+// feed - лента последних новостей агрегатора, которая
+// отдаётся в форматах RSS 2.0, Atom 1.0 и JSON Feed 1.1
+type feed struct {
+	title   string
+	home    string    // адрес веб-приложения
+	self    string    // адрес самой ленты
+	lang    string    // язык ленты, если выбраны новости на одном языке
+	updated time.Time // дата публикации самой свежей новости
+	items   []item
+}
+
+// feedHandler возвращает последние новости в виде ленты
+// в формате {format}: rss, atom или json. Новости выбираются
+// по тем же условиям, что и страница /api/v1/news
+func (api *Api) feedHandler(w http.ResponseWriter, r *http.Request) {
+
+	api.logger.Printf("[DEBUG] method=%s, path=%s, host=%s", r.Method, r.URL.Path, r.Host)
+
+	q := r.URL.Query()
+	var errs []fieldError
+	limit := intParam("limit", q.Get("limit"), defaultLimit, 1, maxLimit, &errs)
+	f := filterParams(q, &errs)
+
+	if len(errs) > 0 {
+		api.problem(w, r, http.StatusBadRequest, "invalid request parameters", errs...)
+		return
+	}
+
+	items, ok := api.find(w, r, limit, f)
+	if !ok {
+		return
+	}
+
+	fd := newFeed(r, f, items)
+	w.Header().Set("Last-Modified", fd.updated.UTC().Format(http.TimeFormat))
+
+	var err error
+	switch mux.Vars(r)["format"] {
+	case "rss":
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		err = writeXML(w, fd.rss())
+	case "atom":
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		err = writeXML(w, fd.atom())
+	case "json":
+		w.Header().Set("Content-Type", "application/feed+json; charset=utf-8")
+		err = json.NewEncoder(w).Encode(fd.json())
+	}
+	if err != nil {
+		api.logger.Printf("[ERROR] method=%s, path=%s, error=%v", r.Method, r.URL.Path, err)
+	}
+}
+
+// newFeed возвращает ленту из новостей items, выбранных по
+// условиям f. Лента по одной rss-ленте или категории
+// называется по ним
+func newFeed(r *http.Request, f storage.Filter, items []item) feed {
+	base := baseURL(r)
+	fd := feed{
+		title: feedTitle,
+		home:  base + "/",
+		self:  base + r.URL.RequestURI(),
+		lang:  f.Lang,
+		items: items,
+	}
+
+	var parts []string
+	if f.Category != "" {
+		parts = append(parts, f.Category)
+	}
+	if f.Source != "" {
+		parts = append(parts, f.Source)
+	}
+	if f.Query != "" {
+		parts = append(parts, "«"+f.Query+"»")
+	}
+	if len(parts) > 0 {
+		fd.title += ": " + strings.Join(parts, ", ")
+	}
+
+	for _, it := range items {
+		if t := time.Unix(it.PubDate, 0); it.PubDate > 0 && t.After(fd.updated) {
+			fd.updated = t
+		}
+	}
+	if fd.updated.IsZero() {
+		fd.updated = time.Now()
+	}
+
+	return fd
+}
+
+// baseURL возвращает схему и адрес сервера, на который
+// пришёл запрос, с учётом обратного прокси
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if p := r.Header.Get("X-Forwarded-Proto"); p != "" {
+		scheme = strings.TrimSpace(strings.Split(p, ",")[0])
+	}
+	return scheme + "://" + r.Host
+}
+
+func writeXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(v)
+}
+
+// описание новости в html, если есть, иначе простым текстом
+func itemHTML(it item) string {
+	if it.HTML != "" {
+		return it.HTML
+	}
+	return it.Description
+}
+
+// RSS 2.0, https://www.rssboard.org/rss-specification
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Language      string    `xml:"language,omitempty"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Self          atomLink  `xml:"atom:link"` // адрес ленты, рекомендация валидатора W3C
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string     `xml:"title"`
+	Link        string     `xml:"link"`
+	Description string     `xml:"description,omitempty"`
+	GUID        rssGUID    `xml:"guid"`
+	PubDate     string     `xml:"pubDate,omitempty"`
+	Categories  []string   `xml:"category"`
+	Source      *rssSource `xml:"source,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssSource struct {
+	URL   string `xml:"url,attr"`
+	Value string `xml:",chardata"`
+}
+
+func (fd feed) rss() rssFeed {
+	ch := rssChannel{
+		Title:         fd.title,
+		Link:          fd.home,
+		Description:   "Последние новости агрегатора",
+		Language:      fd.lang,
+		LastBuildDate: fd.updated.UTC().Format(time.RFC1123Z),
+		Self:          atomLink{Href: fd.self, Rel: "self", Type: "application/rss+xml"},
+		Items:         make([]rssItem, 0, len(fd.items)),
+	}
+
+	for _, it := range fd.items {
+		ri := rssItem{
+			Title:       it.Title,
+			Link:        it.Link,
+			Description: itemHTML(it),
+			GUID:        rssGUID{IsPermaLink: true, Value: it.Link},
+			Categories:  it.Categories,
+		}
+		if it.PubDate > 0 {
+			ri.PubDate = time.Unix(it.PubDate, 0).UTC().Format(time.RFC1123Z)
+		}
+		if it.Source != "" {
+			ri.Source = &rssSource{URL: it.Source, Value: it.Source}
+		}
+		ch.Items = append(ch.Items, ri)
+	}
+
+	return rssFeed{Version: "2.0", Atom: "http://www.w3.org/2005/Atom", Channel: ch}
+}
+
+// Atom 1.0, RFC 4287
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Lang    string      `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomPerson  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Links      []atomLink     `xml:"link"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published,omitempty"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    *atomText      `xml:"content,omitempty"`
+	Categories []atomCategory `xml:"category"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+func (fd feed) atom() atomFeed {
+	af := atomFeed{
+		Lang:    fd.lang,
+		Title:   fd.title,
+		ID:      fd.self,
+		Updated: fd.updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: fd.self, Rel: "self", Type: "application/atom+xml"},
+			{Href: fd.home, Rel: "alternate", Type: "text/html"},
+		},
+		Author:  atomPerson{Name: feedTitle, URI: fd.home},
+		Entries: make([]atomEntry, 0, len(fd.items)),
+	}
+
+	for _, it := range fd.items {
+		e := atomEntry{
+			Title:   it.Title,
+			ID:      it.Link,
+			Links:   []atomLink{{Href: it.Link, Rel: "alternate"}},
+			Updated: af.Updated,
+		}
+		if it.PubDate > 0 {
+			e.Updated = time.Unix(it.PubDate, 0).UTC().Format(time.RFC3339)
+			e.Published = e.Updated
+		}
+		if it.Source != "" {
+			e.Links = append(e.Links, atomLink{Href: it.Source, Rel: "via"})
+		}
+		if it.Description != "" {
+			e.Summary = &atomText{Type: "text", Body: it.Description}
+		}
+		switch {
+		case it.Article != "":
+			e.Content = &atomText{Type: "html", Body: it.Article}
+		case it.HTML != "":
+			e.Content = &atomText{Type: "html", Body: it.HTML}
+		}
+		for _, c := range it.Categories {
+			e.Categories = append(e.Categories, atomCategory{Term: c})
+		}
+		af.Entries = append(af.Entries, e)
+	}
+
+	return af
+}
+
+// JSON Feed 1.1, https://www.jsonfeed.org/version/1.1/
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Language    string         `json:"language,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string   `json:"id"`
+	URL           string   `json:"url"`
+	Title         string   `json:"title,omitempty"`
+	ContentHTML   string   `json:"content_html,omitempty"`
+	ContentText   string   `json:"content_text,omitempty"`
+	Summary       string   `json:"summary,omitempty"`
+	Image         string   `json:"image,omitempty"`
+	DatePublished string   `json:"date_published,omitempty"`
+	Language      string   `json:"language,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+}
+
+func (fd feed) json() jsonFeed {
+	jf := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       fd.title,
+		HomePageURL: fd.home,
+		FeedURL:     fd.self,
+		Language:    fd.lang,
+		Items:       make([]jsonFeedItem, 0, len(fd.items)),
+	}
+
+	for _, it := range fd.items {
+		ji := jsonFeedItem{
+			ID:       it.Link,
+			URL:      it.Link,
+			Title:    it.Title,
+			Image:    it.Image,
+			Language: it.Lang,
+			Tags:     it.Categories,
+		}
+		if it.PubDate > 0 {
+			ji.DatePublished = time.Unix(it.PubDate, 0).UTC().Format(time.RFC3339)
+		}
+		// у новости должен быть content_html или content_text
+		switch {
+		case it.Article != "":
+			ji.ContentHTML, ji.Summary = it.Article, it.Description
+		case it.HTML != "":
+			ji.ContentHTML, ji.Summary = it.HTML, it.Description
+		case it.Description != "":
+			ji.ContentText = it.Description
+		default:
+			ji.ContentText = it.Title
+		}
+		jf.Items = append(jf.Items, ji)
+	}
+
+	return jf
+}
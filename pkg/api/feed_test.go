@@ -0,0 +1,156 @@
+package api
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"news/pkg/storage/memdb"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApi_feedHandler(t *testing.T) {
+
+	api := New(memdb.New(), log.New(io.Discard, "", 0))
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		api.r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr
+	}
+
+	published := time.Unix(memdb.SampleItem.PubDate, 0).UTC()
+
+	t.Run("rss", func(t *testing.T) {
+		rr := serve("/feed.rss?limit=2&category=go")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Api.feedHandler() got response code = %d, want = %d", rr.Code, http.StatusOK)
+		}
+		if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/rss+xml") {
+			t.Errorf("Api.feedHandler() got content type = %q, want = application/rss+xml", ct)
+		}
+
+		// при разборе <atom:link> попадает в поле <link>,
+		// поэтому ссылки канала читаются списком
+		var got struct {
+			Version string `xml:"version,attr"`
+			Channel struct {
+				Title         string    `xml:"title"`
+				Links         []string  `xml:"link"`
+				LastBuildDate string    `xml:"lastBuildDate"`
+				Items         []rssItem `xml:"item"`
+			} `xml:"channel"`
+		}
+		if err := xml.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+			t.Fatalf("Api.feedHandler() got error = %v", err)
+		}
+		ch := got.Channel
+		if got.Version != "2.0" || ch.Title != "Новости: go" || len(ch.Links) == 0 ||
+			ch.Links[0] != "http://example.com/" || len(ch.Items) != 2 {
+			t.Fatalf("Api.feedHandler() got = %+v", got)
+		}
+		if built, err := time.Parse(time.RFC1123Z, ch.LastBuildDate); err != nil || !built.Equal(published) {
+			t.Errorf("Api.feedHandler() got lastBuildDate = %q, want = %v", ch.LastBuildDate, published)
+		}
+		it := ch.Items[0]
+		if it.Link != memdb.SampleItem.Link || it.GUID.Value != memdb.SampleItem.Link ||
+			!reflect.DeepEqual(it.Categories, memdb.SampleItem.Categories) {
+			t.Errorf("Api.feedHandler() got item = %+v", it)
+		}
+	})
+
+	t.Run("atom", func(t *testing.T) {
+		rr := serve("/feed.atom?lang=en")
+		if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/atom+xml") {
+			t.Errorf("Api.feedHandler() got content type = %q, want = application/atom+xml", ct)
+		}
+
+		var got atomFeed
+		if err := xml.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+			t.Fatalf("Api.feedHandler() got error = %v", err)
+		}
+		if got.XMLName.Space != "http://www.w3.org/2005/Atom" || got.Lang != "en" || len(got.Entries) != defaultLimit {
+			t.Fatalf("Api.feedHandler() got = %+v", got)
+		}
+		if got.Updated != published.Format(time.RFC3339) || got.Entries[0].ID != memdb.SampleItem.Link {
+			t.Errorf("Api.feedHandler() got updated = %q, entry = %+v", got.Updated, got.Entries[0])
+		}
+		if lm := rr.Header().Get("Last-Modified"); lm != published.Format(http.TimeFormat) {
+			t.Errorf("Api.feedHandler() got Last-Modified = %q, want = %q", lm, published.Format(http.TimeFormat))
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		rr := serve("/feed.json?limit=1")
+		if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/feed+json") {
+			t.Errorf("Api.feedHandler() got content type = %q, want = application/feed+json", ct)
+		}
+
+		var got jsonFeed
+		if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+			t.Fatalf("Api.feedHandler() got error = %v", err)
+		}
+		want := jsonFeedItem{
+			ID:            memdb.SampleItem.Link,
+			URL:           memdb.SampleItem.Link,
+			Title:         memdb.SampleItem.Title,
+			ContentText:   memdb.SampleItem.Description,
+			Image:         memdb.SampleItem.Image,
+			DatePublished: published.Format(time.RFC3339),
+			Language:      memdb.SampleItem.Lang,
+			Tags:          memdb.SampleItem.Categories,
+		}
+		if got.Version != "https://jsonfeed.org/version/1.1" || got.FeedURL != "http://example.com/feed.json?limit=1" ||
+			len(got.Items) != 1 || !reflect.DeepEqual(got.Items[0], want) {
+			t.Errorf("Api.feedHandler() got = %+v, want item = %+v", got, want)
+		}
+	})
+
+	t.Run("пустая лента", func(t *testing.T) {
+		rr := serve("/feed.json?category=rust")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Api.feedHandler() got response code = %d, want = %d", rr.Code, http.StatusOK)
+		}
+		var got jsonFeed
+		if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+			t.Fatalf("Api.feedHandler() got error = %v", err)
+		}
+		if got.Items == nil || len(got.Items) != 0 {
+			t.Errorf("Api.feedHandler() got items = %v, want empty", got.Items)
+		}
+	})
+
+	t.Run("ошибки", func(t *testing.T) {
+		tests := []struct {
+			path     string
+			wantCode int
+		}{
+			{"/feed.rss?limit=0", http.StatusBadRequest},
+			{"/feed.atom?lang=klingon", http.StatusBadRequest},
+			{"/feed.xml", http.StatusNotFound},
+		}
+		for _, tt := range tests {
+			if rr := serve(tt.path); rr.Code != tt.wantCode {
+				t.Errorf("GET %s got response code = %d, want = %d", tt.path, rr.Code, tt.wantCode)
+			}
+		}
+	})
+}
+
+func Test_baseURL(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/feed.rss", nil)
+	r.Host = "news.example"
+	if got := baseURL(r); got != "http://news.example" {
+		t.Errorf("baseURL() got = %q, want = %q", got, "http://news.example")
+	}
+
+	r.Header.Set("X-Forwarded-Proto", "https, http")
+	if got := baseURL(r); got != "https://news.example" {
+		t.Errorf("baseURL() got = %q, want = %q", got, "https://news.example")
+	}
+}
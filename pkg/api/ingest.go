@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"news/pkg/ingest"
+	"time"
+)
+
+// maxIngestBody - наибольший размер тела запроса POST /news
+const maxIngestBody = 10 << 20
+
+// ingestResult - итог приёма одной новости
+type ingestResult struct {
+	Link   string `json:"link"`
+	Status string `json:"status"` // accepted, duplicate, invalid или filtered
+	Reason string `json:"reason,omitempty"`
+}
+
+// ingestResponse - ответ POST /news
+type ingestResponse struct {
+	Accepted int            `json:"accepted"`
+	Results  []ingestResult `json:"results"`
+}
+
+// ingestHandler принимает массив новостей от внешних систем
+// и возвращает итог по каждой новости в том же порядке
+func (api *Api) ingestHandler(w http.ResponseWriter, r *http.Request) {
+
+	api.logger.Printf("[DEBUG] method=%s, path=%s, host=%s", r.Method, r.URL.Path, r.Host)
+
+	if api.ingester == nil {
+		api.problem(w, r, http.StatusNotImplemented, "ingest is not enabled")
+		return
+	}
+
+	var items []item
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxIngestBody)).Decode(&items); err != nil {
+		api.problem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(items) > ingest.MaxItems {
+		api.problem(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("too many items, max %d", ingest.MaxItems))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	results, err := api.ingester.Ingest(ctx, items)
+	switch {
+	case errors.Is(err, ingest.ErrClosed):
+		api.problem(w, r, http.StatusServiceUnavailable, err.Error())
+		return
+	case err != nil:
+		api.problem(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	res := ingestResponse{Results: make([]ingestResult, 0, len(results))}
+	for _, ir := range results {
+		if ir.Status == ingest.Accepted {
+			res.Accepted++
+		}
+		res.Results = append(res.Results, ingestResult{Link: ir.Link, Status: ir.Status.String(), Reason: ir.Reason})
+	}
+
+	api.respond(w, r, http.StatusOK, res, nil)
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"news/pkg/ingest"
+	"news/pkg/storage"
+	"news/pkg/storage/memdb"
+	"strings"
+	"testing"
+)
+
+func TestApi_ingestHandler(t *testing.T) {
+
+	logger := log.New(io.Discard, "", 0)
+	in := ingest.New(logger, memdb.New())
+	api := New(memdb.New(), logger).Ingester(in).Tokens("secret")
+
+	src := make(chan storage.ItemContainer)
+	written := in.Join(src)
+	drained := make(chan struct{})
+	go func() {
+		for range written {
+		}
+		close(drained)
+	}()
+
+	serve := func(api *Api, body string) *http.Response {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/news", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		api.r.ServeHTTP(rr, req)
+		return rr.Result()
+	}
+
+	t.Run("приём", func(t *testing.T) {
+		resp := serve(api, `[
+			{"title": "Новость", "content": "<b>Текст</b>", "link": "https://news.com/1"},
+			{"title": "Новость", "link": "https://test.com"},
+			{"link": "https://news.com/2"}
+		]`)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Api.ingestHandler() got response code = %d, want = %d", resp.StatusCode, http.StatusOK)
+		}
+
+		var got ingestResponse
+		if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+			t.Fatalf("Api.ingestHandler() got error = %v", err)
+		}
+
+		want := []string{"accepted", "duplicate", "invalid"}
+		if got.Accepted != 1 || len(got.Results) != len(want) {
+			t.Fatalf("Api.ingestHandler() got = %v", got)
+		}
+		for i := range want {
+			if got.Results[i].Status != want[i] {
+				t.Errorf("Api.ingestHandler() item #%d got = %s, want = %s", i, got.Results[i].Status, want[i])
+			}
+		}
+	})
+
+	t.Run("ошибки", func(t *testing.T) {
+		tests := []struct {
+			name string
+			api  *Api
+			body string
+			want int
+		}{
+			{"не json", api, `{"title":`, http.StatusBadRequest},
+			{"не массив", api, `{"title": "Новость"}`, http.StatusBadRequest},
+			{"слишком много", api, "[" + strings.Repeat(`{},`, ingest.MaxItems) + "{}]", http.StatusRequestEntityTooLarge},
+			{"приём отключен", New(memdb.New(), logger).Tokens("secret"), `[]`, http.StatusNotImplemented},
+		}
+
+		for _, tt := range tests {
+			if resp := serve(tt.api, tt.body); resp.StatusCode != tt.want {
+				t.Errorf("Api.ingestHandler() %s got response code = %d, want = %d", tt.name, resp.StatusCode, tt.want)
+			}
+		}
+
+		close(src)
+		<-drained
+
+		resp := serve(api, `[{"title": "Новость", "link": "https://news.com/3"}]`)
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("Api.ingestHandler() got response code = %d, want = %d", resp.StatusCode, http.StatusServiceUnavailable)
+		}
+	})
+}
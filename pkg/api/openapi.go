@@ -0,0 +1,434 @@
+package api
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"news/pkg/ingest"
+	"strings"
+)
+
+// docsPage - страница с описанием API, которая
+// строится по /openapi.json в браузере
+//
+//go:embed docs.html
+var docsPage []byte
+
+// openapiSpec - описание REST API в формате OpenAPI 3,
+// собирается один раз при запуске
+var openapiSpec = func() []byte {
+	b, err := json.MarshalIndent(spec(), "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return b
+}()
+
+// openapiHandler возвращает описание REST API в формате OpenAPI 3
+func (api *Api) openapiHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openapiSpec)
+}
+
+// docsHandler возвращает страницу с описанием REST API
+func (api *Api) docsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(docsPage)
+}
+
+// obj - объект документа OpenAPI
+type obj = map[string]interface{}
+
+func ref(name string) obj {
+	return obj{"$ref": "#/components/schemas/" + name}
+}
+
+func arrayOf(schema obj) obj {
+	return obj{"type": "array", "items": schema}
+}
+
+// content - тело запроса или ответа в json
+func content(schema obj) obj {
+	return obj{"application/json": obj{"schema": schema}}
+}
+
+func response(description string, schema obj) obj {
+	if schema == nil {
+		return obj{"description": description}
+	}
+	return obj{"description": description, "content": content(schema)}
+}
+
+// problemResponse - ответ с ошибкой по RFC 7807
+func problemResponse(description string) obj {
+	return obj{
+		"description": description,
+		"content":     obj{"application/problem+json": obj{"schema": ref("Problem")}},
+	}
+}
+
+func param(name, in, description string, schema obj) obj {
+	return obj{"name": name, "in": in, "description": description, "required": in == "path", "schema": schema}
+}
+
+func intSchema(min, max int) obj {
+	s := obj{"type": "integer", "minimum": min}
+	if max > 0 {
+		s["maximum"] = max
+	}
+	return s
+}
+
+var str = obj{"type": "string"}
+
+// enveloped заворачивает схему ответа /api/v1 в envelope
+func enveloped(data obj, paged bool) obj {
+	props := obj{"data": data}
+	if paged {
+		props["meta"] = ref("Meta")
+	}
+	return obj{"type": "object", "properties": props, "required": []string{"data"}}
+}
+
+// operations возвращает методы, общие для / и /api/v1.
+// Если v1 - ответы завёрнуты в envelope
+func operations(v1 bool) obj {
+	body := func(schema obj, paged bool) obj {
+		if v1 {
+			return enveloped(schema, paged)
+		}
+		return schema
+	}
+	tag := "news"
+	admin := "admin"
+	if v1 {
+		tag, admin = "v1 news", "v1 admin"
+	}
+	deadLetterID := param("id", "path", "номер записи", intSchema(0, 0))
+	adminToken := []obj{{"adminToken": []string{}}}
+
+	return obj{
+		"/news/{n}": obj{"get": obj{
+			"tags":        []string{tag},
+			"summary":     "n последних новостей",
+			"operationId": opID(v1, "latestNews"),
+			"parameters": []obj{
+				param("n", "path", "сколько новостей вернуть", intSchema(1, maxLimit)),
+				param("lang", "query", "только новости на этом языке, ISO 639-1", str),
+			},
+			"responses": obj{
+				"200": response("новости по убыванию даты публикации", body(arrayOf(ref("Item")), true)),
+				"400": problemResponse("неверные параметры"),
+				"501": problemResponse("хранилище не умеет фильтровать новости"),
+				"500": problemResponse("ошибка БД"),
+				"504": problemResponse("БД не ответила вовремя"),
+			},
+		}},
+		"/news": obj{"post": obj{
+			"tags":        []string{tag},
+			"summary":     "принять новости от внешней системы",
+			"description": "Новости проверяются, дубликаты отсеиваются, остальные проходят шаги обработки и записываются в БД вместе с новостями rss-лент.",
+			"operationId": opID(v1, "ingestNews"),
+			"security":    []obj{{"apiToken": []string{}}},
+			"requestBody": obj{
+				"required": true,
+				"content":  content(obj{"type": "array", "maxItems": ingest.MaxItems, "items": ref("Item")}),
+			},
+			"responses": obj{
+				"200": response("итог по каждой новости в порядке запроса", body(ref("IngestResponse"), false)),
+				"400": problemResponse("тело запроса не разбирается"),
+				"401": problemResponse("нет API-токена или он неверный"),
+				"403": problemResponse("API-токены не настроены"),
+				"413": problemResponse("слишком много новостей"),
+				"501": problemResponse("приём новостей не подключен"),
+				"503": problemResponse("приложение останавливается"),
+			},
+		}},
+		"/admin/deadletters": obj{"get": obj{
+			"tags":        []string{admin},
+			"summary":     "новости, которые не удалось записать в БД",
+			"operationId": opID(v1, "listDeadLetters"),
+			"security":    adminToken,
+			"responses": obj{
+				"200": response("записи хранилища dead-letter", body(arrayOf(ref("Letter")), false)),
+				"401": problemResponse("нет токена администратора или он неверный"),
+				"403": problemResponse("токены администратора не настроены"),
+				"404": problemResponse("хранилище dead-letter не подключено"),
+			},
+		}},
+		"/admin/deadletters/replay": obj{"post": obj{
+			"tags":        []string{admin},
+			"summary":     "повторно записать в БД все новости из dead-letter",
+			"operationId": opID(v1, "replayDeadLetters"),
+			"security":    adminToken,
+			"responses": obj{
+				"200": response("итог повторной записи", body(ref("ReplayResult"), false)),
+				"401": problemResponse("нет токена администратора или он неверный"),
+				"403": problemResponse("токены администратора не настроены"),
+				"404": problemResponse("хранилище dead-letter не подключено"),
+			},
+		}},
+		"/admin/deadletters/{id}/replay": obj{"post": obj{
+			"tags":        []string{admin},
+			"summary":     "повторно записать в БД одну новость из dead-letter",
+			"operationId": opID(v1, "replayDeadLetter"),
+			"security":    adminToken,
+			"parameters":  []obj{deadLetterID},
+			"responses": obj{
+				"204": response("новость записана и удалена из dead-letter", nil),
+				"401": problemResponse("нет токена администратора или он неверный"),
+				"403": problemResponse("токены администратора не настроены"),
+				"404": problemResponse("записи нет или хранилище не подключено"),
+				"409": problemResponse("новость по-прежнему не записывается"),
+			},
+		}},
+		"/admin/deadletters/{id}": obj{"delete": obj{
+			"tags":        []string{admin},
+			"summary":     "удалить новость из dead-letter",
+			"operationId": opID(v1, "deleteDeadLetter"),
+			"security":    adminToken,
+			"parameters":  []obj{deadLetterID},
+			"responses": obj{
+				"204": response("запись удалена", nil),
+				"401": problemResponse("нет токена администратора или он неверный"),
+				"403": problemResponse("токены администратора не настроены"),
+				"404": problemResponse("записи нет или хранилище не подключено"),
+			},
+		}},
+	}
+}
+
+func opID(v1 bool, id string) string {
+	if v1 {
+		return "v1" + strings.ToUpper(id[:1]) + id[1:]
+	}
+	return id
+}
+
+// spec возвращает описание REST API в формате OpenAPI 3
+func spec() obj {
+	paths := operations(false)
+	for p, op := range operations(true) {
+		paths["/api/v1"+p] = op
+	}
+
+	paths["/api/v1/news"].(obj)["get"] = obj{
+		"tags":        []string{"v1 news"},
+		"summary":     "страница новостей",
+		"operationId": "v1ListNews",
+		"parameters": []obj{
+			param("limit", "query", "размер страницы, по-умолчанию 20", intSchema(1, maxLimit)),
+			param("offset", "query", "сколько первых новостей пропустить", intSchema(0, 0)),
+			param("lang", "query", "только новости на этом языке, ISO 639-1", str),
+			param("source", "query", "только новости этой rss-ленты", str),
+			param("q", "query", "подстрока заголовка или описания без учёта регистра", str),
+			param("category", "query", "только новости этой категории, без учёта регистра", str),
+		},
+		"responses": obj{
+			"200": response("новости по убыванию даты публикации", enveloped(arrayOf(ref("Item")), true)),
+			"400": problemResponse("неверные параметры"),
+			"501": problemResponse("хранилище не умеет фильтровать новости"),
+			"500": problemResponse("ошибка БД"),
+			"504": problemResponse("БД не ответила вовремя"),
+		},
+	}
+
+	paths["/feed.{format}"] = obj{"get": obj{
+		"tags":        []string{"feeds"},
+		"summary":     "последние новости в виде ленты",
+		"description": "Ленты по одной rss-ленте или категории получаются параметрами source и category.",
+		"operationId": "feed",
+		"parameters": []obj{
+			param("format", "path", "формат ленты: RSS 2.0, Atom 1.0 или JSON Feed 1.1",
+				obj{"type": "string", "enum": []string{"rss", "atom", "json"}}),
+			param("limit", "query", "сколько новостей в ленте, по-умолчанию 20", intSchema(1, maxLimit)),
+			param("lang", "query", "только новости на этом языке, ISO 639-1", str),
+			param("source", "query", "только новости этой rss-ленты", str),
+			param("q", "query", "подстрока заголовка или описания без учёта регистра", str),
+			param("category", "query", "только новости этой категории, без учёта регистра", str),
+		},
+		"responses": obj{
+			"200": obj{
+				"description": "лента в запрошенном формате",
+				"headers":     obj{"Last-Modified": obj{"description": "дата самой свежей новости", "schema": str}},
+				"content": obj{
+					"application/rss+xml":   obj{"schema": str},
+					"application/atom+xml":  obj{"schema": str},
+					"application/feed+json": obj{"schema": obj{"type": "object"}},
+				},
+			},
+			"400": problemResponse("неверные параметры"),
+			"501": problemResponse("хранилище не умеет фильтровать новости"),
+			"500": problemResponse("ошибка БД"),
+			"504": problemResponse("БД не ответила вовремя"),
+		},
+	}}
+	paths["/news/stream"] = obj{"get": obj{
+		"tags":    []string{"news"},
+		"summary": "новости, записанные в БД, как Server-Sent Events",
+		"description": "Первое событие ready содержит курсор, с которого началась подписка. " +
+			"Новости приходят событиями item, в id события - курсор новости. После обрыва браузер " +
+			"передаёт курсор последней новости в заголовке Last-Event-ID и получает пропущенные новости. " +
+			"Если пропущенных новостей уже нет, первым приходит событие reset: последние новости нужно " +
+			"перечитать через /news/{n}. Пока новостей нет, в поток отправляется комментарий heartbeat. " +
+			"Параметры source, q, lang и category можно повторять.",
+		"operationId": "streamNews",
+		"parameters": []obj{
+			param("Last-Event-ID", "header", "курсор последней полученной новости", str),
+			param("cursor", "query", "то же, что Last-Event-ID, для клиентов без EventSource", str),
+			param("source", "query", "только новости этих rss-лент", arrayOf(str)),
+			param("q", "query", "только новости с одним из слов в заголовке или описании", arrayOf(str)),
+			param("lang", "query", "только новости на этих языках, ISO 639-1", arrayOf(str)),
+			param("category", "query", "только новости одной из категорий, без учёта регистра", arrayOf(str)),
+		},
+		"responses": obj{
+			"200": obj{
+				"description": "поток событий ready, reset и item, данные событий в json",
+				"content":     obj{"text/event-stream": obj{"schema": str}},
+			},
+			"400": problemResponse("неверные параметры"),
+			"501": problemResponse("поток новостей не подключен"),
+			"503": problemResponse("приложение останавливается"),
+		},
+	}}
+	paths["/openapi.json"] = obj{"get": obj{
+		"tags":        []string{"service"},
+		"summary":     "это описание API",
+		"operationId": "openapi",
+		"responses":   obj{"200": response("описание в формате OpenAPI 3", obj{"type": "object"})},
+	}}
+	paths["/docs"] = obj{"get": obj{
+		"tags":        []string{"service"},
+		"summary":     "страница с описанием API",
+		"operationId": "docs",
+		"responses": obj{"200": obj{
+			"description": "html-страница",
+			"content":     obj{"text/html": obj{"schema": str}},
+		}},
+	}}
+	paths["/debug/vars"] = obj{"get": obj{
+		"tags":        []string{"service"},
+		"summary":     "метрики приложения из expvar",
+		"operationId": "metrics",
+		"security":    []obj{{"adminToken": []string{}}},
+		"responses": obj{
+			"200": response("метрики, в том числе gRPC-сервера в поле grpc", obj{"type": "object"}),
+			"401": problemResponse("нет токена администратора или он неверный"),
+			"403": problemResponse("токены администратора не настроены"),
+		},
+	}}
+
+	// веб-приложение, все остальные пути - его файлы
+	paths["/"] = obj{"get": obj{
+		"tags":        []string{"service"},
+		"summary":     "веб-приложение",
+		"operationId": "webapp",
+		"responses": obj{"200": obj{
+			"description": "html-страница веб-приложения",
+			"content":     obj{"text/html": obj{"schema": str}},
+		}},
+	}}
+
+	schemas := obj{
+		"Item": obj{
+			"type": "object",
+			"properties": obj{
+				"id":         obj{"type": "integer", "format": "int64", "description": "идентификатор в postgres"},
+				"_id":        obj{"type": "string", "description": "идентификатор в mongodb"},
+				"title":      str,
+				"pubTime":    obj{"type": "integer", "format": "int64", "description": "дата публикации, unix timestamp"},
+				"content":    obj{"type": "string", "description": "описание простым текстом"},
+				"html":       obj{"type": "string", "description": "описание в безопасном html"},
+				"article":    obj{"type": "string", "description": "полный текст статьи в безопасном html"},
+				"image":      obj{"type": "string", "description": "адрес картинки для превью"},
+				"lang":       obj{"type": "string", "description": "язык, ISO 639-1"},
+				"link":       obj{"type": "string", "format": "uri"},
+				"source":     obj{"type": "string", "description": "rss-лента новости"},
+				"author":     obj{"type": "string", "description": "автор, нужен для фильтрации и в БД не хранится"},
+				"categories": obj{"type": "array", "items": str, "description": "категории"},
+			},
+			"required": []string{"title", "link"},
+		},
+		"Meta": obj{
+			"type": "object",
+			"properties": obj{
+				"limit":  obj{"type": "integer"},
+				"offset": obj{"type": "integer"},
+				"count":  obj{"type": "integer", "description": "новостей на странице"},
+				"next":   obj{"type": "string", "description": "адрес следующей страницы, если она может быть"},
+			},
+		},
+		"Problem": obj{
+			"type":        "object",
+			"description": "ошибка по RFC 7807",
+			"properties": obj{
+				"type":     str,
+				"title":    str,
+				"status":   obj{"type": "integer"},
+				"detail":   str,
+				"instance": str,
+				"errors": arrayOf(obj{
+					"type":       "object",
+					"properties": obj{"param": str, "message": str},
+				}),
+			},
+		},
+		"IngestResponse": obj{
+			"type": "object",
+			"properties": obj{
+				"accepted": obj{"type": "integer"},
+				"results": arrayOf(obj{
+					"type": "object",
+					"properties": obj{
+						"link":   str,
+						"status": obj{"type": "string", "enum": []string{"accepted", "duplicate", "invalid", "filtered"}},
+						"reason": str,
+					},
+				}),
+			},
+		},
+		"Letter": obj{
+			"type": "object",
+			"properties": obj{
+				"id":       obj{"type": "integer"},
+				"item":     ref("Item"),
+				"error":    obj{"type": "string", "description": "последняя ошибка записи"},
+				"attempts": obj{"type": "integer"},
+				"failedAt": obj{"type": "integer", "format": "int64", "description": "время последней ошибки, unix timestamp"},
+			},
+		},
+		"ReplayResult": obj{
+			"type": "object",
+			"properties": obj{
+				"replayed": obj{"type": "integer", "description": "записано в БД"},
+				"failed":   obj{"type": "integer", "description": "осталось в хранилище"},
+			},
+		},
+	}
+
+	// REST API /api/v2 по HTTP-аннотациям item.proto
+	v2paths, v2schemas := v2Spec()
+	for p, ops := range v2paths {
+		paths[p] = ops
+	}
+	for name, schema := range v2schemas {
+		schemas[name] = schema
+	}
+
+	return obj{
+		"openapi": "3.0.3",
+		"info": obj{
+			"title":       "News API",
+			"version":     "1.0.0",
+			"description": "REST API агрегатора новостей. Методы без префикса и с префиксом /api/v1 одинаковы, но ответы /api/v1 завёрнуты в envelope. REST API /api/v2 обслуживает шлюз к gRPC-сервису, его описание строится по HTTP-аннотациям pkg/grpc/item.proto, ошибки /api/v2 - в формате google.rpc.Status.",
+		},
+		"paths": paths,
+		"components": obj{
+			"securitySchemes": obj{
+				"apiToken":   obj{"type": "http", "scheme": "bearer", "description": "токен из NEWS_API_TOKENS"},
+				"adminToken": obj{"type": "http", "scheme": "bearer", "description": "токен из NEWS_ADMIN_TOKENS"},
+			},
+			"schemas": schemas,
+		},
+	}
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	newsgrpc "news/pkg/grpc"
+	"news/pkg/storage/memdb"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestApi_openapi(t *testing.T) {
+
+	api := New(memdb.New(), log.New(io.Discard, "", 0))
+
+	rr := httptest.NewRecorder()
+	api.r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Api.openapiHandler() got response code = %d, want = %d", rr.Code, http.StatusOK)
+	}
+
+	var doc struct {
+		OpenAPI    string                                `json:"openapi"`
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Api.openapiHandler() got error = %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("Api.openapiHandler() got openapi = %q, want = 3.x", doc.OpenAPI)
+	}
+
+	t.Run("все маршруты описаны", func(t *testing.T) {
+		// {id:[0-9]+} -> {id}
+		pattern := regexp.MustCompile(`\{(\w+):[^}]*\}`)
+
+		routes := map[string]bool{}
+		var prefixes []string
+		err := api.r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+			path, err := route.GetPathTemplate()
+			if err != nil {
+				return err
+			}
+			path = pattern.ReplaceAllString(path, "{$1}")
+
+			methods, err := route.GetMethods()
+			if err != nil {
+				// PathPrefix без методов: /api/v1, /api/v2/, веб-приложение
+				prefixes = append(prefixes, path)
+				return nil
+			}
+			for _, m := range methods {
+				if m == http.MethodOptions {
+					continue
+				}
+				routes[strings.ToLower(m)+" "+path] = true
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("mux.Router.Walk() error = %v", err)
+		}
+
+		documented := map[string]bool{}
+		for path, ops := range doc.Paths {
+			for m := range ops {
+				documented[m+" "+path] = true
+			}
+		}
+
+		// методы /api/v2/ обслуживает шлюз, они проверяются
+		// в TestApi_openapiV2, веб-приложение описано путём /
+		served := func(r string) bool {
+			path := strings.SplitN(r, " ", 2)[1]
+			return routes[r] || r == "get /" || strings.HasPrefix(path, "/api/v2/")
+		}
+
+		for r := range routes {
+			if !documented[r] {
+				t.Errorf("openapi.json: маршрут %q не описан", r)
+			}
+		}
+		for r := range documented {
+			if !served(r) {
+				t.Errorf("openapi.json: описан маршрут %q, которого нет", r)
+			}
+		}
+
+		// у каждого префикса есть описанные методы
+		for _, p := range prefixes {
+			found := false
+			for r := range documented {
+				path := strings.SplitN(r, " ", 2)[1]
+				if path == p || p != "/" && strings.HasPrefix(path, p) {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("openapi.json: нет методов с префиксом %q", p)
+			}
+		}
+	})
+
+	t.Run("ссылки на схемы", func(t *testing.T) {
+		refs := regexp.MustCompile(`"\$ref":\s*"#/components/schemas/(\w+)"`).FindAllStringSubmatch(rr.Body.String(), -1)
+		if len(refs) == 0 {
+			t.Fatal("openapi.json: нет ссылок на схемы")
+		}
+		var missing []string
+		for _, m := range refs {
+			if _, ok := doc.Components.Schemas[m[1]]; !ok {
+				missing = append(missing, m[1])
+			}
+		}
+		sort.Strings(missing)
+		if len(missing) > 0 {
+			t.Errorf("openapi.json: нет схем %v", missing)
+		}
+	})
+
+	t.Run("страница", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		api.r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/docs", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Api.docsHandler() got response code = %d, want = %d", rr.Code, http.StatusOK)
+		}
+		if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+			t.Errorf("Api.docsHandler() got content type = %q, want = text/html", ct)
+		}
+		if !strings.Contains(rr.Body.String(), "/openapi.json") {
+			t.Errorf("Api.docsHandler() page does not load /openapi.json")
+		}
+	})
+}
+
+func TestApi_openapiV2(t *testing.T) {
+
+	// настоящий шлюз к gRPC-серверу: методы /api/v2,
+	// описанные в openapi.json, должны в нём быть
+	logger := log.New(io.Discard, "", 0)
+	srv := newsgrpc.NewServer(newsgrpc.New(memdb.New(), logger))
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	go srv.Serve(lis)
+	defer srv.Shutdown(context.Background())
+
+	gw, err := newsgrpc.NewGateway(context.Background(), lis.Addr().String())
+	if err != nil {
+		t.Fatalf("newsgrpc.NewGateway() error = %v", err)
+	}
+	defer gw.Close()
+
+	api := New(memdb.New(), logger).V2(gw)
+
+	var doc struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(openapiSpec, &doc); err != nil {
+		t.Fatalf("openapi.json error = %v", err)
+	}
+
+	params := regexp.MustCompile(`\{\w+\}`)
+	n := 0
+	for path, ops := range doc.Paths {
+		if !strings.HasPrefix(path, "/api/v2/") {
+			continue
+		}
+		for m := range ops {
+			n++
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			req := httptest.NewRequest(strings.ToUpper(m), params.ReplaceAllString(path, "1"), strings.NewReader("{}")).WithContext(ctx)
+			rr := httptest.NewRecorder()
+			api.r.ServeHTTP(rr, req)
+			cancel()
+
+			// ошибки маршрутизации шлюза: путь или метод не найден
+			var st struct {
+				Message string `json:"message"`
+			}
+			json.NewDecoder(rr.Body).Decode(&st)
+			if st.Message == http.StatusText(http.StatusNotFound) || st.Message == http.StatusText(http.StatusMethodNotAllowed) {
+				t.Errorf("openapi.json: описан маршрут %q, которого нет в шлюзе: %d %s", m+" "+path, rr.Code, st.Message)
+			}
+		}
+	}
+	if n == 0 {
+		t.Errorf("openapi.json: нет методов /api/v2")
+	}
+}
@@ -0,0 +1,210 @@
+package api
+
+import (
+	newsgrpc "news/pkg/grpc"
+	"regexp"
+	"strconv"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// v2Summaries - краткие описания методов /api/v2. Комментарии
+// item.proto в скомпилированные дескрипторы не попадают
+var v2Summaries = map[string]string{
+	"List":       "последние новости постранично",
+	"Search":     "поиск новостей по подстроке заголовка или описания",
+	"GetItem":    "новость по идентификатору или ссылке",
+	"CreateItem": "добавить новость",
+	"UpdateItem": "изменить новость по ссылке, пустые поля не меняются",
+	"DeleteItem": "удалить новость по ссылке",
+	"Watch":      "новости по мере записи в БД",
+	"Ingest":     "принять новости от внешних систем",
+}
+
+// v2Admin - методы /api/v2, для которых
+// нужен токен администратора, а не API-токен
+var v2Admin = map[string]bool{"CreateItem": true, "UpdateItem": true, "DeleteItem": true}
+
+// pathVar - переменная шаблона пути HTTP-аннотации: {id} или {id=*}
+var pathVar = regexp.MustCompile(`\{([^}=]+)(=[^}]*)?\}`)
+
+// v2Spec возвращает пути и схемы /api/v2, построенные по
+// HTTP-аннотациям сервиса News, поэтому описание следует
+// за item.proto так же, как шлюз grpc-gateway
+func v2Spec() (paths, schemas obj) {
+	paths, schemas = obj{}, obj{
+		"v2Status": obj{
+			"type":        "object",
+			"description": "ошибка gRPC, google.rpc.Status",
+			"properties": obj{
+				"code":    obj{"type": "integer", "description": "код статуса gRPC"},
+				"message": str,
+				"details": arrayOf(obj{"type": "object"}),
+			},
+		},
+	}
+
+	methods := newsgrpc.File_pkg_grpc_item_proto.Services().ByName("News").Methods()
+	for i := 0; i < methods.Len(); i++ {
+		m := methods.Get(i)
+		rule, _ := proto.GetExtension(m.Options(), annotations.E_Http).(*annotations.HttpRule)
+		if rule == nil {
+			continue
+		}
+
+		for n, b := range append([]*annotations.HttpRule{rule}, rule.AdditionalBindings...) {
+			method, path := httpPattern(b)
+			if method == "" {
+				continue
+			}
+			path = pathVar.ReplaceAllString(path, "{$1}")
+
+			op := v2Operation(m, b, path, schemas)
+			op["operationId"] = "v2" + string(m.Name())
+			if n > 0 {
+				op["operationId"] = "v2" + string(m.Name()) + strconv.Itoa(n+1)
+			}
+
+			ops, _ := paths[path].(obj)
+			if ops == nil {
+				ops = obj{}
+				paths[path] = ops
+			}
+			ops[method] = op
+		}
+	}
+
+	return paths, schemas
+}
+
+// httpPattern возвращает метод и шаблон пути HTTP-аннотации
+func httpPattern(b *annotations.HttpRule) (method, path string) {
+	switch p := b.Pattern.(type) {
+	case *annotations.HttpRule_Get:
+		return "get", p.Get
+	case *annotations.HttpRule_Post:
+		return "post", p.Post
+	case *annotations.HttpRule_Put:
+		return "put", p.Put
+	case *annotations.HttpRule_Patch:
+		return "patch", p.Patch
+	case *annotations.HttpRule_Delete:
+		return "delete", p.Delete
+	}
+	return "", ""
+}
+
+// v2Operation описывает метод m, вызываемый по HTTP-аннотации b.
+// Поля запроса, которых нет в пути, передаются в теле, если
+// оно есть, иначе в параметрах запроса
+func v2Operation(m protoreflect.MethodDescriptor, b *annotations.HttpRule, path string, schemas obj) obj {
+	in := m.Input()
+
+	var params []obj
+	inPath := map[string]bool{}
+	for _, v := range pathVar.FindAllStringSubmatch(path, -1) {
+		inPath[v[1]] = true
+		params = append(params, param(v[1], "path", "", fieldSchema(in.Fields().ByName(protoreflect.Name(v[1])), schemas)))
+	}
+
+	security := "apiToken"
+	if v2Admin[string(m.Name())] {
+		security = "adminToken"
+	}
+	op := obj{
+		"tags":     []string{"v2"},
+		"summary":  v2Summaries[string(m.Name())],
+		"security": []obj{{security: []string{}}},
+	}
+
+	if b.Body == "*" {
+		body := obj{"required": true, "content": content(messageRef(in, schemas))}
+		if m.IsStreamingClient() {
+			body["description"] = "json-объекты " + string(in.Name()) + ", по одному в строке"
+		}
+		op["requestBody"] = body
+	} else {
+		fields := in.Fields()
+		for i := 0; i < fields.Len(); i++ {
+			fd := fields.Get(i)
+			if inPath[string(fd.Name())] || fd.Kind() == protoreflect.MessageKind {
+				continue
+			}
+			params = append(params, param(fd.JSONName(), "query", "", fieldSchema(fd, schemas)))
+		}
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	out, description := messageRef(m.Output(), schemas), "ответ "+string(m.Output().Name())
+	if m.IsStreamingServer() {
+		// шлюз передаёт каждое сообщение потока отдельной строкой
+		out = obj{"type": "object", "properties": obj{"result": out, "error": ref("v2Status")}}
+		description = "поток json-объектов по одному в строке, сообщение " + string(m.Output().Name()) + " в поле result"
+	}
+	op["responses"] = obj{
+		"200":     response(description, out),
+		"default": response("ошибка, код HTTP соответствует коду gRPC", ref("v2Status")),
+	}
+
+	return op
+}
+
+// messageRef возвращает ссылку на схему сообщения md
+// и добавляет схему в schemas, если её там ещё нет
+func messageRef(md protoreflect.MessageDescriptor, schemas obj) obj {
+	name := "v2" + string(md.Name())
+	if _, ok := schemas[name]; ok {
+		return ref(name)
+	}
+	schemas[name] = obj{} // сообщение может ссылаться на себя
+
+	props := obj{}
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		props[fd.JSONName()] = fieldSchema(fd, schemas)
+	}
+	schemas[name] = obj{"type": "object", "properties": props}
+
+	return ref(name)
+}
+
+// fieldSchema возвращает схему поля fd в представлении protojson
+func fieldSchema(fd protoreflect.FieldDescriptor, schemas obj) obj {
+	var s obj
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		s = obj{"type": "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		s = obj{"type": "integer", "format": "int32"}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		// protojson передаёт 64-битные числа строками
+		s = obj{"type": "string", "format": "int64"}
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		s = obj{"type": "number"}
+	case protoreflect.BytesKind:
+		s = obj{"type": "string", "format": "byte"}
+	case protoreflect.EnumKind:
+		var names []string
+		values := fd.Enum().Values()
+		for i := 0; i < values.Len(); i++ {
+			names = append(names, string(values.Get(i).Name()))
+		}
+		s = obj{"type": "string", "enum": names}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		s = messageRef(fd.Message(), schemas)
+	default:
+		s = obj{"type": "string"}
+	}
+
+	if fd.IsList() {
+		return arrayOf(s)
+	}
+	return s
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// problem - описание ошибки по RFC 7807,
+// отдаётся как application/problem+json
+type problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Errors   []fieldError `json:"errors,omitempty"` // ошибки проверки параметров запроса
+}
+
+// fieldError - ошибка в параметре запроса
+type fieldError struct {
+	Param   string `json:"param"`
+	Message string `json:"message"`
+}
+
+// envelope - ответ /api/v1: данные и сведения о странице
+type envelope struct {
+	Data interface{} `json:"data"`
+	Meta *meta       `json:"meta,omitempty"`
+}
+
+// meta - сведения о странице списка новостей
+type meta struct {
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+	Count  int    `json:"count"`          // новостей на странице
+	Next   string `json:"next,omitempty"` // адрес следующей страницы, если она может быть
+}
+
+// ключ контекста запроса /api/v1
+type v1Key struct{}
+
+// v1Middleware отмечает запросы /api/v1, ответы
+// на которые заворачиваются в envelope
+func (api *Api) v1Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), v1Key{}, true)))
+	})
+}
+
+func isV1(r *http.Request) bool {
+	v, _ := r.Context().Value(v1Key{}).(bool)
+	return v
+}
+
+// respond отвечает данными data со статусом code. Ответы
+// /api/v1 заворачиваются в envelope вместе с m
+func (api *Api) respond(w http.ResponseWriter, r *http.Request, code int, data interface{}, m *meta) {
+	var v interface{} = data
+	if isV1(r) {
+		v = envelope{Data: data, Meta: m}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		api.logger.Printf("[ERROR] method=%s, path=%s, error=%v", r.Method, r.URL.Path, err)
+	}
+}
+
+// problem отвечает ошибкой в формате application/problem+json.
+// Подробности внутренних ошибок клиенту не передаются
+func (api *Api) problem(w http.ResponseWriter, r *http.Request, code int, detail string, errs ...fieldError) {
+	if code >= http.StatusInternalServerError && code != http.StatusNotImplemented && code != http.StatusServiceUnavailable {
+		api.logger.Printf("[ERROR] method=%s, path=%s, error=%s", r.Method, r.URL.Path, detail)
+		detail = ""
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(code),
+		Status:   code,
+		Detail:   detail,
+		Instance: r.URL.Path,
+		Errors:   errs,
+	})
+}
+
+// notFoundHandler и methodNotAllowedHandler отвечают
+// ошибкой, если маршрут /api/v1 не найден
+func (api *Api) notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	api.problem(w, r, http.StatusNotFound, "no such endpoint")
+}
+
+func (api *Api) methodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	api.problem(w, r, http.StatusMethodNotAllowed, r.Method+" is not allowed for "+r.URL.Path)
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"news/pkg/storage/memdb"
+	"reflect"
+	"testing"
+)
+
+func TestApi_v1(t *testing.T) {
+
+	api := New(memdb.New(), log.New(io.Discard, "", 0))
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		api.r.ServeHTTP(rr, httptest.NewRequest(method, path, nil))
+		return rr
+	}
+
+	t.Run("страница", func(t *testing.T) {
+		rr := serve(http.MethodGet, "/api/v1/news?limit=3&offset=6&q=sample")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Api.listHandler() got response code = %d, want = %d", rr.Code, http.StatusOK)
+		}
+
+		var got struct {
+			Data []item `json:"data"`
+			Meta meta   `json:"meta"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+			t.Fatalf("Api.listHandler() got error = %v", err)
+		}
+
+		want := meta{Limit: 3, Offset: 6, Count: 3, Next: "/api/v1/news?limit=3&offset=9&q=sample"}
+		if got.Meta != want || len(got.Data) != 3 {
+			t.Errorf("Api.listHandler() got meta = %+v, items = %d, want = %+v", got.Meta, len(got.Data), want)
+		}
+	})
+
+	t.Run("последняя страница", func(t *testing.T) {
+		rr := serve(http.MethodGet, "/api/v1/news?q=nothing")
+
+		var got envelope
+		if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+			t.Fatalf("Api.listHandler() got error = %v", err)
+		}
+		if got.Meta.Next != "" || got.Meta.Limit != defaultLimit || !reflect.DeepEqual(got.Data, []interface{}{}) {
+			t.Errorf("Api.listHandler() got = %+v", got)
+		}
+	})
+
+	t.Run("n новостей", func(t *testing.T) {
+		rr := serve(http.MethodGet, "/api/v1/news/2")
+
+		var got struct {
+			Data []item `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+			t.Fatalf("Api.itemsHandler() got error = %v", err)
+		}
+		if len(got.Data) != 2 || !reflect.DeepEqual(got.Data[0], memdb.SampleItem) {
+			t.Errorf("Api.itemsHandler() got = %v", got.Data)
+		}
+	})
+
+	t.Run("ошибки", func(t *testing.T) {
+		tests := []struct {
+			name       string
+			method     string
+			path       string
+			wantCode   int
+			wantParams []string
+		}{
+			{"отрицательный limit", http.MethodGet, "/api/v1/news?limit=-1", http.StatusBadRequest, []string{"limit"}},
+			{"большой limit и offset", http.MethodGet, "/api/v1/news?limit=100000&offset=x", http.StatusBadRequest, []string{"limit", "offset"}},
+			{"неизвестный язык", http.MethodGet, "/api/v1/news?lang=klingon", http.StatusBadRequest, []string{"lang"}},
+			{"большое n", http.MethodGet, "/api/v1/news/100000", http.StatusBadRequest, []string{"n"}},
+			{"большое n без версии", http.MethodGet, "/news/100000", http.StatusBadRequest, []string{"n"}},
+			{"n не число", http.MethodGet, "/news/ten", http.StatusBadRequest, []string{"n"}},
+			{"нет метода", http.MethodGet, "/api/v1/unknown", http.StatusNotFound, nil},
+			{"неверный метод", http.MethodPut, "/api/v1/news", http.StatusMethodNotAllowed, nil},
+			{"токены администратора не настроены", http.MethodGet, "/api/v1/admin/deadletters", http.StatusForbidden, nil},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				rr := serve(tt.method, tt.path)
+				if rr.Code != tt.wantCode {
+					t.Fatalf("Api got response code = %d, want = %d", rr.Code, tt.wantCode)
+				}
+				if ct := rr.Header().Get("Content-Type"); ct != "application/problem+json" {
+					t.Errorf("Api got content type = %q, want = %q", ct, "application/problem+json")
+				}
+
+				var got problem
+				if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+					t.Fatalf("Api got error = %v", err)
+				}
+				if got.Status != tt.wantCode || got.Title != http.StatusText(tt.wantCode) || got.Type != "about:blank" {
+					t.Errorf("Api got problem = %+v", got)
+				}
+
+				var params []string
+				for _, e := range got.Errors {
+					params = append(params, e.Param)
+				}
+				if !reflect.DeepEqual(params, tt.wantParams) {
+					t.Errorf("Api got invalid params = %v, want = %v", params, tt.wantParams)
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"news/pkg/broker"
+	"strings"
+	"time"
+)
+
+// настройки потока новостей /news/stream по-умолчанию
+const (
+	heartbeat  = 15 * time.Second // период комментария, который держит соединение открытым
+	retryDelay = 3 * time.Second  // через сколько браузер переподключается после обрыва
+)
+
+// события потока новостей
+const (
+	eventReady = "ready" // подписка началась, data - курсор
+	eventReset = "reset" // курсор устарел, последние новости нужно перечитать
+	eventItem  = "item"  // новость записана в БД
+)
+
+// streamState - курсор в данных событий ready и reset
+type streamState struct {
+	Cursor string `json:"cursor"`
+}
+
+// streamHandler отправляет новости, которые записаны в БД,
+// как Server-Sent Events. Курсор новости передаётся в id
+// события, поэтому после обрыва браузер продолжает с
+// последней полученной новости через заголовок Last-Event-ID
+func (api *Api) streamHandler(w http.ResponseWriter, r *http.Request) {
+
+	api.logger.Printf("[DEBUG] method=%s, path=%s, host=%s", r.Method, r.URL.Path, r.Host)
+
+	if api.broker == nil {
+		api.problem(w, r, http.StatusNotImplemented, "news stream is not enabled")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		api.problem(w, r, http.StatusInternalServerError, "streaming is not supported by response writer")
+		return
+	}
+
+	f, errs := streamFilter(r)
+	if len(errs) > 0 {
+		api.problem(w, r, http.StatusBadRequest, "invalid request parameters", errs...)
+		return
+	}
+
+	// браузер передаёт курсор в заголовке при переподключении,
+	// клиенты без EventSource могут передать его в ?cursor=
+	cursor := r.Header.Get("Last-Event-ID")
+	if cursor == "" {
+		cursor = r.URL.Query().Get("cursor")
+	}
+
+	event := eventReady
+	sub, err := api.broker.Subscribe(cursor, f)
+	if errors.Is(err, broker.ErrCursorExpired) || errors.Is(err, broker.ErrBadCursor) {
+		// новости после курсора потеряны: клиент перечитывает
+		// последние новости и получает новые с текущего момента
+		event = eventReset
+		sub, err = api.broker.Subscribe("", f)
+	}
+	if err != nil {
+		api.problem(w, r, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // буферизация nginx
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n", retryDelay.Milliseconds())
+	if err := writeEvent(w, sub.Cursor, event, streamState{Cursor: sub.Cursor}); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(api.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-ticker.C:
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+
+		case ev, ok := <-sub.C():
+			if !ok {
+				// брокер закрыт или клиент не успевал читать,
+				// во втором случае браузер переподключится
+				// с курсора последней новости
+				if err := sub.Err(); err != nil {
+					api.logger.Printf("[INFO] method=%s, path=%s, stream closed: %v", r.Method, r.URL.Path, err)
+				}
+				return
+			}
+			if err := writeEvent(w, ev.Cursor, eventItem, ev.Item); err != nil {
+				api.logger.Printf("[ERROR] method=%s, path=%s, error=%v", r.Method, r.URL.Path, err)
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent записывает событие event с идентификатором id
+// и данными data в json
+func writeEvent(w io.Writer, id, event string, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", id, event, b)
+	return err
+}
+
+// streamFilter разбирает условия подписки на новости. Параметры
+// ?source=, ?q=, ?lang= и ?category= можно повторять
+func streamFilter(r *http.Request) (broker.Filter, []fieldError) {
+	q := r.URL.Query()
+	var errs []fieldError
+
+	f := broker.Filter{
+		Sources:    values(q["source"]),
+		Keywords:   values(q["q"]),
+		Categories: values(q["category"]),
+	}
+	for _, l := range values(q["lang"]) {
+		f.Langs = append(f.Langs, langParam(l, &errs))
+	}
+
+	return f, errs
+}
+
+// values возвращает непустые значения параметра запроса
+func values(list []string) []string {
+	var res []string
+	for _, v := range list {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"news/pkg/broker"
+	"news/pkg/storage/memdb"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sseEvent - событие потока, comment - комментарии перед ним
+type sseEvent struct {
+	id, event, data string
+	comment         []string
+}
+
+// readEvent читает из потока следующее событие
+func readEvent(t *testing.T, r *bufio.Reader) sseEvent {
+	t.Helper()
+	var ev sseEvent
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("readEvent() error = %v, got = %+v", err, ev)
+		}
+		line = strings.TrimSuffix(line, "\n")
+
+		switch {
+		case line == "" && ev.event != "":
+			return ev
+		case strings.HasPrefix(line, ":"):
+			ev.comment = append(ev.comment, strings.TrimSpace(line[1:]))
+		case strings.HasPrefix(line, "id: "):
+			ev.id = line[len("id: "):]
+		case strings.HasPrefix(line, "event: "):
+			ev.event = line[len("event: "):]
+		case strings.HasPrefix(line, "data: "):
+			ev.data = line[len("data: "):]
+		}
+	}
+}
+
+func TestApi_streamHandler(t *testing.T) {
+
+	b := broker.New(10)
+	api := New(memdb.New(), log.New(io.Discard, "", 0)).Broker(b).Heartbeat(20 * time.Millisecond)
+
+	ts := httptest.NewServer(api.Router())
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	open := func(path, lastEventID string) (*http.Response, *bufio.Reader) {
+		t.Helper()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+path, nil)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s error = %v", path, err)
+		}
+		return resp, bufio.NewReader(resp.Body)
+	}
+
+	var last string // курсор последней полученной новости
+
+	t.Run("новости", func(t *testing.T) {
+		resp, r := open("/news/stream?lang=ru&category=go", "")
+		defer resp.Body.Close()
+
+		if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+			t.Errorf("Api.streamHandler() got content type = %q, want = %q", ct, "text/event-stream")
+		}
+
+		ev := readEvent(t, r)
+		if ev.event != eventReady || ev.id != b.Cursor() || ev.data != `{"cursor":"`+b.Cursor()+`"}` {
+			t.Fatalf("Api.streamHandler() got = %+v", ev)
+		}
+
+		b.Publish(
+			item{Title: "Rust", Lang: "ru", Categories: []string{"Rust"}},
+			item{Title: "Go", Lang: "en", Categories: []string{"Go"}},
+			item{Title: "Дженерики", Lang: "ru", Categories: []string{"GO"}},
+		)
+
+		ev = readEvent(t, r)
+		var got item
+		if err := json.Unmarshal([]byte(ev.data), &got); err != nil {
+			t.Fatalf("Api.streamHandler() got error = %v", err)
+		}
+		if ev.event != eventItem || ev.id != b.Cursor() || got.Title != "Дженерики" {
+			t.Errorf("Api.streamHandler() got = %+v", ev)
+		}
+		last = ev.id
+	})
+
+	t.Run("продолжение с Last-Event-ID", func(t *testing.T) {
+		b.Publish(item{Title: "новость 1"}, item{Title: "новость 2"})
+
+		resp, r := open("/news/stream", last)
+		defer resp.Body.Close()
+
+		if ev := readEvent(t, r); ev.event != eventReady || ev.id != last {
+			t.Fatalf("Api.streamHandler() got = %+v", ev)
+		}
+		for _, want := range []string{"новость 1", "новость 2"} {
+			ev := readEvent(t, r)
+			if !strings.Contains(ev.data, want) {
+				t.Errorf("Api.streamHandler() got = %+v, want = %s", ev, want)
+			}
+		}
+
+		// пока новостей нет, поток поддерживается комментариями
+		time.Sleep(50 * time.Millisecond)
+		b.Publish(item{Title: "новость 3"})
+		ev := readEvent(t, r)
+		if !strings.Contains(ev.data, "новость 3") || len(ev.comment) == 0 || ev.comment[0] != "heartbeat" {
+			t.Errorf("Api.streamHandler() got = %+v, want heartbeat before item", ev)
+		}
+	})
+
+	t.Run("курсор устарел", func(t *testing.T) {
+		b.Publish(make([]item, 20)...)
+
+		resp, r := open("/news/stream?cursor="+last, "")
+		defer resp.Body.Close()
+
+		if ev := readEvent(t, r); ev.event != eventReset || ev.id != b.Cursor() {
+			t.Errorf("Api.streamHandler() got = %+v", ev)
+		}
+	})
+
+	t.Run("ошибки", func(t *testing.T) {
+		resp, _ := open("/news/stream?lang=klingon", "")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Api.streamHandler() got response code = %d, want = %d", resp.StatusCode, http.StatusBadRequest)
+		}
+
+		rr := httptest.NewRecorder()
+		New(memdb.New(), log.New(io.Discard, "", 0)).r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/news/stream", nil))
+		if rr.Code != http.StatusNotImplemented {
+			t.Errorf("Api.streamHandler() got response code = %d, want = %d", rr.Code, http.StatusNotImplemented)
+		}
+	})
+
+	t.Run("брокер закрыт", func(t *testing.T) {
+		resp, r := open("/news/stream", "")
+		defer resp.Body.Close()
+		readEvent(t, r)
+
+		b.Close()
+		if _, err := io.ReadAll(r); err != nil {
+			t.Errorf("Api.streamHandler() got error = %v, want end of stream", err)
+		}
+	})
+}
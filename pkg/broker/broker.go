@@ -0,0 +1,296 @@
+// Package broker рассылает подписчикам новости, которые
+// только что записаны в БД. Последние новости хранятся
+// в кольцевом буфере, поэтому подписчик, который потерял
+// соединение, может продолжить с курсора последней
+// полученной новости и ничего не пропустить
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"news/pkg/storage"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type item = storage.Item
+
+// настройки по-умолчанию
+const (
+	bufferSize = 1000 // сколько последних новостей хранит брокер
+	subBuffer  = 64   // сколько новостей может отстать подписчик
+)
+
+var (
+	// ErrCursorExpired - новостей после курсора уже нет в буфере
+	// или курсор выдан до перезапуска брокера. Подписчику нужно
+	// перечитать последние новости из БД и подписаться заново
+	ErrCursorExpired = errors.New("broker: cursor expired")
+	// ErrBadCursor - курсор не разбирается
+	ErrBadCursor = errors.New("broker: bad cursor")
+	// ErrSlowSubscriber - подписчик не успевал читать новости
+	// и был отключен. Можно подписаться снова с курсора
+	// последней полученной новости
+	ErrSlowSubscriber = errors.New("broker: subscriber too slow")
+	// ErrClosed - брокер закрыт
+	ErrClosed = errors.New("broker: closed")
+)
+
+// Event - новость и её курсор
+type Event struct {
+	Cursor string
+	Item   item
+}
+
+// Filter - условия подписки, пустое
+// поле условия не ограничивает
+type Filter struct {
+	Sources    []string // rss-ленты новостей
+	Keywords   []string // слова в заголовке или описании, без учёта регистра
+	Langs      []string // языки новостей по ISO 639-1
+	Categories []string // категории новостей, без учёта регистра
+}
+
+// Match сообщает, что новость подходит под условия
+func (f Filter) Match(it item) bool {
+	if len(f.Sources) > 0 && !contains(f.Sources, it.Source) {
+		return false
+	}
+	if len(f.Langs) > 0 && !contains(f.Langs, it.Lang) {
+		return false
+	}
+	if len(f.Categories) > 0 && !hasCategory(it, f.Categories) {
+		return false
+	}
+	if len(f.Keywords) == 0 {
+		return true
+	}
+
+	text := strings.ToLower(it.Title + "\n" + it.Description)
+	for _, k := range f.Keywords {
+		if strings.Contains(text, strings.ToLower(k)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Broker рассылает новости подписчикам
+type Broker struct {
+	mu sync.Mutex
+	// эпоха отличает курсоры разных запусков брокера
+	epoch int64
+	seq   uint64 // номер последней новости
+	// кольцевой буфер последних новостей,
+	// ring[seq % len(ring)] - новость с номером seq
+	ring      []item
+	subBuffer int
+	subs      map[*Subscription]struct{}
+	closed    bool
+}
+
+// New возвращает брокер, который хранит size последних
+// новостей. Если size не положительный - 1000 новостей
+func New(size int) *Broker {
+	if size <= 0 {
+		size = bufferSize
+	}
+	return &Broker{
+		epoch:     time.Now().UnixNano(),
+		ring:      make([]item, size),
+		subBuffer: subBuffer,
+		subs:      make(map[*Subscription]struct{}),
+	}
+}
+
+// SubBuffer устанавливает, на сколько новостей может отстать
+// подписчик, прежде чем будет отключен
+func (b *Broker) SubBuffer(n int) *Broker {
+	if n > 0 {
+		b.subBuffer = n
+	}
+	return b
+}
+
+// Publish рассылает новости подписчикам. Подписчики,
+// которые не успевают читать, отключаются, чтобы
+// не задерживать остальных
+func (b *Broker) Publish(items ...item) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	for _, it := range items {
+		b.seq++
+		b.ring[b.seq%uint64(len(b.ring))] = it
+		ev := Event{Cursor: b.cursor(b.seq), Item: it}
+
+		for s := range b.subs {
+			if !s.filter.Match(it) {
+				continue
+			}
+			select {
+			case s.ch <- ev:
+			default:
+				b.drop(s, ErrSlowSubscriber)
+			}
+		}
+	}
+}
+
+// Cursor возвращает курсор последней новости
+func (b *Broker) Cursor() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.cursor(b.seq)
+}
+
+// Subscribe подписывает на новости, подходящие под условия f.
+// Если from пустой, подписчик получит новости, опубликованные
+// после подписки, иначе - все новости после курсора from
+func (b *Broker) Subscribe(from string, f Filter) (*Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrClosed
+	}
+
+	seq := b.seq
+	if from != "" {
+		var err error
+		if seq, err = b.parse(from); err != nil {
+			return nil, err
+		}
+	}
+
+	// новости между курсором и текущим моментом
+	var backlog []Event
+	for n := seq + 1; n <= b.seq; n++ {
+		it := b.ring[n%uint64(len(b.ring))]
+		if f.Match(it) {
+			backlog = append(backlog, Event{Cursor: b.cursor(n), Item: it})
+		}
+	}
+
+	s := &Subscription{
+		Cursor: b.cursor(seq),
+		ch:     make(chan Event, len(backlog)+b.subBuffer),
+		filter: f,
+		broker: b,
+	}
+	for _, ev := range backlog {
+		s.ch <- ev
+	}
+	b.subs[s] = struct{}{}
+
+	return s, nil
+}
+
+// Close отключает всех подписчиков с ошибкой ErrClosed
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for s := range b.subs {
+		b.drop(s, ErrClosed)
+	}
+}
+
+// drop отключает подписчика, вызывается под b.mu
+func (b *Broker) drop(s *Subscription, err error) {
+	delete(b.subs, s)
+	s.err = err
+	close(s.ch)
+}
+
+func (b *Broker) cursor(seq uint64) string {
+	return fmt.Sprintf("%d-%d", b.epoch, seq)
+}
+
+// parse возвращает номер новости курсора, вызывается под b.mu
+func (b *Broker) parse(cursor string) (uint64, error) {
+	epoch, seq, ok := strings.Cut(cursor, "-")
+	if !ok {
+		return 0, ErrBadCursor
+	}
+	e, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return 0, ErrBadCursor
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return 0, ErrBadCursor
+	}
+
+	// курсор из другого запуска, из будущего
+	// или новости после него уже вытеснены
+	if e != b.epoch || n > b.seq || b.seq-n > uint64(len(b.ring)) {
+		return 0, ErrCursorExpired
+	}
+
+	return n, nil
+}
+
+// Subscription - подписка на новости
+type Subscription struct {
+	// Cursor - курсор, с которого началась подписка
+	Cursor string
+
+	ch     chan Event
+	err    error
+	filter Filter
+	broker *Broker
+}
+
+// C возвращает канал новостей. Канал закрывается, когда
+// подписчик отключен, причину возвращает Err
+func (s *Subscription) C() <-chan Event {
+	return s.ch
+}
+
+// Err возвращает причину отключения подписчика
+// или nil, если подписчик не отключен
+func (s *Subscription) Err() error {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	return s.err
+}
+
+// Close отменяет подписку
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+
+	if _, ok := s.broker.subs[s]; ok {
+		s.broker.drop(s, nil)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// hasCategory сообщает, что у новости есть
+// одна из категорий list без учёта регистра
+func hasCategory(it item, list []string) bool {
+	for _, c := range it.Categories {
+		for _, v := range list {
+			if strings.EqualFold(c, v) {
+				return true
+			}
+		}
+	}
+	return false
+}
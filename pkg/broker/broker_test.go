@@ -0,0 +1,171 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func items(n int, source string) []item {
+	var out []item
+	for i := 0; i < n; i++ {
+		out = append(out, item{Title: fmt.Sprintf("новость %d", i), Source: source})
+	}
+	return out
+}
+
+// recv читает из подписки n новостей
+func recv(t *testing.T, s *Subscription, n int) []Event {
+	t.Helper()
+	var out []Event
+	for i := 0; i < n; i++ {
+		select {
+		case ev, ok := <-s.C():
+			if !ok {
+				t.Fatalf("Subscription.C() closed after %d events, err = %v", i, s.Err())
+			}
+			out = append(out, ev)
+		default:
+			t.Fatalf("Subscription.C() got events = %d, want = %d", i, n)
+		}
+	}
+	return out
+}
+
+func TestBroker(t *testing.T) {
+	b := New(10)
+
+	b.Publish(items(3, "a")...) // до подписки
+
+	s, err := b.Subscribe("", Filter{})
+	if err != nil {
+		t.Fatalf("Broker.Subscribe() error = %v", err)
+	}
+	defer s.Close()
+
+	if s.Cursor != b.Cursor() {
+		t.Errorf("Subscription.Cursor got = %s, want = %s", s.Cursor, b.Cursor())
+	}
+
+	b.Publish(items(2, "b")...)
+
+	got := recv(t, s, 2)
+	if got[0].Item.Source != "b" || got[1].Cursor != b.Cursor() {
+		t.Errorf("Subscription.C() got = %+v", got)
+	}
+	if len(s.C()) != 0 {
+		t.Errorf("Subscription.C() got extra events = %d", len(s.C()))
+	}
+
+	t.Run("продолжение_с_курсора", func(t *testing.T) {
+		r, err := b.Subscribe(got[0].Cursor, Filter{})
+		if err != nil {
+			t.Fatalf("Broker.Subscribe() error = %v", err)
+		}
+		defer r.Close()
+
+		if ev := recv(t, r, 1); ev[0].Cursor != got[1].Cursor {
+			t.Errorf("Subscription.C() got cursor = %s, want = %s", ev[0].Cursor, got[1].Cursor)
+		}
+	})
+
+	t.Run("фильтр", func(t *testing.T) {
+		f := Filter{Sources: []string{"a", "b"}, Keywords: []string{"НОВОСТЬ 1"}}
+		r, err := b.Subscribe(fmt.Sprintf("%d-0", b.epoch), f)
+		if err != nil {
+			t.Fatalf("Broker.Subscribe() error = %v", err)
+		}
+		defer r.Close()
+
+		// "новость 1" из ленты a и из ленты b
+		if ev := recv(t, r, 2); ev[0].Item.Source != "a" || ev[1].Item.Source != "b" {
+			t.Errorf("Subscription.C() got = %+v", ev)
+		}
+		if len(r.C()) != 0 {
+			t.Errorf("Subscription.C() got extra events = %d", len(r.C()))
+		}
+	})
+
+	t.Run("курсор_устарел", func(t *testing.T) {
+		old := b.Cursor()
+		b.Publish(items(11, "c")...)
+		recv(t, s, 0)
+
+		for cursor, want := range map[string]error{
+			old:                                  ErrCursorExpired,
+			fmt.Sprintf("%d-5", b.epoch+1):       ErrCursorExpired,
+			fmt.Sprintf("%d-1000", b.epoch):      ErrCursorExpired,
+			"bad":                                ErrBadCursor,
+			fmt.Sprintf("%d-%d", b.epoch, b.seq): nil,
+		} {
+			r, err := b.Subscribe(cursor, Filter{})
+			if !errors.Is(err, want) {
+				t.Errorf("Broker.Subscribe(%q) got error = %v, want = %v", cursor, err, want)
+			}
+			if r != nil {
+				r.Close()
+			}
+		}
+	})
+}
+
+func TestBroker_slowSubscriber(t *testing.T) {
+	b := New(100).SubBuffer(5)
+
+	s, err := b.Subscribe("", Filter{})
+	if err != nil {
+		t.Fatalf("Broker.Subscribe() error = %v", err)
+	}
+
+	b.Publish(items(6, "a")...)
+
+	got := 0
+	for range s.C() {
+		got++
+	}
+	if got != 5 || !errors.Is(s.Err(), ErrSlowSubscriber) {
+		t.Errorf("Subscription got events = %d, err = %v, want = %d, %v", got, s.Err(), 5, ErrSlowSubscriber)
+	}
+
+	// подписчик продолжает с последней полученной новости
+	r, err := b.Subscribe(fmt.Sprintf("%d-5", b.epoch), Filter{})
+	if err != nil {
+		t.Fatalf("Broker.Subscribe() error = %v", err)
+	}
+	if ev := recv(t, r, 1); ev[0].Item.Title != "новость 5" {
+		t.Errorf("Subscription.C() got = %+v", ev)
+	}
+
+	b.Close()
+	if _, ok := <-r.C(); ok || !errors.Is(r.Err(), ErrClosed) {
+		t.Errorf("Subscription.Err() got = %v, want = %v", r.Err(), ErrClosed)
+	}
+	if _, err := b.Subscribe("", Filter{}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Broker.Subscribe() got error = %v, want = %v", err, ErrClosed)
+	}
+}
+
+func TestFilter_Match(t *testing.T) {
+	it := item{Title: "Дженерики в Go", Source: "a", Lang: "ru", Categories: []string{"Go", "Программирование"}}
+
+	tests := []struct {
+		name string
+		f    Filter
+		want bool
+	}{
+		{"пустой фильтр", Filter{}, true},
+		{"язык", Filter{Langs: []string{"en", "ru"}}, true},
+		{"другой язык", Filter{Langs: []string{"en"}}, false},
+		{"категория без учёта регистра", Filter{Categories: []string{"rust", "go"}}, true},
+		{"другая категория", Filter{Categories: []string{"Rust"}}, false},
+		{"все условия", Filter{Sources: []string{"a"}, Keywords: []string{"дженерики"}, Langs: []string{"ru"}, Categories: []string{"GO"}}, true},
+		{"не подходит лента", Filter{Sources: []string{"b"}, Categories: []string{"Go"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.f.Match(it); got != tt.want {
+				t.Errorf("Filter.Match() got = %v, want = %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+// Package client - клиент gRPC-сервиса News. Запросы, которые
+// можно безопасно повторить, повторяются, если сервер временно
+// недоступен, а подписка Watch продолжается с последнего
+// полученного курсора после обрыва соединения
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	newsgrpc "news/pkg/grpc"
+	"time"
+
+	grpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// serviceConfig - политика повторов gRPC для методов News.
+// CreateItem и DeleteItem не повторяются: если сервер успел
+// выполнить запрос, повтор вернёт ошибку AlreadyExists или
+// NotFound, хотя запрос выполнен
+var serviceConfig = fmt.Sprintf(`{
+	"methodConfig": [{
+		"name": [
+			{"service": %[1]q, "method": "List"},
+			{"service": %[1]q, "method": "Search"},
+			{"service": %[1]q, "method": "GetItem"},
+			{"service": %[1]q, "method": "UpdateItem"}
+		],
+		"retryPolicy": {
+			"maxAttempts": 4,
+			"initialBackoff": "0.1s",
+			"maxBackoff": "1s",
+			"backoffMultiplier": 2,
+			"retryableStatusCodes": ["UNAVAILABLE"]
+		}
+	}]
+}`, newsgrpc.News_ServiceDesc.ServiceName)
+
+// задержки между попытками продолжить подписку
+const (
+	followMin = 100 * time.Millisecond
+	followMax = 5 * time.Second
+)
+
+// Client - клиент сервиса News
+type Client struct {
+	newsgrpc.NewsClient
+	conn *grpc.ClientConn
+}
+
+// New подключается к серверу addr. По-умолчанию соединение
+// без шифрования, opts могут это переопределить
+func New(addr string, opts ...grpc.DialOption) (*Client, error) {
+	opts = append([]grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultServiceConfig(serviceConfig),
+	}, opts...)
+
+	conn, err := grpc.Dial(addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{NewsClient: newsgrpc.NewNewsClient(conn), conn: conn}, nil
+}
+
+// WithToken передаёт с каждым запросом API-токен token.
+// Токен передаётся и по соединению без шифрования, поэтому
+// такое соединение годится только для внутренней сети
+func WithToken(token string) grpc.DialOption {
+	return grpc.WithPerRPCCredentials(tokenAuth(token))
+}
+
+// tokenAuth - API-токен в метаданных запроса
+type tokenAuth string
+
+func (t tokenAuth) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+func (t tokenAuth) RequireTransportSecurity() bool {
+	return false
+}
+
+// Close закрывает соединение с сервером
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Follow подписывается на новости и вызывает f для каждой новости,
+// пока не отменён ctx или f не вернёт ошибку. Если соединение
+// оборвалось или сервер отключил подписку, Follow подписывается
+// снова с курсора последней полученной новости. Если курсор
+// устарел, возвращается ошибка с кодом OutOfRange: пропущенные
+// новости нужно перечитать через List
+func (c *Client) Follow(ctx context.Context, in *newsgrpc.WatchRequest, f func(*newsgrpc.Item) error) error {
+	req := &newsgrpc.WatchRequest{Sources: in.Sources, Keywords: in.Keywords, Cursor: in.Cursor}
+	backoff := followMin
+
+	for {
+		err := c.follow(ctx, req, f, func() { backoff = followMin })
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var ferr *funcError
+		if errors.As(err, &ferr) {
+			return ferr.err
+		}
+
+		switch status.Code(err) {
+		case codes.Unavailable, codes.ResourceExhausted:
+		default:
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > followMax {
+			backoff = followMax
+		}
+	}
+}
+
+// funcError - ошибка, которую вернула функция пользователя
+type funcError struct {
+	err error
+}
+
+func (e *funcError) Error() string {
+	return e.err.Error()
+}
+
+// follow читает одну подписку, запоминая курсор
+// в req. reset вызывается после каждого события
+func (c *Client) follow(ctx context.Context, req *newsgrpc.WatchRequest, f func(*newsgrpc.Item) error, reset func()) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := c.Watch(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	for {
+		ev, err := stream.Recv()
+		if err == io.EOF {
+			return status.Error(codes.Unavailable, "stream closed by server")
+		}
+		if err != nil {
+			return err
+		}
+
+		req.Cursor = ev.Cursor
+		reset()
+
+		if ev.Item == nil {
+			continue
+		}
+		if err := f(ev.Item); err != nil {
+			return &funcError{err: err}
+		}
+	}
+}
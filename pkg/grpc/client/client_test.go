@@ -0,0 +1,208 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"news/pkg/broker"
+	newsgrpc "news/pkg/grpc"
+	"news/pkg/storage"
+	"news/pkg/storage/memdb"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	grpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// flaky отвечает Unavailable на первые fails вызовов каждого метода
+// и обрывает первую подписку Watch после первой новости
+type flaky struct {
+	mu    sync.Mutex
+	fails int
+	calls map[string]int
+}
+
+func (f *flaky) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	f.mu.Lock()
+	f.calls[info.FullMethod]++
+	n := f.calls[info.FullMethod]
+	f.mu.Unlock()
+
+	if n <= f.fails {
+		return nil, status.Error(codes.Unavailable, "try again")
+	}
+	return handler(ctx, req)
+}
+
+func (f *flaky) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	f.mu.Lock()
+	f.calls[info.FullMethod]++
+	n := f.calls[info.FullMethod]
+	f.mu.Unlock()
+
+	if n == 1 {
+		ss = &dropStream{ServerStream: ss, left: 2} // курсор и одна новость
+	}
+	return handler(srv, ss)
+}
+
+// dropStream обрывает поток после left сообщений
+type dropStream struct {
+	grpc.ServerStream
+	left int
+}
+
+func (s *dropStream) SendMsg(m any) error {
+	if s.left == 0 {
+		return status.Error(codes.Unavailable, "connection lost")
+	}
+	s.left--
+	return s.ServerStream.SendMsg(m)
+}
+
+func start(t *testing.T, f *flaky) (*Client, *broker.Broker) {
+	t.Helper()
+
+	b := broker.New(100)
+	api := newsgrpc.New(memdb.New(), log.New(io.Discard, "", 0)).Broker(b)
+	srv := newsgrpc.NewServer(api, grpc.UnaryInterceptor(f.unary), grpc.StreamInterceptor(f.stream))
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	go srv.Serve(lis)
+
+	c, err := New(lis.Addr().String())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	t.Cleanup(func() {
+		b.Close()
+		c.Close()
+		srv.Shutdown(context.Background())
+	})
+
+	return c, b
+}
+
+func TestClient_retries(t *testing.T) {
+
+	f := &flaky{fails: 2, calls: map[string]int{}}
+	c, _ := start(t, f)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	items, err := c.List(ctx, &newsgrpc.ListRequest{Limit: 3})
+	if err != nil {
+		t.Fatalf("Client.List() error = %v", err)
+	}
+	if len(items.Items) != 3 {
+		t.Errorf("Client.List() got items = %d, want = %d", len(items.Items), 3)
+	}
+	if n := f.calls["/newsgrpc.News/List"]; n != 3 {
+		t.Errorf("Client.List() got calls = %d, want = %d", n, 3)
+	}
+
+	// добавление новости не повторяется
+	_, err = c.CreateItem(ctx, &newsgrpc.Item{Title: "t", Link: "https://test.com/new"})
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("Client.CreateItem() got code = %v, want = %v", status.Code(err), codes.Unavailable)
+	}
+	if n := f.calls["/newsgrpc.News/CreateItem"]; n != 1 {
+		t.Errorf("Client.CreateItem() got calls = %d, want = %d", n, 1)
+	}
+
+	// удаление тоже: повтор удалённой новости вернул бы NotFound
+	_, err = c.DeleteItem(ctx, &newsgrpc.DeleteItemRequest{Link: memdb.SampleItem.Link})
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("Client.DeleteItem() got code = %v, want = %v", status.Code(err), codes.Unavailable)
+	}
+	if n := f.calls["/newsgrpc.News/DeleteItem"]; n != 1 {
+		t.Errorf("Client.DeleteItem() got calls = %d, want = %d", n, 1)
+	}
+}
+
+func TestClient_Follow(t *testing.T) {
+
+	f := &flaky{calls: map[string]int{}}
+	c, b := start(t, f)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var got []string
+	done := make(chan error, 1)
+
+	// подписка с текущего курсора, чтобы не пропустить
+	// новости, опубликованные до подключения
+	req := &newsgrpc.WatchRequest{Cursor: b.Cursor()}
+
+	go func() {
+		done <- c.Follow(ctx, req, func(it *newsgrpc.Item) error {
+			got = append(got, it.Title)
+			if len(got) == 5 {
+				return fmt.Errorf("enough")
+			}
+			return nil
+		})
+	}()
+
+	for i := 1; i <= 5; i++ {
+		b.Publish(storage.Item{Title: fmt.Sprint(i), Link: fmt.Sprintf("https://test.com/%d", i)})
+	}
+
+	err := <-done
+	if err == nil || err.Error() != "enough" {
+		t.Fatalf("Client.Follow() error = %v, want = %v", err, "enough")
+	}
+
+	// первая подписка оборвана после первой новости,
+	// остальные получены после переподключения
+	if strings.Join(got, ",") != "1,2,3,4,5" {
+		t.Errorf("Client.Follow() got = %v, want = %v", got, "1,2,3,4,5")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if n := f.calls["/newsgrpc.News/Watch"]; n != 2 {
+		t.Errorf("Client.Follow() got subscriptions = %d, want = %d", n, 2)
+	}
+}
+
+func TestWithToken(t *testing.T) {
+
+	logger := log.New(io.Discard, "", 0)
+	ic := newsgrpc.NewInterceptors(logger).Tokens("secret")
+	srv := newsgrpc.NewServer(newsgrpc.New(memdb.New(), logger), ic.ServerOptions()...)
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	go srv.Serve(lis)
+	defer srv.Shutdown(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for token, want := range map[string]codes.Code{"secret": codes.OK, "wrong": codes.Unauthenticated} {
+		c, err := New(lis.Addr().String(), WithToken(token))
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		_, err = c.List(ctx, &newsgrpc.ListRequest{Limit: 1})
+		if status.Code(err) != want {
+			t.Errorf("Client.List() with token %q got code = %v, want = %v", token, status.Code(err), want)
+		}
+		c.Close()
+	}
+}
@@ -0,0 +1,54 @@
+package grpc
+
+import (
+	context "context"
+	"errors"
+	"news/pkg/broker"
+	"news/pkg/ingest"
+	"news/pkg/storage"
+
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+var internalError = status.Error(codes.Internal, "internal server error")
+
+// statusError переводит ошибку хранилища, брокера или приёма
+// новостей в статус gRPC. Статус возвращается как есть, а
+// подробности неизвестных ошибок клиенту не передаются
+func statusError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return status.Error(codes.NotFound, "item not found")
+	case errors.Is(err, storage.ErrInvalidLimit):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled):
+		return status.FromContextError(err).Err()
+	case errors.Is(err, broker.ErrBadCursor):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, broker.ErrCursorExpired):
+		return status.Error(codes.OutOfRange, err.Error())
+	case errors.Is(err, broker.ErrSlowSubscriber):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, broker.ErrClosed), errors.Is(err, ingest.ErrClosed):
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return internalError
+}
+
+// storageError переводит ошибку хранилища в статус gRPC.
+// Подробности внутренних ошибок только логгируются
+func (api *API) storageError(err error) error {
+	st := statusError(err)
+	if st == internalError {
+		api.logger.Printf("[ERROR] storage error=%v", err)
+	}
+	return st
+}
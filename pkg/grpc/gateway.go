@@ -0,0 +1,54 @@
+package grpc
+
+import (
+	context "context"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	grpc "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Gateway - REST API /api/v2 по HTTP/JSON-аннотациям item.proto.
+// Шлюз переводит HTTP-запросы в запросы к gRPC-серверу, поэтому
+// они проходят те же перехватчики, что и запросы gRPC-клиентов.
+// Заголовок Authorization передаётся серверу как метаданные
+type Gateway struct {
+	mux  *runtime.ServeMux
+	conn *grpc.ClientConn
+}
+
+// NewGateway возвращает шлюз к gRPC-серверу addr. По-умолчанию
+// соединение без шифрования, opts могут это переопределить
+func NewGateway(ctx context.Context, addr string, opts ...grpc.DialOption) (*Gateway, error) {
+	opts = append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, opts...)
+
+	conn, err := grpc.DialContext(ctx, addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := runtime.NewServeMux(
+		// пустые поля тоже попадают в ответ, как в /news/{n}
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions:   protojson.MarshalOptions{EmitUnpopulated: true},
+			UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+		}),
+	)
+	if err := RegisterNewsHandler(ctx, mux, conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &Gateway{mux: mux, conn: conn}, nil
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mux.ServeHTTP(w, r)
+}
+
+// Close закрывает соединение с gRPC-сервером
+func (g *Gateway) Close() error {
+	return g.conn.Close()
+}
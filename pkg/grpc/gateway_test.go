@@ -0,0 +1,132 @@
+package grpc
+
+import (
+	"bufio"
+	context "context"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"news/pkg/broker"
+	"news/pkg/storage/memdb"
+	"testing"
+	"time"
+)
+
+func TestGateway(t *testing.T) {
+
+	logger := log.New(io.Discard, "", 0)
+	b := broker.New(10)
+	ic := NewInterceptors(logger).Tokens("secret")
+	srv := NewServer(New(memdb.New(), logger).Broker(b), ic.ServerOptions()...)
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	go srv.Serve(lis)
+	defer srv.Shutdown(context.Background())
+	defer b.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	gw, err := NewGateway(ctx, lis.Addr().String())
+	if err != nil {
+		t.Fatalf("NewGateway() error = %v", err)
+	}
+	defer gw.Close()
+
+	ts := httptest.NewServer(gw)
+	defer ts.Close()
+
+	get := func(path, token string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s error = %v", path, err)
+		}
+		return resp
+	}
+
+	t.Run("маршруты", func(t *testing.T) {
+		tests := []struct {
+			path     string
+			token    string
+			wantCode int
+		}{
+			{"/api/v2/news?limit=2", "secret", http.StatusOK},
+			{"/api/v2/news?limit=-1", "secret", http.StatusBadRequest},
+			{"/api/v2/news", "", http.StatusUnauthorized},
+			{"/api/v2/news", "wrong", http.StatusUnauthorized},
+			{"/api/v2/news/1", "secret", http.StatusOK},
+			{"/api/v2/news/100", "secret", http.StatusNotFound},
+			{"/api/v2/item?link=https://test.com", "secret", http.StatusOK},
+			{"/api/v2/search?query=sample&limit=1", "secret", http.StatusOK},
+			{"/api/v2/search", "secret", http.StatusBadRequest},
+		}
+
+		for _, tt := range tests {
+			resp := get(tt.path, tt.token)
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("GET %s got response code = %d, want = %d", tt.path, resp.StatusCode, tt.wantCode)
+			}
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		resp := get("/api/v2/news?limit=2", "secret")
+		defer resp.Body.Close()
+
+		var got struct {
+			Items []struct {
+				Title   string `json:"title"`
+				PubTime string `json:"pubTime"`
+				Link    string `json:"link"`
+				Source  string `json:"source"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+			t.Fatalf("GET /api/v2/news error = %v", err)
+		}
+		if len(got.Items) != 2 || got.Items[0].Title != memdb.SampleItem.Title ||
+			got.Items[0].PubTime != "5555555" || got.NextPageToken == "" {
+			t.Errorf("GET /api/v2/news got = %+v", got)
+		}
+	})
+
+	t.Run("watch", func(t *testing.T) {
+		resp := get("/api/v2/watch", "secret")
+		defer resp.Body.Close()
+
+		r := bufio.NewReader(resp.Body)
+		var ev struct {
+			Result struct {
+				Cursor string `json:"cursor"`
+				Item   *struct {
+					Title string `json:"title"`
+				} `json:"item"`
+			} `json:"result"`
+		}
+
+		line, err := r.ReadBytes('\n')
+		if err != nil || json.Unmarshal(line, &ev) != nil || ev.Result.Cursor != b.Cursor() {
+			t.Fatalf("GET /api/v2/watch got = %s, error = %v", line, err)
+		}
+
+		b.Publish(storItem{Title: "Новость"})
+
+		line, err = r.ReadBytes('\n')
+		if err != nil || json.Unmarshal(line, &ev) != nil || ev.Result.Item == nil || ev.Result.Item.Title != "Новость" {
+			t.Errorf("GET /api/v2/watch got = %s, error = %v", line, err)
+		}
+	})
+}
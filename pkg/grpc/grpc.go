@@ -2,22 +2,35 @@ package grpc
 
 import (
 	context "context"
-	"errors"
+	"encoding/base64"
 	"fmt"
+	"io"
 	"log"
+	"net/url"
+	"news/pkg/broker"
+	"news/pkg/ingest"
+	"news/pkg/langdetect"
+	"news/pkg/sanitize"
 	"news/pkg/storage"
+	"strconv"
+	"strings"
+	"time"
 
-	wrapperspb "google.golang.org/protobuf/types/known/wrapperspb"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
 )
 
-var internalError = fmt.Errorf("internal server error")
-
 type stor = storage.Storage
 type storItem = storage.Item
 
 type API struct {
 	logger  *log.Logger
 	storage stor
+	// брокер новостей для Watch, по-умолчанию nil
+	broker *broker.Broker
+	// приём новостей для Ingest, по-умолчанию nil
+	ingester *ingest.Ingester
 	UnimplementedNewsServer
 }
 
@@ -28,6 +41,20 @@ func New(storage stor, logger *log.Logger) *API {
 	}
 }
 
+// Broker подключает брокер, из которого Watch
+// получает новости, записанные в БД
+func (api *API) Broker(b *broker.Broker) *API {
+	api.broker = b
+	return api
+}
+
+// Ingester подключает приём новостей, через
+// который Ingest передаёт новости на запись в БД
+func (api *API) Ingester(in *ingest.Ingester) *API {
+	api.ingester = in
+	return api
+}
+
 func ofStorageItems(items ...storItem) []*Item {
 	out := make([]*Item, 0, len(items))
 	for i := range items {
@@ -36,17 +63,327 @@ func ofStorageItems(items ...storItem) []*Item {
 	return out
 }
 
-func (api *API) List(ctx context.Context, in *wrapperspb.Int64Value) (*Items, error) {
+// размер страницы List и Search
+const (
+	defaultLimit = 20
+	maxLimit     = 1000
+)
+
+func (api *API) List(ctx context.Context, in *ListRequest) (*Items, error) {
+	return api.page(ctx, in.Limit, in.PageToken, storage.Filter{Lang: in.Lang, Source: in.Source})
+}
+
+func (api *API) Search(ctx context.Context, in *SearchRequest) (*Items, error) {
+	if strings.TrimSpace(in.Query) == "" {
+		return nil, status.Error(codes.InvalidArgument, "empty query")
+	}
+	return api.page(ctx, in.Limit, in.PageToken,
+		storage.Filter{Query: strings.TrimSpace(in.Query), Lang: in.Lang, Source: in.Source})
+}
+
+// page возвращает страницу новостей, подходящих под f
+func (api *API) page(ctx context.Context, limit int64, token string, f storage.Filter) (*Items, error) {
+	switch {
+	case limit < 0:
+		return nil, status.Error(codes.InvalidArgument, "negative limit")
+	case limit == 0:
+		limit = defaultLimit
+	case limit > maxLimit:
+		limit = maxLimit
+	}
+
+	if f.Lang != "" {
+		if f.Lang = langdetect.Normalize(f.Lang); f.Lang == "" {
+			return nil, status.Error(codes.InvalidArgument, "invalid lang")
+		}
+	}
+
+	var err error
+	if f.Offset, err = parsePageToken(token); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid page token")
+	}
+
+	var items []storItem
+
+	if f == (storage.Filter{}) {
+		items, err = api.storage.Items(ctx, int(limit))
+	} else {
+		db, ok := api.storage.(storage.Filterer)
+		if !ok {
+			return nil, status.Error(codes.Unimplemented, "filtering is not supported by storage")
+		}
+		items, err = db.FilterItems(ctx, int(limit), f)
+	}
+	if err != nil {
+		return nil, api.storageError(err)
+	}
+
+	out := &Items{Items: ofStorageItems(items...)}
+	if len(items) == int(limit) {
+		out.NextPageToken = pageToken(f.Offset + len(items))
+	}
+	return out, nil
+}
+
+// pageToken возвращает токен страницы, которая
+// начинается с новости номер offset
+func pageToken(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func parsePageToken(token string) (int, error) {
+	if token == "" {
+		return 0, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+	offset, err := strconv.Atoi(string(b))
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("bad offset %q", b)
+	}
+	return offset, nil
+}
+
+func (api *API) GetItem(ctx context.Context, in *GetItemRequest) (*Item, error) {
+	var it storItem
+	var err error
+
+	switch key := in.Key.(type) {
+	case *GetItemRequest_Id:
+		db, ok := api.storage.(storage.IDGetter)
+		if !ok {
+			return nil, status.Error(codes.Unimplemented, "lookup by id is not supported by storage")
+		}
+		it, err = db.ItemByID(ctx, key.Id)
+
+	case *GetItemRequest_Oid:
+		db, ok := api.storage.(storage.OIDGetter)
+		if !ok {
+			return nil, status.Error(codes.Unimplemented, "lookup by oid is not supported by storage")
+		}
+		if len(key.Oid) != len(it.Oid) {
+			return nil, status.Error(codes.InvalidArgument, "invalid oid")
+		}
+		var oid [12]byte
+		copy(oid[:], key.Oid)
+		it, err = db.ItemByOID(ctx, oid)
+
+	case *GetItemRequest_Link:
+		db, serr := api.editor()
+		if serr != nil {
+			return nil, serr
+		}
+		it, err = db.Item(ctx, key.Link)
+
+	default:
+		return nil, status.Error(codes.InvalidArgument, "id, oid or link required")
+	}
+	if err != nil {
+		return nil, api.storageError(err)
+	}
+
+	return ofStorageItem(&it), nil
+}
+
+func (api *API) CreateItem(ctx context.Context, in *Item) (*Item, error) {
+	if err := validate(in); err != nil {
+		return nil, err
+	}
+
+	it := toStorageItem(in)
+	it.Id, it.Oid = 0, [12]byte{} // идентификаторы присваивает БД
+	it.Lang = langdetect.Normalize(in.Lang)
+	ingest.Sanitize(it)
+	if it.PubDate == 0 {
+		it.PubDate = time.Now().Unix()
+	}
+	it.DetectLang("")
+
+	res, err := api.storage.AddItems(ctx, []storItem{*it})
+	if err != nil {
+		return nil, api.storageError(err)
+	}
+	if len(res.Inserted) == 0 {
+		return nil, status.Errorf(codes.AlreadyExists, "item %s already exists", it.Link)
+	}
+
+	if api.broker != nil {
+		api.broker.Publish(res.Inserted...)
+	}
+
+	return ofStorageItem(&res.Inserted[0]), nil
+}
+
+// UpdateItem меняет поля новости, пустые поля in
+// остаются без изменений
+func (api *API) UpdateItem(ctx context.Context, in *Item) (*Item, error) {
+	if in.Link == "" {
+		return nil, status.Error(codes.InvalidArgument, "link required")
+	}
+
+	db, err := api.editor()
+	if err != nil {
+		return nil, err
+	}
+
+	it, err := db.Item(ctx, in.Link)
+	if err != nil {
+		return nil, api.storageError(err)
+	}
+
+	if in.Title != "" {
+		it.Title = in.Title
+	}
+	if in.PubTime != 0 {
+		it.PubDate = in.PubTime
+	}
+	if in.Image != "" {
+		it.Image = in.Image
+	}
+	switch {
+	case in.Content != "":
+		// html и картинка строятся заново по новому описанию
+		it.Description = in.Content
+		ingest.Sanitize(&it)
+	case in.Image != "":
+		base, _ := url.Parse(it.Link)
+		it.Image, _ = sanitize.ImageURL(it.Image, base)
+	}
+	if in.Lang != "" {
+		if it.Lang = langdetect.Normalize(in.Lang); it.Lang == "" {
+			return nil, status.Error(codes.InvalidArgument, "invalid lang")
+		}
+	}
+
+	if err := db.UpdateItem(ctx, it); err != nil {
+		return nil, api.storageError(err)
+	}
+
+	return ofStorageItem(&it), nil
+}
+
+func (api *API) DeleteItem(ctx context.Context, in *DeleteItemRequest) (*emptypb.Empty, error) {
+	if in.Link == "" {
+		return nil, status.Error(codes.InvalidArgument, "link required")
+	}
+
+	db, err := api.editor()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.DeleteItem(ctx, in.Link); err != nil {
+		return nil, api.storageError(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// editor возвращает хранилище, если оно
+// умеет работать с отдельными новостями
+func (api *API) editor() (storage.Editor, error) {
+	db, ok := api.storage.(storage.Editor)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "item editing is not supported by storage")
+	}
+	return db, nil
+}
+
+// validate проверяет новость перед добавлением в БД
+func validate(in *Item) error {
+	if strings.TrimSpace(in.Title) == "" {
+		return status.Error(codes.InvalidArgument, "title required")
+	}
+	u, err := url.Parse(in.Link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return status.Error(codes.InvalidArgument, "link must be an absolute http(s) url")
+	}
+	if in.Lang != "" && langdetect.Normalize(in.Lang) == "" {
+		return status.Error(codes.InvalidArgument, "invalid lang")
+	}
+	return nil
+}
+
+func (api *API) Watch(in *WatchRequest, stream News_WatchServer) error {
+	if api.broker == nil {
+		return status.Error(codes.Unimplemented, "watch is not enabled")
+	}
+
+	sub, err := api.broker.Subscribe(in.Cursor, broker.Filter{Sources: in.Sources, Keywords: in.Keywords})
+	if err != nil {
+		return statusError(err)
+	}
+	defer sub.Close()
+
+	// курсор, с которого началась подписка, чтобы клиент мог
+	// продолжить, даже если не получит ни одной новости
+	if err := stream.Send(&WatchEvent{Cursor: sub.Cursor}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
 
-	items, err := api.storage.Items(ctx, int(in.Value))
+		case ev, ok := <-sub.C():
+			if !ok {
+				return statusError(sub.Err())
+			}
+			if err := stream.Send(&WatchEvent{Cursor: ev.Cursor, Item: ofStorageItem(&ev.Item)}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (api *API) Ingest(stream News_IngestServer) error {
+	if api.ingester == nil {
+		return status.Error(codes.Unimplemented, "ingest is not enabled")
+	}
+
+	var items []storItem
+	for {
+		in, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if len(items) == ingest.MaxItems {
+			return status.Errorf(codes.InvalidArgument, "too many items, max %d", ingest.MaxItems)
+		}
+		items = append(items, *toStorageItem(in))
+	}
+
+	results, err := api.ingester.Ingest(stream.Context(), items)
 	if err != nil {
-		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
-			return nil, err
+		return statusError(err)
+	}
+
+	out := &IngestResponse{Results: make([]*IngestResult, 0, len(results))}
+	for _, r := range results {
+		if r.Status == ingest.Accepted {
+			out.Accepted++
 		}
-		return nil, internalError
+		out.Results = append(out.Results, &IngestResult{
+			Link:   r.Link,
+			Status: ingestStatus[r.Status],
+			Reason: r.Reason,
+		})
 	}
 
-	return &Items{Items: ofStorageItems(items...)}, nil
+	return stream.SendAndClose(out)
+}
+
+var ingestStatus = map[ingest.Status]IngestStatus{
+	ingest.Accepted:  IngestStatus_ACCEPTED,
+	ingest.Duplicate: IngestStatus_DUPLICATE,
+	ingest.Invalid:   IngestStatus_INVALID,
+	ingest.Filtered:  IngestStatus_FILTERED,
 }
 
 func ofStorageItem(si *storItem) *Item {
@@ -57,6 +394,11 @@ func ofStorageItem(si *storItem) *Item {
 		PubTime: si.PubDate,
 		Content: si.Description,
 		Link:    si.Link,
+		Image:   si.Image,
+		Lang:    si.Lang,
+		Source:  si.Source,
+		Html:    si.HTML,
+		Article: si.Article,
 	}
 }
 
@@ -70,5 +412,8 @@ func toStorageItem(i *Item) *storItem {
 		PubDate:     i.PubTime,
 		Description: i.Content,
 		Link:        i.Link,
+		Image:       i.Image,
+		Lang:        i.Lang,
+		Source:      i.Source,
 	}
 }
@@ -5,6 +5,9 @@ import (
 	"io"
 	"log"
 	"net"
+	"news/pkg/broker"
+	"news/pkg/ingest"
+	"news/pkg/storage"
 	"news/pkg/storage/memdb"
 	"os"
 	"reflect"
@@ -12,8 +15,9 @@ import (
 	"time"
 
 	grpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
-	"google.golang.org/protobuf/types/known/wrapperspb"
+	"google.golang.org/grpc/status"
 )
 
 const port = ":50051"
@@ -43,7 +47,7 @@ func TestAPI_List(t *testing.T) {
 
 	wantLen := 10
 
-	items, err := api.List(context.Background(), &wrapperspb.Int64Value{Value: int64(wantLen)})
+	items, err := api.List(context.Background(), &ListRequest{Limit: int64(wantLen)})
 	if err != nil {
 		t.Fatalf("API_List() error = %v", err)
 	}
@@ -74,7 +78,7 @@ func TestAPI(t *testing.T) {
 
 	wantLen := 10
 
-	items, err := client.List(ctx, &wrapperspb.Int64Value{Value: int64(wantLen)})
+	items, err := client.List(ctx, &ListRequest{Limit: int64(wantLen)})
 	if err != nil {
 		t.Fatalf("API error = %v", err)
 	}
@@ -83,3 +87,394 @@ func TestAPI(t *testing.T) {
 		t.Errorf("API got items = %d, want = %d", len(items.Items), wantLen)
 	}
 }
+
+func TestAPI_List_lang(t *testing.T) {
+
+	api := New(memdb.New(), log.New(io.Discard, "", 0))
+
+	tests := []struct {
+		name     string
+		lang     string
+		wantLen  int
+		wantCode codes.Code
+	}{
+		{"язык совпадает", "en", 5, codes.OK},
+		{"язык не совпадает", "ru", 0, codes.OK},
+		{"некорректный язык", "русский", 0, codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, err := api.List(context.Background(), &ListRequest{Limit: 5, Lang: tt.lang})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("API_List() got code = %v, want = %v", code, tt.wantCode)
+			}
+			if err != nil {
+				return
+			}
+			if len(items.Items) != tt.wantLen {
+				t.Errorf("API_List() got items = %d, want = %d", len(items.Items), tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestAPI_Watch(t *testing.T) {
+
+	b := broker.New(10)
+	api := New(memdb.New(), log.New(io.Discard, "", 0)).Broker(b)
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	srv := NewServer(api)
+	go srv.Serve(lis)
+	defer srv.Shutdown(context.Background())
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc.Dial() error = %v", err)
+	}
+	defer conn.Close()
+	client := NewNewsClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	stream, err := client.Watch(ctx, &WatchRequest{Sources: []string{"https://test.com/rss"}})
+	if err != nil {
+		t.Fatalf("News.Watch() error = %v", err)
+	}
+
+	first, err := stream.Recv()
+	if err != nil || first.Item != nil || first.Cursor != b.Cursor() {
+		t.Fatalf("News.Watch() got first = %v, error = %v", first, err)
+	}
+
+	b.Publish(
+		storItem{Title: "1", Source: "https://other.com/rss"},
+		storItem{Title: "2", Source: "https://test.com/rss"},
+		storItem{Title: "3", Source: "https://test.com/rss"})
+
+	ev, err := stream.Recv()
+	if err != nil || ev.Item.GetTitle() != "2" {
+		t.Fatalf("News.Watch() got = %v, error = %v", ev, err)
+	}
+
+	// обрыв соединения: продолжаем с курсора полученной новости
+	resumed, err := client.Watch(ctx, &WatchRequest{Cursor: ev.Cursor})
+	if err != nil {
+		t.Fatalf("News.Watch() error = %v", err)
+	}
+	if _, err := resumed.Recv(); err != nil {
+		t.Fatalf("News.Watch() error = %v", err)
+	}
+	if ev, err := resumed.Recv(); err != nil || ev.Item.GetTitle() != "3" {
+		t.Fatalf("News.Watch() got = %v, error = %v", ev, err)
+	}
+
+	t.Run("ошибки", func(t *testing.T) {
+		tests := []struct {
+			cursor string
+			close  bool
+			want   codes.Code
+		}{
+			{"bad", false, codes.InvalidArgument},
+			{"1-1", false, codes.OutOfRange},
+			{"", true, codes.Unavailable},
+		}
+
+		for _, tt := range tests {
+			if tt.close {
+				b.Close()
+			}
+			s, err := client.Watch(ctx, &WatchRequest{Cursor: tt.cursor})
+			if err == nil {
+				_, err = s.Recv()
+			}
+			if status.Code(err) != tt.want {
+				t.Errorf("News.Watch(%q) got code = %v, want = %v", tt.cursor, status.Code(err), tt.want)
+			}
+		}
+	})
+}
+
+func TestAPI_List_pages(t *testing.T) {
+
+	api := New(memdb.New(), log.New(io.Discard, "", 0))
+
+	first, err := api.List(context.Background(), &ListRequest{Limit: 3})
+	if err != nil {
+		t.Fatalf("API_List() error = %v", err)
+	}
+	if first.NextPageToken == "" {
+		t.Fatalf("API_List() got empty next page token")
+	}
+	if off, err := parsePageToken(first.NextPageToken); err != nil || off != 3 {
+		t.Errorf("API_List() got next page offset = %d, error = %v, want = %d", off, err, 3)
+	}
+
+	second, err := api.List(context.Background(), &ListRequest{Limit: 3, PageToken: first.NextPageToken})
+	if err != nil {
+		t.Fatalf("API_List() error = %v", err)
+	}
+	if off, _ := parsePageToken(second.NextPageToken); off != 6 {
+		t.Errorf("API_List() got next page offset = %d, want = %d", off, 6)
+	}
+
+	if items, err := api.List(context.Background(), &ListRequest{}); err != nil || len(items.Items) != defaultLimit {
+		t.Errorf("API_List() got items = %d, error = %v, want = %d", len(items.GetItems()), err, defaultLimit)
+	}
+
+	for _, in := range []*ListRequest{{Limit: -1}, {PageToken: "!"}, {PageToken: pageToken(-5)}} {
+		if _, err := api.List(context.Background(), in); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("API_List(%v) got code = %v, want = %v", in, status.Code(err), codes.InvalidArgument)
+		}
+	}
+}
+
+func TestAPI_Search(t *testing.T) {
+
+	api := New(memdb.New(), log.New(io.Discard, "", 0))
+
+	tests := []struct {
+		name     string
+		query    string
+		wantLen  int
+		wantCode codes.Code
+	}{
+		{"совпадение", "SAMPLE", 5, codes.OK},
+		{"нет совпадений", "nothing", 0, codes.OK},
+		{"пустой запрос", " ", 0, codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, err := api.Search(context.Background(), &SearchRequest{Query: tt.query, Limit: 5})
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("API_Search() got code = %v, want = %v", status.Code(err), tt.wantCode)
+			}
+			if len(items.GetItems()) != tt.wantLen {
+				t.Errorf("API_Search() got items = %d, want = %d", len(items.GetItems()), tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestAPI_GetItem(t *testing.T) {
+
+	api := New(memdb.New(), log.New(io.Discard, "", 0))
+
+	tests := []struct {
+		name     string
+		in       *GetItemRequest
+		wantCode codes.Code
+	}{
+		{"по id", &GetItemRequest{Key: &GetItemRequest_Id{Id: memdb.SampleItem.Id}}, codes.OK},
+		{"по oid", &GetItemRequest{Key: &GetItemRequest_Oid{Oid: memdb.SampleItem.Oid[:]}}, codes.OK},
+		{"по ссылке", &GetItemRequest{Key: &GetItemRequest_Link{Link: memdb.SampleItem.Link}}, codes.OK},
+		{"нет новости", &GetItemRequest{Key: &GetItemRequest_Id{Id: 100}}, codes.NotFound},
+		{"нет новости по ссылке", &GetItemRequest{Key: &GetItemRequest_Link{Link: "https://nothing.com"}}, codes.NotFound},
+		{"некорректный oid", &GetItemRequest{Key: &GetItemRequest_Oid{Oid: []byte{1}}}, codes.InvalidArgument},
+		{"без ключа", &GetItemRequest{}, codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := api.GetItem(context.Background(), tt.in)
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("API_GetItem() got code = %v, want = %v", status.Code(err), tt.wantCode)
+			}
+			if err == nil && !reflect.DeepEqual(got, ofStorageItem(&memdb.SampleItem)) {
+				t.Errorf("API_GetItem() got = %v, want = %v", got, ofStorageItem(&memdb.SampleItem))
+			}
+		})
+	}
+}
+
+func TestAPI_admin(t *testing.T) {
+
+	b := broker.New(10)
+	api := New(memdb.New(), log.New(io.Discard, "", 0)).Broker(b)
+	ctx := context.Background()
+
+	t.Run("CreateItem", func(t *testing.T) {
+		sub, err := b.Subscribe("", broker.Filter{})
+		if err != nil {
+			t.Fatalf("Broker.Subscribe() error = %v", err)
+		}
+		defer sub.Close()
+
+		in := &Item{Id: 7, Title: "Новая новость про Go", Content: "Новость добавлена вручную через админку", Link: "https://test.com/new"}
+		got, err := api.CreateItem(ctx, in)
+		if err != nil {
+			t.Fatalf("API_CreateItem() error = %v", err)
+		}
+		if got.Id != 0 || got.PubTime == 0 || got.Lang != "ru" || got.Link != in.Link {
+			t.Errorf("API_CreateItem() got = %v", got)
+		}
+		if ev := <-sub.C(); ev.Item.Link != in.Link {
+			t.Errorf("API_CreateItem() published = %v, want = %s", ev.Item, in.Link)
+		}
+
+		// описание разбирается так же, как у принятых новостей
+		in = &Item{Title: "Go", Lang: "EN", Link: "https://test.com/html",
+			Content: `<p>Text <img src="/a.png"><script>alert(1)</script></p>`}
+		got, err = api.CreateItem(ctx, in)
+		if err != nil {
+			t.Fatalf("API_CreateItem() error = %v", err)
+		}
+		if got.Lang != "en" || got.Content != "Text" || got.Image != "https://test.com/a.png" {
+			t.Errorf("API_CreateItem() got = %v", got)
+		}
+		if ev := <-sub.C(); ev.Item.HTML != `<p>Text <img src="https://test.com/a.png"></p>` || got.Html != ev.Item.HTML {
+			t.Errorf("API_CreateItem() got html = %q, published html = %q", got.Html, ev.Item.HTML)
+		}
+
+		for _, in := range []*Item{
+			{Title: "t", Link: "/relative"},
+			{Title: "t", Link: "ftp://test.com"},
+			{Link: "https://test.com/new"},
+			{Title: "t", Link: "https://test.com/new", Lang: "русский"},
+		} {
+			if _, err := api.CreateItem(ctx, in); status.Code(err) != codes.InvalidArgument {
+				t.Errorf("API_CreateItem(%v) got code = %v, want = %v", in, status.Code(err), codes.InvalidArgument)
+			}
+		}
+	})
+
+	t.Run("UpdateItem", func(t *testing.T) {
+		got, err := api.UpdateItem(ctx, &Item{Link: memdb.SampleItem.Link, Title: "upd title"})
+		if err != nil {
+			t.Fatalf("API_UpdateItem() error = %v", err)
+		}
+
+		want := ofStorageItem(&memdb.SampleItem)
+		want.Title = "upd title"
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("API_UpdateItem() got = %v, want = %v", got, want)
+		}
+
+		db := &updated{MemDB: memdb.New()}
+		got, err = New(db, log.New(io.Discard, "", 0)).UpdateItem(ctx,
+			&Item{Link: memdb.SampleItem.Link, Content: `<b>new</b> <a href="/a" onclick="x()">text</a>`})
+		if err != nil {
+			t.Fatalf("API_UpdateItem() error = %v", err)
+		}
+		if got.Content != "new text" || db.item.HTML != `<b>new</b> <a href="https://test.com/a" rel="nofollow noopener noreferrer" target="_blank">text</a>` ||
+			db.item.Image != memdb.SampleItem.Image {
+			t.Errorf("API_UpdateItem() got = %v, html = %q", got, db.item.HTML)
+		}
+
+		if _, err := api.UpdateItem(ctx, &Item{Link: "https://nothing.com", Title: "t"}); status.Code(err) != codes.NotFound {
+			t.Errorf("API_UpdateItem() got code = %v, want = %v", status.Code(err), codes.NotFound)
+		}
+	})
+
+	t.Run("DeleteItem", func(t *testing.T) {
+		if _, err := api.DeleteItem(ctx, &DeleteItemRequest{Link: memdb.SampleItem.Link}); err != nil {
+			t.Fatalf("API_DeleteItem() error = %v", err)
+		}
+		if _, err := api.DeleteItem(ctx, &DeleteItemRequest{Link: "https://nothing.com"}); status.Code(err) != codes.NotFound {
+			t.Errorf("API_DeleteItem() got code = %v, want = %v", status.Code(err), codes.NotFound)
+		}
+		if _, err := api.DeleteItem(ctx, &DeleteItemRequest{}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("API_DeleteItem() got code = %v, want = %v", status.Code(err), codes.InvalidArgument)
+		}
+	})
+}
+
+func TestAPI_Ingest(t *testing.T) {
+
+	logger := log.New(io.Discard, "", 0)
+	in := ingest.New(logger, memdb.New())
+	api := New(memdb.New(), logger).Ingester(in)
+
+	src := make(chan storage.ItemContainer)
+	written := in.Join(src)
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	srv := NewServer(api)
+	go srv.Serve(lis)
+	defer srv.Shutdown(context.Background())
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc.Dial() error = %v", err)
+	}
+	defer conn.Close()
+	client := NewNewsClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	stream, err := client.Ingest(ctx)
+	if err != nil {
+		t.Fatalf("News.Ingest() error = %v", err)
+	}
+	for _, it := range []*Item{
+		{Title: "Новость", Link: "https://news.com/1"},
+		{Title: "Новость", Link: memdb.SampleItem.Link},
+		{Title: "", Link: "https://news.com/2"},
+	} {
+		if err := stream.Send(it); err != nil {
+			t.Fatalf("News.Ingest() send error = %v", err)
+		}
+	}
+
+	got := make(chan storage.ItemContainer, 1)
+	go func() { got <- <-written }()
+
+	res, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatalf("News.Ingest() error = %v", err)
+	}
+
+	want := []IngestStatus{IngestStatus_ACCEPTED, IngestStatus_DUPLICATE, IngestStatus_INVALID}
+	if res.Accepted != 1 || len(res.Results) != len(want) {
+		t.Fatalf("News.Ingest() got = %v", res)
+	}
+	for i := range want {
+		if res.Results[i].Status != want[i] {
+			t.Errorf("News.Ingest() item #%d got = %v, want = %v", i, res.Results[i].Status, want[i])
+		}
+	}
+	if c := <-got; len(c.Items) != 1 || c.Items[0].Link != "https://news.com/1" {
+		t.Errorf("News.Ingest() written = %v", c.Items)
+	}
+
+	// запись в БД остановлена
+	close(src)
+	for range written {
+	}
+
+	stream, err = client.Ingest(ctx)
+	if err != nil {
+		t.Fatalf("News.Ingest() error = %v", err)
+	}
+	if err := stream.Send(&Item{Title: "Новость", Link: "https://news.com/3"}); err != nil {
+		t.Fatalf("News.Ingest() send error = %v", err)
+	}
+	if _, err := stream.CloseAndRecv(); status.Code(err) != codes.Unavailable {
+		t.Errorf("News.Ingest() got code = %v, want = %v", status.Code(err), codes.Unavailable)
+	}
+
+	if err := New(memdb.New(), logger).Ingest(nil); status.Code(err) != codes.Unimplemented {
+		t.Errorf("API.Ingest() got code = %v, want = %v", status.Code(err), codes.Unimplemented)
+	}
+}
+
+// updated запоминает новость, переданную в UpdateItem
+type updated struct {
+	*memdb.MemDB
+	item storItem
+}
+
+func (db *updated) UpdateItem(ctx context.Context, it storItem) error {
+	db.item = it
+	return db.MemDB.UpdateItem(ctx, it)
+}
@@ -0,0 +1,236 @@
+package grpc
+
+import (
+	context "context"
+	"crypto/subtle"
+	"encoding/json"
+	"expvar"
+	"log"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	status "google.golang.org/grpc/status"
+)
+
+// Metrics - метрики gRPC-сервера. Реализует expvar.Var,
+// поэтому публикуется через expvar.Publish
+type Metrics struct {
+	requests expvar.Map // запросы по методам
+	byCode   expvar.Map // ответы по кодам статуса
+	latency  expvar.Map // суммарное время обработки по методам, мс
+	inFlight expvar.Int // запросы в обработке
+	panics   expvar.Int // перехваченные паники
+}
+
+// NewMetrics возвращает новый объект *Metrics
+func NewMetrics() *Metrics {
+	m := &Metrics{}
+	m.requests.Init()
+	m.byCode.Init()
+	m.latency.Init()
+	return m
+}
+
+// String возвращает метрики в json
+func (m *Metrics) String() string {
+	b, _ := json.Marshal(map[string]json.RawMessage{
+		"requests":   json.RawMessage(m.requests.String()),
+		"codes":      json.RawMessage(m.byCode.String()),
+		"latency_ms": json.RawMessage(m.latency.String()),
+		"in_flight":  json.RawMessage(m.inFlight.String()),
+		"panics":     json.RawMessage(m.panics.String()),
+	})
+	return string(b)
+}
+
+func (m *Metrics) record(method string, code codes.Code, d time.Duration) {
+	m.requests.Add(method, 1)
+	m.byCode.Add(code.String(), 1)
+	m.latency.AddFloat(method, float64(d)/float64(time.Millisecond))
+}
+
+// adminMethods - методы сервиса News, которые изменяют
+// сохранённые новости, для них нужен токен администратора
+var adminMethods = map[string]bool{"CreateItem": true, "UpdateItem": true, "DeleteItem": true}
+
+// restrictedMethods - методы сервиса News, которые без API-токенов
+// отклоняются, как POST /news: Ingest записывает новости в БД,
+// Watch держит поток открытым сколько угодно долго
+var restrictedMethods = map[string]bool{"Ingest": true, "Watch": true}
+
+// Interceptors - перехватчики запросов gRPC-сервера: логгирование,
+// метрики, проверка API-токена, перехват паник и перевод ошибок
+// в статусы gRPC. Проверка здоровья и рефлексия токена не требуют
+type Interceptors struct {
+	log *log.Logger
+	// метрики сервера, по-умолчанию nil
+	metrics *Metrics
+	// API-токены, если пусто - токен проверяется только
+	// у методов restrictedMethods, и они отклоняются
+	tokens [][]byte
+	// токены администратора для методов adminMethods,
+	// если пусто - эти методы отклоняются
+	admin [][]byte
+}
+
+// NewInterceptors возвращает новый объект *Interceptors
+func NewInterceptors(log *log.Logger) *Interceptors {
+	return &Interceptors{log: log}
+}
+
+// Metrics устанавливает, куда записывать метрики запросов
+func (ic *Interceptors) Metrics(m *Metrics) *Interceptors {
+	ic.metrics = m
+	return ic
+}
+
+// Tokens устанавливает API-токены. Клиент передаёт токен в
+// метаданных "authorization: Bearer <токен>". Пустые строки
+// пропускаются, без токенов проверяются только методы Ingest
+// и Watch, и они отклоняются
+func (ic *Interceptors) Tokens(tokens ...string) *Interceptors {
+	ic.tokens = appendTokens(ic.tokens, tokens)
+	return ic
+}
+
+// AdminTokens устанавливает токены администратора для методов
+// CreateItem, UpdateItem и DeleteItem, они передаются так же,
+// как API-токены. Без токенов эти методы отклоняются
+func (ic *Interceptors) AdminTokens(tokens ...string) *Interceptors {
+	ic.admin = appendTokens(ic.admin, tokens)
+	return ic
+}
+
+func appendTokens(list [][]byte, tokens []string) [][]byte {
+	for _, t := range tokens {
+		if t = strings.TrimSpace(t); t != "" {
+			list = append(list, []byte(t))
+		}
+	}
+	return list
+}
+
+// ServerOptions возвращает опции для NewServer
+func (ic *Interceptors) ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(ic.unary),
+		grpc.ChainStreamInterceptor(ic.stream),
+	}
+}
+
+func (ic *Interceptors) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	ic.begin()
+	defer ic.observe(ctx, info.FullMethod, time.Now(), &err)
+	defer ic.recoverPanic(info.FullMethod, &err)
+
+	if err := ic.authorize(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+
+	resp, err = handler(ctx, req)
+	return resp, ic.toStatus(info.FullMethod, err)
+}
+
+func (ic *Interceptors) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	ic.begin()
+	defer ic.observe(ss.Context(), info.FullMethod, time.Now(), &err)
+	defer ic.recoverPanic(info.FullMethod, &err)
+
+	if err := ic.authorize(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+
+	return ic.toStatus(info.FullMethod, handler(srv, ss))
+}
+
+// begin отмечает начало обработки запроса
+func (ic *Interceptors) begin() {
+	if ic.metrics != nil {
+		ic.metrics.inFlight.Add(1)
+	}
+}
+
+// observe логгирует запрос и записывает метрики,
+// вызывается отложенно после обработки запроса
+func (ic *Interceptors) observe(ctx context.Context, method string, start time.Time, err *error) {
+	d := time.Since(start)
+	code := status.Code(*err)
+
+	if ic.metrics != nil {
+		ic.metrics.inFlight.Add(-1)
+		ic.metrics.record(method, code, d)
+	}
+
+	addr := "unknown"
+	if p, ok := peer.FromContext(ctx); ok {
+		addr = p.Addr.String()
+	}
+	agent := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		agent = strings.Join(md.Get("user-agent"), " ")
+	}
+
+	ic.log.Printf("[INFO] method=%s, peer=%s, user-agent=%q, code=%s, duration=%s", method, addr, agent, code, d)
+}
+
+// recoverPanic перехватывает панику обработчика и отвечает
+// клиенту статусом Internal, вызывается отложенно
+func (ic *Interceptors) recoverPanic(method string, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	if ic.metrics != nil {
+		ic.metrics.panics.Add(1)
+	}
+	ic.log.Printf("[ERROR] method=%s, panic=%v\n%s", method, r, debug.Stack())
+	*err = internalError
+}
+
+// authorize проверяет токен запроса к сервису News: токен
+// администратора для adminMethods, API-токен для остальных
+func (ic *Interceptors) authorize(ctx context.Context, method string) error {
+	name := strings.TrimPrefix(method, "/"+News_ServiceDesc.ServiceName+"/")
+	if name == method {
+		return nil
+	}
+
+	tokens := ic.tokens
+	switch {
+	case adminMethods[name]:
+		tokens = ic.admin
+	case len(tokens) == 0 && !restrictedMethods[name]:
+		return nil
+	}
+	if len(tokens) == 0 {
+		return status.Error(codes.PermissionDenied, "api tokens are not configured")
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		token := []byte(strings.TrimSpace(strings.TrimPrefix(v, "Bearer ")))
+		for _, t := range tokens {
+			if subtle.ConstantTimeCompare(token, t) == 1 {
+				return nil
+			}
+		}
+	}
+
+	return status.Error(codes.Unauthenticated, "invalid or missing api token")
+}
+
+// toStatus переводит ошибку обработчика в статус gRPC,
+// подробности внутренних ошибок только логгируются
+func (ic *Interceptors) toStatus(method string, err error) error {
+	st := statusError(err)
+	if st == internalError && err != internalError {
+		ic.log.Printf("[ERROR] method=%s, error=%v", method, err)
+	}
+	return st
+}
@@ -0,0 +1,209 @@
+package grpc
+
+import (
+	"bytes"
+	context "context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"news/pkg/broker"
+	"news/pkg/storage"
+	"news/pkg/storage/memdb"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	status "google.golang.org/grpc/status"
+)
+
+// panicDB паникует при чтении новостей
+type panicDB struct {
+	*memdb.MemDB
+}
+
+func (panicDB) Items(context.Context, int) ([]storage.Item, error) {
+	panic("boom")
+}
+
+// syncBuffer - буфер для логгера, который пишут из разных горутин
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestInterceptors(t *testing.T) {
+
+	var logs syncBuffer
+	logger := log.New(&logs, "", 0)
+	metrics := NewMetrics()
+	ic := NewInterceptors(logger).Metrics(metrics).Tokens("secret", " ")
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	srv := NewServer(New(panicDB{memdb.New()}, logger), ic.ServerOptions()...)
+	go srv.Serve(lis)
+	defer srv.Shutdown(context.Background())
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc.Dial() error = %v", err)
+	}
+	defer conn.Close()
+	client := NewNewsClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	authed := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret")
+
+	t.Run("токен", func(t *testing.T) {
+		tests := []struct {
+			name string
+			ctx  context.Context
+			want codes.Code
+		}{
+			{"без токена", ctx, codes.Unauthenticated},
+			{"неверный токен", metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer wrong"), codes.Unauthenticated},
+			{"верный токен", authed, codes.OK},
+		}
+
+		for _, tt := range tests {
+			_, err := client.GetItem(tt.ctx, &GetItemRequest{Key: &GetItemRequest_Link{Link: memdb.SampleItem.Link}})
+			if status.Code(err) != tt.want {
+				t.Errorf("News.GetItem() %s got code = %v, want = %v", tt.name, status.Code(err), tt.want)
+			}
+		}
+
+		// проверка здоровья доступна без токена
+		if _, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+			t.Errorf("Health.Check() error = %v", err)
+		}
+
+		// потоковые методы тоже требуют токен
+		s, err := client.Watch(ctx, &WatchRequest{})
+		if err == nil {
+			_, err = s.Recv()
+		}
+		if status.Code(err) != codes.Unauthenticated {
+			t.Errorf("News.Watch() got code = %v, want = %v", status.Code(err), codes.Unauthenticated)
+		}
+	})
+
+	t.Run("токен_администратора", func(t *testing.T) {
+		admin := NewInterceptors(logger).Tokens("secret").AdminTokens("admin")
+		none := NewInterceptors(logger)
+
+		tests := []struct {
+			name   string
+			ic     *Interceptors
+			method string
+			token  string
+			want   codes.Code
+		}{
+			{"изменение с API-токеном", admin, "/newsgrpc.News/DeleteItem", "secret", codes.Unauthenticated},
+			{"изменение с токеном администратора", admin, "/newsgrpc.News/DeleteItem", "admin", codes.OK},
+			{"изменение без токенов", none, "/newsgrpc.News/CreateItem", "", codes.PermissionDenied},
+			{"приём без токенов", none, "/newsgrpc.News/Ingest", "", codes.PermissionDenied},
+			{"поток без токенов", none, "/newsgrpc.News/Watch", "", codes.PermissionDenied},
+			{"чтение без токенов", none, "/newsgrpc.News/List", "", codes.OK},
+			{"проверка здоровья", none, "/grpc.health.v1.Health/Check", "", codes.OK},
+		}
+
+		for _, tt := range tests {
+			ctx := context.Background()
+			if tt.token != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+tt.token))
+			}
+			if got := status.Code(tt.ic.authorize(ctx, tt.method)); got != tt.want {
+				t.Errorf("Interceptors.authorize() %s got = %v, want = %v", tt.name, got, tt.want)
+			}
+		}
+	})
+
+	t.Run("паника", func(t *testing.T) {
+		_, err := client.List(authed, &ListRequest{})
+		if status.Code(err) != codes.Internal {
+			t.Fatalf("News.List() got code = %v, want = %v", status.Code(err), codes.Internal)
+		}
+		if !strings.Contains(logs.String(), "panic=boom") {
+			t.Errorf("Interceptors got logs = %s, want panic", logs.String())
+		}
+
+		// сервер продолжает работать
+		if _, err := client.GetItem(authed, &GetItemRequest{Key: &GetItemRequest_Link{Link: memdb.SampleItem.Link}}); err != nil {
+			t.Errorf("News.GetItem() error = %v", err)
+		}
+	})
+
+	t.Run("метрики", func(t *testing.T) {
+		var got struct {
+			Requests map[string]int `json:"requests"`
+			Codes    map[string]int `json:"codes"`
+			InFlight int            `json:"in_flight"`
+			Panics   int            `json:"panics"`
+		}
+		if err := json.Unmarshal([]byte(metrics.String()), &got); err != nil {
+			t.Fatalf("Metrics.String() error = %v", err)
+		}
+
+		if got.Requests["/newsgrpc.News/GetItem"] != 4 || got.Codes["Unauthenticated"] != 3 ||
+			got.Panics != 1 || got.InFlight != 0 {
+			t.Errorf("Metrics.String() got = %s", metrics.String())
+		}
+	})
+}
+
+func TestInterceptors_toStatus(t *testing.T) {
+
+	var logs bytes.Buffer
+	ic := NewInterceptors(log.New(&logs, "", 0))
+
+	tests := []struct {
+		err  error
+		want codes.Code
+	}{
+		{nil, codes.OK},
+		{fmt.Errorf("item: %w", storage.ErrNotFound), codes.NotFound},
+		{storage.ErrInvalidLimit, codes.InvalidArgument},
+		{context.DeadlineExceeded, codes.DeadlineExceeded},
+		{context.Canceled, codes.Canceled},
+		{broker.ErrCursorExpired, codes.OutOfRange},
+		{status.Error(codes.AlreadyExists, "exists"), codes.AlreadyExists},
+		{errors.New("connection refused"), codes.Internal},
+	}
+
+	for _, tt := range tests {
+		if got := ic.toStatus("/test", tt.err); status.Code(got) != tt.want {
+			t.Errorf("Interceptors.toStatus(%v) got code = %v, want = %v", tt.err, status.Code(got), tt.want)
+		}
+	}
+
+	// подробности внутренней ошибки только в логе
+	if got := ic.toStatus("/test", errors.New("connection refused")); strings.Contains(got.Error(), "refused") {
+		t.Errorf("Interceptors.toStatus() got = %v", got)
+	}
+	if !strings.Contains(logs.String(), "connection refused") {
+		t.Errorf("Interceptors.toStatus() got logs = %s", logs.String())
+	}
+}
@@ -11,7 +11,7 @@ import (
 	grpc "google.golang.org/grpc"
 	codes "google.golang.org/grpc/codes"
 	status "google.golang.org/grpc/status"
-	wrapperspb "google.golang.org/protobuf/types/known/wrapperspb"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
 )
 
 // This is a compile-time assertion to ensure that this generated file
@@ -23,7 +23,25 @@ const _ = grpc.SupportPackageIsVersion7
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type NewsClient interface {
-	List(ctx context.Context, in *wrapperspb.Int64Value, opts ...grpc.CallOption) (*Items, error)
+	// List возвращает последние новости постранично
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*Items, error)
+	// Search ищет новости по подстроке заголовка или описания
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*Items, error)
+	// GetItem возвращает новость по идентификатору или ссылке
+	GetItem(ctx context.Context, in *GetItemRequest, opts ...grpc.CallOption) (*Item, error)
+	// CreateItem, UpdateItem и DeleteItem - администрирование,
+	// новость определяется ссылкой
+	CreateItem(ctx context.Context, in *Item, opts ...grpc.CallOption) (*Item, error)
+	UpdateItem(ctx context.Context, in *Item, opts ...grpc.CallOption) (*Item, error)
+	DeleteItem(ctx context.Context, in *DeleteItemRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// Watch присылает новости по мере записи в БД. Первое
+	// сообщение потока содержит только курсор, с которого
+	// началась подписка
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (News_WatchClient, error)
+	// Ingest принимает новости от внешних систем. Новости проходят
+	// те же проверки и обработку, что и новости rss-лент, ответ
+	// содержит итог по каждой новости в порядке получения
+	Ingest(ctx context.Context, opts ...grpc.CallOption) (News_IngestClient, error)
 }
 
 type newsClient struct {
@@ -34,7 +52,7 @@ func NewNewsClient(cc grpc.ClientConnInterface) NewsClient {
 	return &newsClient{cc}
 }
 
-func (c *newsClient) List(ctx context.Context, in *wrapperspb.Int64Value, opts ...grpc.CallOption) (*Items, error) {
+func (c *newsClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*Items, error) {
 	out := new(Items)
 	err := c.cc.Invoke(ctx, "/newsgrpc.News/List", in, out, opts...)
 	if err != nil {
@@ -43,11 +61,140 @@ func (c *newsClient) List(ctx context.Context, in *wrapperspb.Int64Value, opts .
 	return out, nil
 }
 
+func (c *newsClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*Items, error) {
+	out := new(Items)
+	err := c.cc.Invoke(ctx, "/newsgrpc.News/Search", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *newsClient) GetItem(ctx context.Context, in *GetItemRequest, opts ...grpc.CallOption) (*Item, error) {
+	out := new(Item)
+	err := c.cc.Invoke(ctx, "/newsgrpc.News/GetItem", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *newsClient) CreateItem(ctx context.Context, in *Item, opts ...grpc.CallOption) (*Item, error) {
+	out := new(Item)
+	err := c.cc.Invoke(ctx, "/newsgrpc.News/CreateItem", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *newsClient) UpdateItem(ctx context.Context, in *Item, opts ...grpc.CallOption) (*Item, error) {
+	out := new(Item)
+	err := c.cc.Invoke(ctx, "/newsgrpc.News/UpdateItem", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *newsClient) DeleteItem(ctx context.Context, in *DeleteItemRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/newsgrpc.News/DeleteItem", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *newsClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (News_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &News_ServiceDesc.Streams[0], "/newsgrpc.News/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &newsWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type News_WatchClient interface {
+	Recv() (*WatchEvent, error)
+	grpc.ClientStream
+}
+
+type newsWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *newsWatchClient) Recv() (*WatchEvent, error) {
+	m := new(WatchEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *newsClient) Ingest(ctx context.Context, opts ...grpc.CallOption) (News_IngestClient, error) {
+	stream, err := c.cc.NewStream(ctx, &News_ServiceDesc.Streams[1], "/newsgrpc.News/Ingest", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &newsIngestClient{stream}
+	return x, nil
+}
+
+type News_IngestClient interface {
+	Send(*Item) error
+	CloseAndRecv() (*IngestResponse, error)
+	grpc.ClientStream
+}
+
+type newsIngestClient struct {
+	grpc.ClientStream
+}
+
+func (x *newsIngestClient) Send(m *Item) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *newsIngestClient) CloseAndRecv() (*IngestResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(IngestResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // NewsServer is the server API for News service.
 // All implementations must embed UnimplementedNewsServer
 // for forward compatibility
 type NewsServer interface {
-	List(context.Context, *wrapperspb.Int64Value) (*Items, error)
+	// List возвращает последние новости постранично
+	List(context.Context, *ListRequest) (*Items, error)
+	// Search ищет новости по подстроке заголовка или описания
+	Search(context.Context, *SearchRequest) (*Items, error)
+	// GetItem возвращает новость по идентификатору или ссылке
+	GetItem(context.Context, *GetItemRequest) (*Item, error)
+	// CreateItem, UpdateItem и DeleteItem - администрирование,
+	// новость определяется ссылкой
+	CreateItem(context.Context, *Item) (*Item, error)
+	UpdateItem(context.Context, *Item) (*Item, error)
+	DeleteItem(context.Context, *DeleteItemRequest) (*emptypb.Empty, error)
+	// Watch присылает новости по мере записи в БД. Первое
+	// сообщение потока содержит только курсор, с которого
+	// началась подписка
+	Watch(*WatchRequest, News_WatchServer) error
+	// Ingest принимает новости от внешних систем. Новости проходят
+	// те же проверки и обработку, что и новости rss-лент, ответ
+	// содержит итог по каждой новости в порядке получения
+	Ingest(News_IngestServer) error
 	mustEmbedUnimplementedNewsServer()
 }
 
@@ -55,9 +202,30 @@ type NewsServer interface {
 type UnimplementedNewsServer struct {
 }
 
-func (UnimplementedNewsServer) List(context.Context, *wrapperspb.Int64Value) (*Items, error) {
+func (UnimplementedNewsServer) List(context.Context, *ListRequest) (*Items, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
 }
+func (UnimplementedNewsServer) Search(context.Context, *SearchRequest) (*Items, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedNewsServer) GetItem(context.Context, *GetItemRequest) (*Item, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetItem not implemented")
+}
+func (UnimplementedNewsServer) CreateItem(context.Context, *Item) (*Item, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateItem not implemented")
+}
+func (UnimplementedNewsServer) UpdateItem(context.Context, *Item) (*Item, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateItem not implemented")
+}
+func (UnimplementedNewsServer) DeleteItem(context.Context, *DeleteItemRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteItem not implemented")
+}
+func (UnimplementedNewsServer) Watch(*WatchRequest, News_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedNewsServer) Ingest(News_IngestServer) error {
+	return status.Errorf(codes.Unimplemented, "method Ingest not implemented")
+}
 func (UnimplementedNewsServer) mustEmbedUnimplementedNewsServer() {}
 
 // UnsafeNewsServer may be embedded to opt out of forward compatibility for this service.
@@ -72,7 +240,7 @@ func RegisterNewsServer(s grpc.ServiceRegistrar, srv NewsServer) {
 }
 
 func _News_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(wrapperspb.Int64Value)
+	in := new(ListRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
@@ -84,11 +252,148 @@ func _News_List_Handler(srv interface{}, ctx context.Context, dec func(interface
 		FullMethod: "/newsgrpc.News/List",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(NewsServer).List(ctx, req.(*wrapperspb.Int64Value))
+		return srv.(NewsServer).List(ctx, req.(*ListRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
+func _News_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NewsServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/newsgrpc.News/Search",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NewsServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _News_GetItem_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetItemRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NewsServer).GetItem(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/newsgrpc.News/GetItem",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NewsServer).GetItem(ctx, req.(*GetItemRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _News_CreateItem_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Item)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NewsServer).CreateItem(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/newsgrpc.News/CreateItem",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NewsServer).CreateItem(ctx, req.(*Item))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _News_UpdateItem_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Item)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NewsServer).UpdateItem(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/newsgrpc.News/UpdateItem",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NewsServer).UpdateItem(ctx, req.(*Item))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _News_DeleteItem_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteItemRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NewsServer).DeleteItem(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/newsgrpc.News/DeleteItem",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NewsServer).DeleteItem(ctx, req.(*DeleteItemRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _News_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(NewsServer).Watch(m, &newsWatchServer{stream})
+}
+
+type News_WatchServer interface {
+	Send(*WatchEvent) error
+	grpc.ServerStream
+}
+
+type newsWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *newsWatchServer) Send(m *WatchEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _News_Ingest_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(NewsServer).Ingest(&newsIngestServer{stream})
+}
+
+type News_IngestServer interface {
+	SendAndClose(*IngestResponse) error
+	Recv() (*Item, error)
+	grpc.ServerStream
+}
+
+type newsIngestServer struct {
+	grpc.ServerStream
+}
+
+func (x *newsIngestServer) SendAndClose(m *IngestResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *newsIngestServer) Recv() (*Item, error) {
+	m := new(Item)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // News_ServiceDesc is the grpc.ServiceDesc for News service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -100,7 +405,38 @@ var News_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "List",
 			Handler:    _News_List_Handler,
 		},
+		{
+			MethodName: "Search",
+			Handler:    _News_Search_Handler,
+		},
+		{
+			MethodName: "GetItem",
+			Handler:    _News_GetItem_Handler,
+		},
+		{
+			MethodName: "CreateItem",
+			Handler:    _News_CreateItem_Handler,
+		},
+		{
+			MethodName: "UpdateItem",
+			Handler:    _News_UpdateItem_Handler,
+		},
+		{
+			MethodName: "DeleteItem",
+			Handler:    _News_DeleteItem_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _News_Watch_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Ingest",
+			Handler:       _News_Ingest_Handler,
+			ClientStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "pkg/grpc/item.proto",
 }
@@ -0,0 +1,65 @@
+package grpc
+
+import (
+	context "context"
+	"net"
+
+	grpc "google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// Server - gRPC-сервер с сервисом News, проверкой
+// здоровья grpc.health.v1 и рефлексией сервера
+type Server struct {
+	srv    *grpc.Server
+	health *health.Server
+}
+
+// NewServer возвращает сервер, который обслуживает api
+func NewServer(api *API, opts ...grpc.ServerOption) *Server {
+	s := &Server{
+		srv:    grpc.NewServer(opts...),
+		health: health.NewServer(),
+	}
+
+	RegisterNewsServer(s.srv, api)
+	healthpb.RegisterHealthServer(s.srv, s.health)
+	reflection.Register(s.srv)
+
+	// пустое имя - состояние сервера целиком
+	s.health.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	s.health.SetServingStatus(News_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+
+	return s
+}
+
+// Serve принимает соединения на lis, пока сервер
+// не будет остановлен методом Shutdown
+func (s *Server) Serve(lis net.Listener) error {
+	return s.srv.Serve(lis)
+}
+
+// Shutdown сообщает клиентам через проверку здоровья, что
+// сервер больше не обслуживает запросы, и дожидается
+// завершения текущих запросов. Если ctx завершится раньше,
+// оставшиеся запросы прерываются и возвращается ошибка ctx
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.health.Shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		s.srv.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.srv.Stop()
+		<-done
+		return ctx.Err()
+	}
+}
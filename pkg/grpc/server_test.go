@@ -0,0 +1,87 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"news/pkg/storage/memdb"
+	"testing"
+	"time"
+
+	grpc "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+)
+
+func TestServer(t *testing.T) {
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+
+	srv := NewServer(New(memdb.New(), log.New(io.Discard, "", 0)))
+
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.Serve(lis)
+	}()
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc.Dial() error = %v", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	t.Run("проверка_здоровья", func(t *testing.T) {
+		hc := healthpb.NewHealthClient(conn)
+		for _, service := range []string{"", News_ServiceDesc.ServiceName} {
+			resp, err := hc.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+			if err != nil {
+				t.Fatalf("Health.Check(%q) error = %v", service, err)
+			}
+			if resp.Status != healthpb.HealthCheckResponse_SERVING {
+				t.Errorf("Health.Check(%q) got = %v, want = %v", service, resp.Status, healthpb.HealthCheckResponse_SERVING)
+			}
+		}
+	})
+
+	t.Run("рефлексия", func(t *testing.T) {
+		stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+		if err != nil {
+			t.Fatalf("ServerReflectionInfo() error = %v", err)
+		}
+		err = stream.Send(&rpb.ServerReflectionRequest{
+			MessageRequest: &rpb.ServerReflectionRequest_ListServices{},
+		})
+		if err != nil {
+			t.Fatalf("ServerReflectionInfo().Send() error = %v", err)
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("ServerReflectionInfo().Recv() error = %v", err)
+		}
+		// иначе сервер будет ждать завершения потока при остановке
+		_ = stream.CloseSend()
+
+		var found bool
+		for _, s := range resp.GetListServicesResponse().GetService() {
+			found = found || s.Name == News_ServiceDesc.ServiceName
+		}
+		if !found {
+			t.Errorf("ServerReflectionInfo() got services = %v, want %s", resp.GetListServicesResponse().GetService(), News_ServiceDesc.ServiceName)
+		}
+	})
+
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatalf("Server.Shutdown() error = %v", err)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("Server.Serve() error = %v", err)
+	}
+}
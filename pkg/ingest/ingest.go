@@ -0,0 +1,269 @@
+// Package ingest принимает новости, которые присылают внешние
+// системы, а не rss-ленты. Новости проверяются, отсеиваются
+// дубликаты, затем новости проходят те же шаги обработки, что
+// и новости rss-лент, и передаются на запись в БД вместе с ними
+package ingest
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/url"
+	"news/pkg/langdetect"
+	"news/pkg/sanitize"
+	"news/pkg/storage"
+	"strings"
+	"sync"
+	"time"
+)
+
+type container = storage.ItemContainer
+type item = storage.Item
+
+const (
+	// DefaultSource - источник всех принятых новостей. Источник
+	// клиента не сохраняется, иначе новость могла бы выдать себя
+	// за новость rss-ленты, например для шага "readability"
+	DefaultSource = "ingest"
+	// MaxItems - сколько новостей можно передать за один запрос
+	MaxItems = 1000
+)
+
+// ErrClosed - новости больше не принимаются: канал
+// с новостями rss-лент, к которому подключен Ingester, закрыт
+var ErrClosed = errors.New("ingest: closed")
+
+// Status - итог приёма новости
+type Status int
+
+const (
+	Accepted  Status = iota // новость передана на запись в БД
+	Duplicate               // новость с такой ссылкой уже есть в БД или в запросе
+	Invalid                 // новость не прошла проверку
+	Filtered                // новость отброшена шагом обработки
+)
+
+func (s Status) String() string {
+	switch s {
+	case Accepted:
+		return "accepted"
+	case Duplicate:
+		return "duplicate"
+	case Invalid:
+		return "invalid"
+	case Filtered:
+		return "filtered"
+	}
+	return "unknown"
+}
+
+// Result - итог приёма одной новости
+type Result struct {
+	Link   string
+	Status Status
+	Reason string // почему новость не принята
+}
+
+// processor - шаги обработки новостей, например *pipeline.Pipeline
+type processor interface {
+	Process(ctx context.Context, it item) (item, bool, string)
+}
+
+// Ingester принимает новости от внешних систем
+type Ingester struct {
+	log *log.Logger
+	db  storage.Storage
+	// шаги обработки новостей, по-умолчанию nil
+	pipe processor
+
+	queue chan container
+	done  chan struct{}
+	once  sync.Once
+
+	// когда установлен в true, логгирует
+	// непринятые новости, по-умолчанию false
+	debugMode bool
+}
+
+// New возвращает новый объект *Ingester. db нужна, чтобы
+// отсеивать новости, которые уже есть в БД
+func New(log *log.Logger, db storage.Storage) *Ingester {
+	return &Ingester{
+		log:       log,
+		db:        db,
+		queue:     make(chan container),
+		done:      make(chan struct{}),
+		debugMode: false,
+	}
+}
+
+// DebugMode переключает debug режим у *Ingester
+func (in *Ingester) DebugMode(on bool) *Ingester {
+	in.debugMode = on
+	return in
+}
+
+// Pipeline устанавливает шаги обработки, через
+// которые проходят принятые новости
+func (in *Ingester) Pipeline(p processor) *Ingester {
+	in.pipe = p
+	return in
+}
+
+// Join возвращает канал, в который попадают контейнеры из src
+// и принятые новости. Канал закрывается, когда закрыт src, после
+// этого Ingest возвращает ErrClosed. Новости из src проходят
+// дальше как есть, поэтому src должен быть уже обработан
+func (in *Ingester) Join(src <-chan container) <-chan container {
+	out := make(chan container, cap(src))
+
+	go func() {
+		defer close(out)
+		defer in.once.Do(func() { close(in.done) })
+
+		for {
+			select {
+			case v, ok := <-src:
+				if !ok {
+					return
+				}
+				out <- v
+			case v := <-in.queue:
+				out <- v
+			}
+		}
+	}()
+
+	return out
+}
+
+// Ingest проверяет новости, пропускает их через шаги обработки
+// и передаёт принятые на запись в БД. Возвращает итог по каждой
+// новости в исходном порядке. Принятая новость записывается
+// в БД асинхронно, вместе с новостями rss-лент. Ingest ждёт,
+// пока новости заберёт канал, который вернул Join
+func (in *Ingester) Ingest(ctx context.Context, items []item) ([]Result, error) {
+	results := make([]Result, len(items))
+	accepted := make([]item, 0, len(items))
+	seen := make(map[string]bool, len(items))
+
+	for i, it := range items {
+		it, res := in.check(ctx, it, seen)
+		results[i] = res
+
+		if res.Status != Accepted {
+			if in.debugMode {
+				in.log.Printf("[DEBUG] status=%s link=%s reason=%s", res.Status, res.Link, res.Reason)
+			}
+			continue
+		}
+		accepted = append(accepted, it)
+	}
+
+	if len(accepted) > 0 {
+		select {
+		case in.queue <- container{Items: accepted}:
+		case <-in.done:
+			return nil, ErrClosed
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	in.log.Printf("[INFO] ingested items=%d accepted=%d", len(items), len(accepted))
+
+	return results, nil
+}
+
+// check проверяет и обрабатывает одну новость. seen -
+// ссылки новостей, уже принятых в этом запросе
+func (in *Ingester) check(ctx context.Context, it item, seen map[string]bool) (item, Result) {
+	it.Link = strings.TrimSpace(it.Link)
+	res := Result{Link: it.Link, Status: Invalid}
+
+	if reason := validate(&it); reason != "" {
+		res.Reason = reason
+		return it, res
+	}
+
+	if seen[it.Link] {
+		res.Status, res.Reason = Duplicate, "duplicate link in request"
+		return it, res
+	}
+	if db, ok := in.db.(storage.Editor); ok {
+		_, err := db.Item(ctx, it.Link)
+		switch {
+		case err == nil:
+			res.Status, res.Reason = Duplicate, "item already exists"
+			return it, res
+		case !errors.Is(err, storage.ErrNotFound):
+			// БД не ответила, дубликат отсеет запись в БД
+			in.log.Printf("[ERROR] link=%s error=%v", it.Link, err)
+		}
+	}
+
+	prepare(&it)
+
+	if in.pipe != nil {
+		out, keep, stage := in.pipe.Process(ctx, it)
+		if !keep {
+			res.Status, res.Reason = Filtered, "dropped by stage "+stage
+			return it, res
+		}
+		it = out
+	}
+
+	seen[it.Link] = true
+	res.Link, res.Status = it.Link, Accepted
+	return it, res
+}
+
+// Sanitize разбирает описание новости так же, как описание
+// новости rss-ленты: описание может быть в html, из него
+// получаются безопасный html, простой текст и картинка,
+// если её нет. Ссылки разрешаются относительно ссылки новости
+func Sanitize(it *item) {
+	base, _ := url.Parse(it.Link)
+
+	if it.Image != "" {
+		it.Image, _ = sanitize.ImageURL(it.Image, base)
+	} else {
+		it.Image = sanitize.FirstImage(it.Description, base)
+	}
+	it.HTML = sanitize.HTML(it.Description, base)
+	it.Description = sanitize.Text(it.Description)
+}
+
+// validate возвращает причину, по которой
+// новость нельзя принять, или пустую строку
+func validate(it *item) string {
+	if strings.TrimSpace(it.Title) == "" {
+		return "title required"
+	}
+	u, err := url.Parse(it.Link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "link must be an absolute http(s) url"
+	}
+	if it.Lang != "" {
+		if it.Lang = langdetect.Normalize(it.Lang); it.Lang == "" {
+			return "invalid lang"
+		}
+	}
+	if it.PubDate < 0 {
+		return "negative pubTime"
+	}
+	return ""
+}
+
+// prepare приводит новость к виду новостей rss-лент
+func prepare(it *item) {
+	it.Id, it.Oid = 0, [12]byte{} // идентификаторы присваивает БД
+	Sanitize(it)
+	it.Article = ""
+
+	it.Source = DefaultSource
+	if it.PubDate == 0 {
+		it.PubDate = time.Now().Unix()
+	}
+	it.DetectLang("")
+}
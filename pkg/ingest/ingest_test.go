@@ -0,0 +1,96 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"news/pkg/pipeline"
+	"news/pkg/storage/memdb"
+	"testing"
+	"time"
+)
+
+func TestIngester_Ingest(t *testing.T) {
+
+	logger := log.New(io.Discard, "", 0)
+	pipe := pipeline.New(logger, pipeline.Trim(), pipeline.StageFunc{
+		StageName: "no_ads",
+		Func: func(_ context.Context, it item) (item, bool, error) {
+			return it, it.Title != "Реклама", nil
+		},
+	})
+
+	in := New(logger, memdb.New()).Pipeline(pipe)
+
+	src := make(chan container)
+	out := in.Join(src)
+
+	items := []item{
+		{Title: "  Новость  ", Description: `<p>Текст <img src="/a.png"></p>`, Link: " https://news.com/1 "},
+		{Title: "Новость", Link: "https://news.com/1"},
+		{Title: "", Link: "https://news.com/2"},
+		{Title: "Новость", Link: "/relative"},
+		{Title: "Новость", Link: "https://news.com/3", Lang: "klingon"},
+		{Title: "Новость", Link: memdb.SampleItem.Link},
+		{Title: "Реклама", Link: "https://news.com/4"},
+		{Title: "News", Link: "https://news.com/5", Source: "https://news.com/rss", PubDate: 100, Lang: "EN"},
+	}
+	want := []Status{Accepted, Duplicate, Invalid, Invalid, Invalid, Duplicate, Filtered, Accepted}
+
+	got := make(chan container, 1)
+	go func() { got <- <-out }()
+
+	res, err := in.Ingest(context.Background(), items)
+	if err != nil {
+		t.Fatalf("Ingester.Ingest() error = %v", err)
+	}
+
+	for i := range want {
+		if res[i].Status != want[i] {
+			t.Errorf("Ingester.Ingest() item #%d got = %v (%s), want = %v", i, res[i].Status, res[i].Reason, want[i])
+		}
+	}
+	if res[6].Reason != "dropped by stage no_ads" {
+		t.Errorf("Ingester.Ingest() got reason = %q", res[6].Reason)
+	}
+
+	var c container
+	select {
+	case c = <-got:
+	case <-time.After(time.Second):
+		t.Fatalf("Ingester.Join() got nothing")
+	}
+	if len(c.Items) != 2 {
+		t.Fatalf("Ingester.Join() got items = %d, want = 2", len(c.Items))
+	}
+
+	first := c.Items[0]
+	if first.Title != "Новость" || first.Link != "https://news.com/1" || first.Source != DefaultSource {
+		t.Errorf("Ingester.Join() got = %+v", first)
+	}
+	if first.Description != "Текст" || first.Image != "https://news.com/a.png" || first.PubDate == 0 {
+		t.Errorf("Ingester.Join() got description = %q, image = %q, pubTime = %d",
+			first.Description, first.Image, first.PubDate)
+	}
+	if second := c.Items[1]; second.Source != DefaultSource || second.PubDate != 100 || second.Lang != "en" {
+		t.Errorf("Ingester.Join() got = %+v", second)
+	}
+
+	// после закрытия канала новости rss-лент
+	// новости больше не принимаются
+	close(src)
+	for range out {
+	}
+
+	_, err = in.Ingest(context.Background(), []item{{Title: "Новость", Link: "https://news.com/6"}})
+	if !errors.Is(err, ErrClosed) {
+		t.Errorf("Ingester.Ingest() error = %v, want = %v", err, ErrClosed)
+	}
+
+	// непринятые новости не ждут канала
+	res, err = in.Ingest(context.Background(), []item{{Link: "https://news.com/7"}})
+	if err != nil || res[0].Status != Invalid {
+		t.Errorf("Ingester.Ingest() got = %v, error = %v", res, err)
+	}
+}
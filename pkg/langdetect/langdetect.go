@@ -0,0 +1,147 @@
+// Package langdetect определяет язык текста по частотам
+// триграмм символов: выбирается язык, в котором триграммы
+// текста наиболее вероятны. Частоты считаются при старте
+// по небольшим текстам на каждом языке из каталога corpus
+package langdetect
+
+import (
+	"embed"
+	"math"
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:embed corpus/*.txt
+var corpus embed.FS
+
+// minLetters - тексты короче не определяются, слишком мало данных
+const minLetters = 20
+
+// model - частоты триграмм языка
+type model struct {
+	counts map[string]int
+	total  int
+}
+
+// logProb - логарифм вероятности триграммы в языке
+// со сглаживанием Лапласа для невстречавшихся триграмм
+func (m model) logProb(tri string, vocab int) float64 {
+	return math.Log(float64(m.counts[tri]+1) / float64(m.total+vocab))
+}
+
+var (
+	models = map[string]model{}
+	vocab  int // количество разных триграмм во всех текстах
+)
+
+func init() {
+	files, err := corpus.ReadDir("corpus")
+	if err != nil {
+		panic(err)
+	}
+
+	all := make(map[string]bool)
+
+	for _, f := range files {
+		b, err := corpus.ReadFile(path.Join("corpus", f.Name()))
+		if err != nil {
+			panic(err)
+		}
+
+		m := model{counts: trigrams(string(b))}
+		for tri, n := range m.counts {
+			m.total += n
+			all[tri] = true
+		}
+
+		lang := strings.TrimSuffix(f.Name(), path.Ext(f.Name()))
+		models[lang] = m
+	}
+
+	vocab = len(all)
+}
+
+// Languages возвращает языки, которые умеет определять пакет
+func Languages() []string {
+	langs := make([]string, 0, len(models))
+	for lang := range models {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Detect возвращает код языка текста по ISO 639-1,
+// например "ru" или "en", или пустую строку, если
+// текст слишком короткий для определения
+func Detect(text string) string {
+	letters := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	if letters < minLetters {
+		return ""
+	}
+
+	tris := trigrams(text)
+
+	var best string
+	var bestScore float64
+
+	for lang, m := range models {
+		var score float64
+		for tri, n := range tris {
+			score += float64(n) * m.logProb(tri, vocab)
+		}
+		if best == "" || score > bestScore || (score == bestScore && lang < best) {
+			best, bestScore = lang, score
+		}
+	}
+
+	return best
+}
+
+// Normalize приводит тег языка вида "en-US" или "ru_RU"
+// к коду языка "en" или "ru". Для некорректного тега
+// возвращает пустую строку
+func Normalize(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+
+	if n := utf8.RuneCountInString(tag); n < 2 || n > 3 {
+		return ""
+	}
+	for _, r := range tag {
+		if r < 'a' || r > 'z' {
+			return ""
+		}
+	}
+
+	return tag
+}
+
+// trigrams считает триграммы слов текста,
+// дополненных пробелами с обеих сторон
+func trigrams(text string) map[string]int {
+	counts := make(map[string]int)
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+
+	for _, w := range words {
+		r := []rune(" " + w + " ")
+		for i := 0; i+3 <= len(r); i++ {
+			counts[string(r[i:i+3])]++
+		}
+	}
+
+	return counts
+}
@@ -0,0 +1,42 @@
+package langdetect
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Как мы переписали сервис уведомлений на Go и сократили задержки вдвое", "ru"},
+		{"Why you should avoid global state in your Go packages and what to do instead", "en"},
+		{"Як ми переписали сервіс сповіщень на Go і вдвічі скоротили затримки", "uk"},
+		{"Warum man globalen Zustand in Go-Paketen vermeiden sollte und was stattdessen hilft", "de"},
+		{"Pourquoi il faut éviter l'état global dans vos paquets Go et que faire à la place", "fr"},
+		{"Por qué deberías evitar el estado global en tus paquetes de Go y qué hacer en su lugar", "es"},
+		{"Go 1.19", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := Detect(tt.text); got != tt.want {
+				t.Errorf("Detect(%q) got = %q, want = %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	for tag, want := range map[string]string{
+		"ru":      "ru",
+		"en-US":   "en",
+		"ru_RU":   "ru",
+		" EN-gb ": "en",
+		"":        "",
+		"русский": "",
+		"e":       "",
+	} {
+		if got := Normalize(tag); got != want {
+			t.Errorf("Normalize(%q) got = %q, want = %q", tag, got, want)
+		}
+	}
+}
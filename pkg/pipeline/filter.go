@@ -0,0 +1,171 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+func init() {
+	Register("filter", func(params json.RawMessage) (Stage, error) {
+		var c FilterConfig
+		if err := decodeParams(params, &c); err != nil {
+			return nil, err
+		}
+		return Filter(c)
+	})
+}
+
+// Match - условия совпадения новости. Новость совпадает,
+// если выполнено хотя бы одно из условий
+type Match struct {
+	Keywords   []string `json:"keywords"`   // подстроки заголовка или описания, без учёта регистра
+	Regexps    []string `json:"regexps"`    // регулярные выражения для заголовка или описания
+	Categories []string `json:"categories"` // категории новости, без учёта регистра
+	Authors    []string `json:"authors"`    // авторы новости, без учёта регистра
+}
+
+// Rules - правила фильтрации. Новость, совпавшая с Exclude,
+// отбрасывается. Если Include задан, то новость, не совпавшая
+// с ним, тоже отбрасывается
+type Rules struct {
+	Include Match `json:"include"`
+	Exclude Match `json:"exclude"`
+}
+
+// FilterConfig - параметры шага "filter". Новость должна
+// пройти и общие правила, и правила своей rss-ленты
+type FilterConfig struct {
+	Global Rules            `json:"global"` // правила для всех rss-лент
+	Feeds  map[string]Rules `json:"feeds"`  // правила для отдельных rss-лент по ссылке на ленту
+}
+
+// Filter возвращает шаг, отбрасывающий новости по правилам c.
+// Ленту новости шаг определяет по полю Source
+func Filter(c FilterConfig) (Stage, error) {
+	global, err := compileRules(c.Global)
+	if err != nil {
+		return nil, fmt.Errorf("global: %w", err)
+	}
+
+	feeds := make(map[string]rules, len(c.Feeds))
+	for feed, r := range c.Feeds {
+		if feeds[feed], err = compileRules(r); err != nil {
+			return nil, fmt.Errorf("feed %q: %w", feed, err)
+		}
+	}
+
+	return StageFunc{
+		StageName: "filter",
+		Func: func(_ context.Context, it item) (item, bool, error) {
+			if !global.pass(it) {
+				return it, false, nil
+			}
+			if r, ok := feeds[it.Source]; ok && !r.pass(it) {
+				return it, false, nil
+			}
+			return it, true, nil
+		},
+	}, nil
+}
+
+// rules - скомпилированные Rules
+type rules struct {
+	include, exclude matcher
+}
+
+func compileRules(r Rules) (rules, error) {
+	include, err := compileMatch(r.Include)
+	if err != nil {
+		return rules{}, fmt.Errorf("include: %w", err)
+	}
+	exclude, err := compileMatch(r.Exclude)
+	if err != nil {
+		return rules{}, fmt.Errorf("exclude: %w", err)
+	}
+	return rules{include: include, exclude: exclude}, nil
+}
+
+// pass сообщает, что новость проходит правила
+func (r rules) pass(it item) bool {
+	if r.exclude.match(it) {
+		return false
+	}
+	return r.include.empty() || r.include.match(it)
+}
+
+// matcher - скомпилированный Match
+type matcher struct {
+	keywords   []string
+	regexps    []*regexp.Regexp
+	categories map[string]bool
+	authors    map[string]bool
+}
+
+func compileMatch(m Match) (matcher, error) {
+	c := matcher{
+		categories: set(m.Categories),
+		authors:    set(m.Authors),
+	}
+
+	for _, kw := range m.Keywords {
+		if kw = strings.ToLower(strings.TrimSpace(kw)); kw != "" {
+			c.keywords = append(c.keywords, kw)
+		}
+	}
+
+	for _, expr := range m.Regexps {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return matcher{}, err
+		}
+		c.regexps = append(c.regexps, re)
+	}
+
+	return c, nil
+}
+
+// set возвращает множество строк в нижнем регистре
+func set(values []string) map[string]bool {
+	s := make(map[string]bool, len(values))
+	for _, v := range values {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			s[v] = true
+		}
+	}
+	return s
+}
+
+// empty сообщает, что условий нет
+func (m matcher) empty() bool {
+	return len(m.keywords) == 0 && len(m.regexps) == 0 &&
+		len(m.categories) == 0 && len(m.authors) == 0
+}
+
+// match сообщает, что новость выполняет хотя бы одно условие
+func (m matcher) match(it item) bool {
+	if len(m.keywords) > 0 {
+		title, desc := strings.ToLower(it.Title), strings.ToLower(it.Description)
+		for _, kw := range m.keywords {
+			if strings.Contains(title, kw) || strings.Contains(desc, kw) {
+				return true
+			}
+		}
+	}
+
+	for _, re := range m.regexps {
+		if re.MatchString(it.Title) || re.MatchString(it.Description) {
+			return true
+		}
+	}
+
+	for _, c := range it.Categories {
+		if m.categories[strings.ToLower(strings.TrimSpace(c))] {
+			return true
+		}
+	}
+
+	return m.authors[strings.ToLower(strings.TrimSpace(it.Author))]
+}
@@ -0,0 +1,90 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+
+	const (
+		habr   = "https://habr.com/ru/rss/hub/go/all/?fl=ru"
+		weekly = "https://cprss.s3.amazonaws.com/golangweekly.com.xml"
+	)
+
+	params := json.RawMessage(`{
+		"global": {
+			"exclude": {"keywords": ["Реклама"], "categories": ["Вакансии"]}
+		},
+		"feeds": {
+			"` + habr + `": {
+				"include": {"categories": ["go"], "authors": ["gopher"]},
+				"exclude": {"regexps": ["^\\[(?i:sponsored)\\]"]}
+			}
+		}
+	}`)
+
+	stages, err := Build([]Spec{{Stage: "filter", Params: params}})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	f := stages[0]
+
+	tests := []struct {
+		name string
+		it   item
+		want bool
+	}{
+		{
+			name: "общее_исключение_по_слову",
+			it:   item{Title: "Новость", Description: "Это реклама", Source: weekly},
+			want: false,
+		},
+		{
+			name: "общее_исключение_по_категории",
+			it:   item{Title: "Ищем разработчика", Categories: []string{"вакансии"}, Source: weekly},
+			want: false,
+		},
+		{
+			name: "лента_без_своих_правил",
+			it:   item{Title: "Go 1.19", Source: weekly},
+			want: true,
+		},
+		{
+			name: "включение_по_категории",
+			it:   item{Title: "Дженерики", Categories: []string{"Go"}, Source: habr},
+			want: true,
+		},
+		{
+			name: "включение_по_автору",
+			it:   item{Title: "Каналы", Author: "Gopher", Source: habr},
+			want: true,
+		},
+		{
+			name: "не_совпало_с_включением",
+			it:   item{Title: "Rust", Categories: []string{"Rust"}, Source: habr},
+			want: false,
+		},
+		{
+			name: "исключение_ленты_по_регулярке",
+			it:   item{Title: "[Sponsored] Go", Categories: []string{"Go"}, Source: habr},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, got, _ := f.Process(context.Background(), tt.it); got != tt.want {
+				t.Errorf("Filter() got keep = %t, want = %t", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("ошибка_в_регулярке", func(t *testing.T) {
+		_, err := Filter(FilterConfig{Global: Rules{Exclude: Match{Regexps: []string{"("}}}})
+		if err == nil {
+			t.Errorf("Filter() expected error, got nothing")
+		}
+	})
+}
@@ -0,0 +1,299 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"news/pkg/storage"
+	"sort"
+	"sync"
+)
+
+// container - объекты, которые обрабатывает pipeline
+type container = storage.ItemContainer
+type item = storage.Item
+
+// Stage - шаг обработки новости между rss-обходчиком и записью в БД.
+// Фильтр отбрасывает новость, возвращая keep = false, преобразователь
+// и обогатитель возвращают изменённую новость. Если шаг вернул ошибку,
+// то новость проходит дальше без изменений этого шага
+type Stage interface {
+	Name() string
+	Process(ctx context.Context, it item) (out item, keep bool, err error)
+}
+
+// StageFunc - адаптер для Stage, а-ля http.HandlerFunc
+type StageFunc struct {
+	StageName string
+	Func      func(ctx context.Context, it item) (item, bool, error)
+}
+
+func (f StageFunc) Name() string {
+	return f.StageName
+}
+
+func (f StageFunc) Process(ctx context.Context, it item) (item, bool, error) {
+	return f.Func(ctx, it)
+}
+
+// Spec - описание шага в конфигурации
+type Spec struct {
+	Stage  string          `json:"stage"`  // имя шага
+	Params json.RawMessage `json:"params"` // параметры шага, зависят от шага
+}
+
+// Factory создаёт шаг по его параметрам из конфигурации
+type Factory func(params json.RawMessage) (Stage, error)
+
+var (
+	regMu    sync.RWMutex
+	registry = map[string]Factory{}
+)
+
+// Register регистрирует фабрику шага под именем name,
+// чтобы шаг можно было указать в конфигурации
+func Register(name string, f Factory) {
+	regMu.Lock()
+	defer regMu.Unlock()
+	registry[name] = f
+}
+
+// Stages возвращает имена зарегистрированных шагов
+func Stages() []string {
+	regMu.RLock()
+	defer regMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Build создаёт шаги по их описанию в конфигурации
+func Build(specs []Spec) ([]Stage, error) {
+	regMu.RLock()
+	defer regMu.RUnlock()
+
+	stages := make([]Stage, 0, len(specs))
+
+	for i, s := range specs {
+		f, ok := registry[s.Stage]
+		if !ok {
+			return nil, fmt.Errorf("pipeline: stage #%d: unknown stage %q", i+1, s.Stage)
+		}
+
+		st, err := f(s.Params)
+		if err != nil {
+			return nil, fmt.Errorf("pipeline: stage #%d %q: %w", i+1, s.Stage, err)
+		}
+
+		stages = append(stages, st)
+	}
+
+	return stages, nil
+}
+
+// Stats - статистика работы *Pipeline
+type Stats struct {
+	Containers uint            // обработанные контейнеры
+	Items      uint            // обработанные новости
+	Passed     uint            // новости, прошедшие все шаги
+	Dropped    map[string]uint // отброшенные новости по имени шага
+	BySource   map[string]uint // отброшенные новости по rss-ленте
+	Errs       uint            // ошибки шагов
+}
+
+// Filtered - всего отброшено новостей
+func (s Stats) Filtered() uint {
+	var n uint
+	for _, v := range s.Dropped {
+		n += v
+	}
+	return n
+}
+
+// Pipeline - цепочка шагов обработки новостей
+type Pipeline struct {
+	log    *log.Logger
+	stages []Stage
+
+	mu    sync.Mutex
+	stats Stats
+
+	// сколько новостей контейнера обрабатывать
+	// одновременно, по-умолчанию 1
+	workers int
+
+	// когда установлен в true, логгирует ошибки
+	// и отброшенные новости, по-умолчанию false
+	debugMode bool
+}
+
+// New возвращает новый объект *Pipeline
+// с шагами в порядке их выполнения
+func New(log *log.Logger, stages ...Stage) *Pipeline {
+	return &Pipeline{
+		log:       log,
+		stages:    stages,
+		stats:     Stats{Dropped: map[string]uint{}, BySource: map[string]uint{}},
+		workers:   1,
+		debugMode: false,
+	}
+}
+
+// DebugMode переключает debug режим у *Pipeline
+func (p *Pipeline) DebugMode(on bool) *Pipeline {
+	p.debugMode = on
+	return p
+}
+
+// Workers устанавливает, сколько новостей контейнера
+// обрабатывать одновременно. Полезно, если в цепочке
+// есть шаги, которые ходят в сеть
+func (p *Pipeline) Workers(n int) *Pipeline {
+	if n > 0 {
+		p.workers = n
+	}
+	return p
+}
+
+// Stats возвращает текущую статистику работы *Pipeline
+func (p *Pipeline) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s := p.stats
+	s.Dropped = copyCounts(p.stats.Dropped)
+	s.BySource = copyCounts(p.stats.BySource)
+
+	return s
+}
+
+func copyCounts(m map[string]uint) map[string]uint {
+	c := make(map[string]uint, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// Process пропускает новость через все шаги. Возвращает
+// обработанную новость и признак, что её нужно оставить,
+// а если новость отброшена - имя отбросившего её шага
+func (p *Pipeline) Process(ctx context.Context, it item) (item, bool, string) {
+
+	p.mu.Lock()
+	p.stats.Items++
+	p.mu.Unlock()
+
+	for _, st := range p.stages {
+		out, keep, err := st.Process(ctx, it)
+		if err != nil {
+			p.mu.Lock()
+			p.stats.Errs++
+			p.mu.Unlock()
+			p.log.Printf("[ERROR] stage=%s link=%s error=%v", st.Name(), it.Link, err)
+			continue // шаг пропускается, новость идёт дальше
+		}
+
+		if !keep {
+			p.mu.Lock()
+			p.stats.Dropped[st.Name()]++
+			p.stats.BySource[it.Source]++
+			p.mu.Unlock()
+			if p.debugMode {
+				p.log.Printf("[DEBUG] stage=%s dropped link=%s", st.Name(), it.Link)
+			}
+			return it, false, st.Name()
+		}
+
+		it = out
+	}
+
+	p.mu.Lock()
+	p.stats.Passed++
+	p.mu.Unlock()
+
+	return it, true, ""
+}
+
+// processAll пропускает новости через все шаги в p.workers
+// горутин и возвращает оставленные новости в исходном порядке
+func (p *Pipeline) processAll(ctx context.Context, in []item) []item {
+
+	out := make([]item, len(in))
+	keep := make([]bool, len(in))
+
+	idx := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < p.workers && w < len(in); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range idx {
+				out[i], keep[i], _ = p.Process(ctx, in[i])
+			}
+		}()
+	}
+
+	for i := range in {
+		idx <- i
+	}
+	close(idx)
+	wg.Wait()
+
+	items := out[:0]
+	for i := range out {
+		if keep[i] {
+			items = append(items, out[i])
+		}
+	}
+
+	return items
+}
+
+// Run пропускает новости контейнеров из канала in через все шаги
+// и возвращает канал с обработанными контейнерами. Пустые после
+// обработки контейнеры дальше не передаются. Выходной канал
+// закрывается, когда закрыт входной или отменён ctx
+func (p *Pipeline) Run(ctx context.Context, in <-chan container) <-chan container {
+
+	out := make(chan container, cap(in))
+
+	go func() {
+		defer func() {
+			close(out)
+
+			s := p.Stats()
+			p.log.Printf("[INFO] totals: containers=%d items=%d passed=%d filtered=%d stage_errors=%d dropped_by_stage=%v dropped_by_source=%v",
+				s.Containers, s.Items, s.Passed, s.Filtered(), s.Errs, s.Dropped, s.BySource)
+		}()
+
+		for v := range in {
+			items := p.processAll(ctx, v.Items)
+
+			p.mu.Lock()
+			p.stats.Containers++
+			p.mu.Unlock()
+
+			if len(items) == 0 {
+				continue
+			}
+
+			v.Items = items
+			select {
+			case out <- v:
+			case <-ctx.Done():
+				// получатель остановился и канал больше не читает
+				return
+			}
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,166 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestBuild(t *testing.T) {
+
+	specs := []Spec{
+		{Stage: "trim"},
+		{Stage: "limit", Params: json.RawMessage(`{"title": 10}`)},
+		{Stage: "require_link"},
+		{Stage: "clamp_future", Params: json.RawMessage(`{"tolerance": 5}`)},
+	}
+
+	stages, err := Build(specs)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	for i := range specs {
+		if stages[i].Name() != specs[i].Stage {
+			t.Errorf("Build() got stage = %s, want = %s", stages[i].Name(), specs[i].Stage)
+		}
+	}
+
+	if _, err := Build([]Spec{{Stage: "unknown"}}); err == nil {
+		t.Errorf("Build() expected error for unknown stage, got nothing")
+	}
+
+	if _, err := Build([]Spec{{Stage: "limit", Params: json.RawMessage(`{"title": -1}`)}}); err == nil {
+		t.Errorf("Build() expected error for negative limit, got nothing")
+	}
+}
+
+func TestStages(t *testing.T) {
+
+	ctx := context.Background()
+
+	t.Run("trim", func(t *testing.T) {
+		got, _, _ := Trim().Process(ctx, item{Title: "  Заголовок \n  новости ", Link: " https://test.com "})
+
+		if got.Title != "Заголовок новости" || got.Link != "https://test.com" {
+			t.Errorf("Trim() got = %v", got)
+		}
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		got, _, _ := Limit(5, 0).Process(ctx, item{Title: "Тестовый заголовок", Description: "Описание"})
+
+		if got.Title != "Тест…" || got.Description != "Описание" {
+			t.Errorf("Limit() got title = %q, description = %q", got.Title, got.Description)
+		}
+	})
+
+	t.Run("limit_html", func(t *testing.T) {
+		got, _, _ := Limit(0, 5).Process(ctx, item{Description: "Текст новости", HTML: "<p>Текст <b>новости</b></p>"})
+
+		if got.Description != "Текс…" || got.HTML != "<p>Текс…</p>" {
+			t.Errorf("Limit() got description = %q, html = %q", got.Description, got.HTML)
+		}
+	})
+
+	t.Run("require_link", func(t *testing.T) {
+		for link, want := range map[string]bool{
+			"https://test.com/1": true,
+			"http://test.com":    true,
+			"":                   false,
+			"/relative/link":     false,
+			"ftp://test.com":     false,
+		} {
+			if _, keep, _ := RequireLink().Process(ctx, item{Link: link}); keep != want {
+				t.Errorf("RequireLink() link = %q got keep = %t, want = %t", link, keep, want)
+			}
+		}
+	})
+
+	t.Run("clamp_future", func(t *testing.T) {
+		fixed := time.Unix(1655806394, 0)
+		now = func() time.Time { return fixed }
+		defer func() { now = time.Now }()
+
+		st := ClampFuture(time.Minute)
+
+		got, _, _ := st.Process(ctx, item{PubDate: fixed.Add(time.Hour).Unix()})
+		if got.PubDate != fixed.Unix() {
+			t.Errorf("ClampFuture() got = %d, want = %d", got.PubDate, fixed.Unix())
+		}
+
+		// опережение в пределах допуска не трогаем
+		want := fixed.Add(30 * time.Second).Unix()
+		got, _, _ = st.Process(ctx, item{PubDate: want})
+		if got.PubDate != want {
+			t.Errorf("ClampFuture() got = %d, want = %d", got.PubDate, want)
+		}
+	})
+}
+
+func TestPipeline_Run(t *testing.T) {
+
+	failing := StageFunc{
+		StageName: "failing",
+		Func: func(_ context.Context, it item) (item, bool, error) {
+			it.Title = "испорчено"
+			return it, true, errors.New("enrichment failed")
+		},
+	}
+
+	p := New(log.New(io.Discard, "", 0), Trim(), failing, RequireLink())
+
+	in := make(chan container, 2)
+	in <- container{Items: []item{
+		{Title: " новость 1 ", Link: "https://test.com/1"},
+		{Title: "без ссылки"},
+	}}
+	in <- container{Items: []item{{Title: "без ссылки"}}} // пустой после обработки
+	close(in)
+
+	var got []container
+	for v := range p.Run(context.Background(), in) {
+		got = append(got, v)
+	}
+
+	if len(got) != 1 || len(got[0].Items) != 1 {
+		t.Fatalf("Pipeline.Run() got containers = %v, want one container with one item", got)
+	}
+
+	// ошибка шага не портит новость
+	if got[0].Items[0].Title != "новость 1" {
+		t.Errorf("Pipeline.Run() got title = %q, want = %q", got[0].Items[0].Title, "новость 1")
+	}
+
+	s := p.Stats()
+	if s.Items != 3 || s.Passed != 1 || s.Dropped["require_link"] != 2 || s.BySource[""] != 2 || s.Errs != 3 {
+		t.Errorf("Pipeline.Run() got stats = %+v", s)
+	}
+}
+
+func TestPipeline_Run_cancel(t *testing.T) {
+
+	p := New(log.New(io.Discard, "", 0), Trim())
+
+	// выходной канал никто не читает
+	in := make(chan container)
+	ctx, cancel := context.WithCancel(context.Background())
+	out := p.Run(ctx, in)
+
+	in <- container{Items: []item{{Title: "новость", Link: "https://test.com/1"}}}
+	cancel()
+	time.Sleep(20 * time.Millisecond) // Run замечает отмену, пока канал не читается
+
+	select {
+	case _, ok := <-out:
+		if ok {
+			t.Errorf("Pipeline.Run() got container after cancel")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Pipeline.Run() output channel is not closed after cancel")
+	}
+}
@@ -0,0 +1,130 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"news/pkg/readability"
+	"sync"
+	"time"
+)
+
+func init() {
+	Register("readability", func(params json.RawMessage) (Stage, error) {
+		var c ReadabilityConfig
+		if err := decodeParams(params, &c); err != nil {
+			return nil, err
+		}
+		if c.Concurrency < 0 || c.Rate < 0 || c.Timeout < 0 || c.MaxKB < 0 {
+			return nil, fmt.Errorf("negative param")
+		}
+
+		f := readability.NewFetcher(c.Concurrency, c.Rate).
+			Timeout(time.Second * time.Duration(c.Timeout)).
+			MaxBytes(int64(c.MaxKB) << 10)
+
+		return Readability(f, c.Feeds), nil
+	})
+}
+
+// ReadabilityConfig - параметры шага "readability".
+// Нулевые значения оставляют настройки по-умолчанию
+type ReadabilityConfig struct {
+	Feeds       []string `json:"feeds"`       // rss-ленты, для которых загружать статьи, если пусто - для всех
+	Concurrency int      `json:"concurrency"` // сколько страниц загружать одновременно
+	Rate        float64  `json:"rate"`        // сколько раз в секунду можно обращаться к одному сайту
+	Timeout     int      `json:"timeout"`     // таймаут загрузки страницы в секундах
+	MaxKB       int      `json:"max_kb"`      // максимальный размер страницы в килобайтах
+}
+
+// fetcher загружает статью по ссылке
+type fetcher interface {
+	Fetch(ctx context.Context, link string) (readability.Article, error)
+}
+
+// seenLimit - сколько ссылок помнит шаг "readability"
+const seenLimit = 10000
+
+// retryFailed - через сколько шаг "readability" снова загружает
+// статью, которую не удалось загрузить: таймауты и ошибки
+// сайта бывают временными
+const retryFailed = 30 * time.Minute
+
+// Readability возвращает шаг, который загружает страницу новости
+// и сохраняет основной текст статьи в поле Article, а картинку
+// статьи - в поле Image, если у новости картинки ещё нет. Статьи
+// загружаются только для новостей из лент feeds, если feeds
+// пустой - для всех. Rss-ленты при каждом опросе отдают
+// в основном те же новости, поэтому ссылки, которые уже
+// загружались, шаг пропускает, а ссылки, которые загрузить
+// не удалось, пропускает в течение retryFailed
+func Readability(f fetcher, feeds []string) Stage {
+	allowed := make(map[string]bool, len(feeds))
+	for _, feed := range feeds {
+		allowed[feed] = true
+	}
+
+	var mu sync.Mutex
+	// когда ссылку можно загрузить снова, нулевое
+	// время - статья загружена или загружается
+	seen := make(map[string]time.Time)
+	var order []string // порядок добавления ссылок для вытеснения старых
+
+	// remember помечает ссылку как загружающуюся и сообщает,
+	// что её ещё не загружали или пора загрузить повторно
+	remember := func(link string) bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		retry, ok := seen[link]
+		if ok && (retry.IsZero() || now().Before(retry)) {
+			return false
+		}
+
+		if !ok {
+			if len(order) >= seenLimit {
+				delete(seen, order[0])
+				order = order[1:]
+			}
+			order = append(order, link)
+		}
+		seen[link] = time.Time{}
+
+		return true
+	}
+
+	// failed откладывает повторную загрузку ссылки
+	failed := func(link string) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if _, ok := seen[link]; ok {
+			seen[link] = now().Add(retryFailed)
+		}
+	}
+
+	return StageFunc{
+		StageName: "readability",
+		Func: func(ctx context.Context, it item) (item, bool, error) {
+			if it.Article != "" || (len(allowed) > 0 && !allowed[it.Source]) {
+				return it, true, nil
+			}
+
+			if !remember(it.Link) {
+				return it, true, nil
+			}
+
+			article, err := f.Fetch(ctx, it.Link)
+			if err != nil {
+				failed(it.Link)
+				return it, true, err
+			}
+
+			it.Article = article.Content
+			if it.Image == "" {
+				it.Image = article.Image
+			}
+			return it, true, nil
+		},
+	}
+}
@@ -0,0 +1,127 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"news/pkg/readability"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeFetcher возвращает текст статьи по ссылке
+// и считает обращения к каждой ссылке
+type fakeFetcher struct {
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (f *fakeFetcher) Fetch(_ context.Context, link string) (readability.Article, error) {
+	f.mu.Lock()
+	f.calls[link]++
+	f.mu.Unlock()
+
+	time.Sleep(time.Millisecond)
+
+	if link == "https://test.com/broken" {
+		return readability.Article{}, errors.New("no article content")
+	}
+	return readability.Article{Content: "<p>статья " + link + "</p>", Image: link + "/og.png"}, nil
+}
+
+func TestReadability(t *testing.T) {
+
+	const feed = "https://test.com/rss"
+
+	f := &fakeFetcher{calls: map[string]int{}}
+	p := New(log.New(io.Discard, "", 0), Readability(f, []string{feed})).Workers(4)
+
+	var items []item
+	for i := 0; i < 10; i++ {
+		items = append(items, item{Link: fmt.Sprintf("https://test.com/%d", i), Source: feed})
+	}
+	items[0].Image = "https://test.com/rss.png" // картинка из rss-ленты важнее
+	items = append(items,
+		item{Link: "https://test.com/broken", Source: feed},
+		item{Link: "https://other.com/1", Source: "https://other.com/rss"})
+
+	in := make(chan container, 2)
+	in <- container{Items: items}
+	in <- container{Items: items} // повторный опрос ленты
+	close(in)
+
+	var got []container
+	for v := range p.Run(context.Background(), in) {
+		got = append(got, v)
+	}
+
+	if len(got) != 2 || len(got[0].Items) != len(items) {
+		t.Fatalf("Pipeline.Run() got containers = %d, want = %d", len(got), 2)
+	}
+
+	// порядок новостей сохраняется
+	for i := 0; i < 10; i++ {
+		it := got[0].Items[i]
+		if it.Link != items[i].Link || it.Article != "<p>статья "+it.Link+"</p>" {
+			t.Errorf("Readability() got link = %s, article = %q", it.Link, it.Article)
+		}
+	}
+
+	if img := got[0].Items[0].Image; img != items[0].Image {
+		t.Errorf("Readability() got image = %q, want = %q", img, items[0].Image)
+	}
+	if img, want := got[0].Items[1].Image, items[1].Link+"/og.png"; img != want {
+		t.Errorf("Readability() got image = %q, want = %q", img, want)
+	}
+
+	if a := got[0].Items[11].Article; a != "" {
+		t.Errorf("Readability() got article for other feed = %q, want empty", a)
+	}
+
+	for link, n := range f.calls {
+		if n != 1 {
+			t.Errorf("Readability() got fetches of %s = %d, want = %d", link, n, 1)
+		}
+	}
+	if _, ok := f.calls["https://other.com/1"]; ok {
+		t.Errorf("Readability() fetched item of other feed")
+	}
+
+	if s := p.Stats(); s.Errs != 1 || s.Passed != uint(2*len(items)) {
+		t.Errorf("Pipeline.Run() got stats = %+v", s)
+	}
+}
+
+func TestReadability_retry(t *testing.T) {
+
+	const link = "https://test.com/broken"
+
+	f := &fakeFetcher{calls: map[string]int{}}
+	stage := Readability(f, nil)
+	ctx := context.Background()
+
+	fixed := time.Now()
+	now = func() time.Time { return fixed }
+	defer func() { now = time.Now }()
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := stage.Process(ctx, item{Link: link}); i == 0 && err == nil {
+			t.Fatalf("Readability() error = %v, want error", err)
+		}
+	}
+	if n := f.calls[link]; n != 1 {
+		t.Fatalf("Readability() got fetches = %d, want = %d", n, 1)
+	}
+
+	// после retryFailed неудачная загрузка повторяется
+	fixed = fixed.Add(retryFailed + time.Second)
+	if _, _, err := stage.Process(ctx, item{Link: link}); err == nil {
+		t.Fatalf("Readability() error = %v, want error", err)
+	}
+	if n := f.calls[link]; n != 2 {
+		t.Errorf("Readability() got fetches = %d, want = %d", n, 2)
+	}
+}
@@ -0,0 +1,134 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"news/pkg/sanitize"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// встроенные шаги
+func init() {
+	Register("trim", func(json.RawMessage) (Stage, error) {
+		return Trim(), nil
+	})
+	Register("limit", func(params json.RawMessage) (Stage, error) {
+		var p struct {
+			Title       int `json:"title"`       // максимум символов в заголовке
+			Description int `json:"description"` // максимум символов в описании
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if p.Title < 0 || p.Description < 0 {
+			return nil, fmt.Errorf("negative limit")
+		}
+		return Limit(p.Title, p.Description), nil
+	})
+	Register("require_link", func(json.RawMessage) (Stage, error) {
+		return RequireLink(), nil
+	})
+	Register("clamp_future", func(params json.RawMessage) (Stage, error) {
+		var p struct {
+			Tolerance int `json:"tolerance"` // допустимое опережение в минутах
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if p.Tolerance < 0 {
+			return nil, fmt.Errorf("negative tolerance %d", p.Tolerance)
+		}
+		return ClampFuture(time.Minute * time.Duration(p.Tolerance)), nil
+	})
+}
+
+// decodeParams декодирует параметры шага, отсутствующие
+// параметры оставляют значения по-умолчанию
+func decodeParams(params json.RawMessage, v any) error {
+	if len(params) == 0 {
+		return nil
+	}
+	return json.Unmarshal(params, v)
+}
+
+// Trim убирает пробельные символы по краям заголовка, описания,
+// его html и ссылки, а повторяющиеся пробелы в заголовке заменяет одним
+func Trim() Stage {
+	return StageFunc{
+		StageName: "trim",
+		Func: func(_ context.Context, it item) (item, bool, error) {
+			it.Title = strings.Join(strings.Fields(it.Title), " ")
+			it.Description = strings.TrimSpace(it.Description)
+			it.HTML = strings.TrimSpace(it.HTML)
+			it.Link = strings.TrimSpace(it.Link)
+			return it, true, nil
+		},
+	}
+}
+
+// Limit обрезает заголовок и описание до title и description
+// символов соответственно. html описания обрезается до того же
+// числа символов текста, см. sanitize.Truncate. Ноль означает
+// отсутствие ограничения
+func Limit(title, description int) Stage {
+	return StageFunc{
+		StageName: "limit",
+		Func: func(_ context.Context, it item) (item, bool, error) {
+			it.Title = truncate(it.Title, title)
+			it.Description = truncate(it.Description, description)
+			if description > 0 && it.HTML != "" {
+				it.HTML = sanitize.Truncate(it.HTML, description)
+			}
+			return it, true, nil
+		},
+	}
+}
+
+// truncate обрезает строку до n символов, заменяя
+// последний символ многоточием
+func truncate(s string, n int) string {
+	if n <= 0 || utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	r := []rune(s)
+	return strings.TrimSpace(string(r[:n-1])) + "…"
+}
+
+// RequireLink отбрасывает новости без ссылки или со ссылкой,
+// которая не является абсолютным http(s) адресом. Ссылка -
+// ключ новости в БД, без неё новость не сохранить
+func RequireLink() Stage {
+	return StageFunc{
+		StageName: "require_link",
+		Func: func(_ context.Context, it item) (item, bool, error) {
+			u, err := url.Parse(it.Link)
+			if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+				return it, false, nil
+			}
+			return it, true, nil
+		},
+	}
+}
+
+// now - текущее время, подменяется в тестах
+var now = time.Now
+
+// ClampFuture заменяет текущим временем дату публикации новостей
+// из будущего, если она опережает текущее время больше чем на tolerance.
+// Иначе такие новости навсегда остаются в начале ленты
+func ClampFuture(tolerance time.Duration) Stage {
+	return StageFunc{
+		StageName: "clamp_future",
+		Func: func(_ context.Context, it item) (item, bool, error) {
+			t := now()
+			if it.PubDate > t.Add(tolerance).Unix() {
+				it.PubDate = t.Unix()
+			}
+			return it, true, nil
+		},
+	}
+}
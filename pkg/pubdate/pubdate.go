@@ -0,0 +1,199 @@
+// Package pubdate разбирает даты публикации из rss-лент.
+// Ленты пишут даты как придётся: по RFC 822 и RFC 1123,
+// по ISO 8601, без дня недели, с названием часового пояса
+// вместо смещения, с названиями месяцев на разных языках.
+// Parse приводит такие строки к нескольким известным
+// форматам и пробует их по очереди
+package pubdate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ErrUnknownFormat - дату не удалось разобрать ни одним форматом
+var ErrUnknownFormat = errors.New("unknown date format")
+
+// isoLayouts - форматы ISO 8601 и RFC 3339, строка с такой
+// датой пробуется до нормализации
+var isoLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05Z0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// layouts - форматы нормализованной строки: без дня недели
+// и запятых, месяц по-английски, часовой пояс смещением
+var layouts = func() []string {
+	dates := []string{"2 Jan 2006", "2 Jan 06", "Jan 2 2006", "2.1.2006"}
+	clocks := []string{"15:04:05", "15:04"}
+	zones := []string{" -0700", ""}
+
+	var out []string
+	for _, d := range dates {
+		for _, c := range clocks {
+			for _, z := range zones {
+				out = append(out, d+" "+c+z)
+			}
+		}
+		out = append(out, d)
+	}
+
+	// time.UnixDate и time.ANSIC без дня недели
+	return append(out, "Jan 2 15:04:05 -0700 2006", "Jan 2 15:04:05 2006")
+}()
+
+// zones - смещения часовых поясов, которые
+// встречаются в rss-лентах под названием
+var zones = map[string]string{
+	"Z": "+0000", "UT": "+0000", "UTC": "+0000", "GMT": "+0000",
+	"EST": "-0500", "EDT": "-0400", "CST": "-0600", "CDT": "-0500",
+	"MST": "-0700", "MDT": "-0600", "PST": "-0800", "PDT": "-0700",
+	"BST": "+0100", "CET": "+0100", "CEST": "+0200",
+	"EET": "+0200", "EEST": "+0300", "MSK": "+0300",
+	"IST": "+0530", "JST": "+0900",
+}
+
+// months - названия месяцев на английском, русском,
+// украинском, немецком, французском и испанском
+// в именительном и родительном падежах и сокращения
+var months = func() map[string]time.Month {
+	names := [12][]string{
+		{"january", "январь", "января", "янв", "січень", "січня", "січ", "januar", "jän", "janvier", "janv", "enero", "ene"},
+		{"february", "февраль", "февраля", "фев", "лютий", "лютого", "лют", "februar", "février", "févr", "fév", "febrero"},
+		{"march", "март", "марта", "мар", "березень", "березня", "бер", "märz", "mär", "mars", "marzo"},
+		{"april", "апрель", "апреля", "апр", "квітень", "квітня", "квіт", "avril", "avr", "abril", "abr"},
+		{"may", "май", "мая", "травень", "травня", "трав", "mai", "mayo"},
+		{"june", "июнь", "июня", "июн", "червень", "червня", "черв", "juni", "juin", "junio"},
+		{"july", "июль", "июля", "июл", "липень", "липня", "лип", "juli", "juillet", "juil", "julio"},
+		{"august", "август", "августа", "авг", "серпень", "серпня", "серп", "août", "agosto", "ago"},
+		{"september", "сентябрь", "сентября", "сен", "сент", "вересень", "вересня", "вер", "septembre", "septiembre", "sept"},
+		{"october", "октябрь", "октября", "окт", "жовтень", "жовтня", "жовт", "oktober", "octobre", "octubre"},
+		{"november", "ноябрь", "ноября", "ноя", "листопад", "листопада", "лист", "novembre", "noviembre"},
+		{"december", "декабрь", "декабря", "дек", "грудень", "грудня", "груд", "dezember", "dez", "décembre", "déc", "diciembre", "dic"},
+	}
+
+	m := make(map[string]time.Month)
+	for i, list := range names {
+		month := time.Month(i + 1)
+		m[strings.ToLower(month.String()[:3])] = month
+		for _, name := range list {
+			m[name] = month
+		}
+	}
+	return m
+}()
+
+// noise - слова, которые можно пропустить
+var noise = map[string]bool{"г": true, "года": true, "at": true, "um": true, "à": true}
+
+// Parse разбирает дату публикации. Дата без часового
+// пояса считается датой в UTC
+func Parse(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, ErrUnknownFormat
+	}
+
+	for _, layout := range isoLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+
+	n, err := normalize(s)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, n); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("%w: %q", ErrUnknownFormat, s)
+}
+
+// normalize приводит дату к виду, понятному layouts:
+// убирает день недели, запятые и лишние слова, заменяет
+// название месяца английским сокращением, а название
+// часового пояса - смещением
+func normalize(s string) (string, error) {
+	fields := strings.Fields(s)
+	out := make([]string, 0, len(fields))
+
+	for i, f := range fields {
+		comma := strings.HasSuffix(f, ",")
+		f = strings.Trim(f, ",")
+		if f == "" {
+			continue
+		}
+
+		word := strings.ToLower(strings.TrimSuffix(f, "."))
+
+		switch {
+		case !isWord(word):
+			out = append(out, offset(f))
+
+		case zones[strings.ToUpper(word)] != "":
+			out = append(out, zones[strings.ToUpper(word)])
+
+		// день недели стоит первым и обычно отделён запятой,
+		// "Mar, 15 Mar 2022" - вторник, "Mar 15 2022" - март
+		case i == 0 && (comma || months[word] == 0):
+			continue
+
+		case months[word] != 0:
+			out = append(out, months[word].String()[:3])
+
+		case noise[word]:
+			continue
+
+		default:
+			return "", fmt.Errorf("%w: unknown word %q", ErrUnknownFormat, f)
+		}
+	}
+
+	return strings.Join(out, " "), nil
+}
+
+// offset приводит смещение часового пояса
+// вида "+03:00" и "+03" к виду "+0300"
+func offset(f string) string {
+	if len(f) < 3 || (f[0] != '+' && f[0] != '-') {
+		return f
+	}
+	digits := strings.ReplaceAll(f[1:], ":", "")
+	if len(digits) == 2 {
+		digits += "00"
+	}
+	if len(digits) != 4 {
+		return f
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return f
+		}
+	}
+	return f[:1] + digits
+}
+
+func isWord(s string) bool {
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return s != ""
+}
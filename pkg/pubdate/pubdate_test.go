@@ -0,0 +1,57 @@
+package pubdate
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	// Thu, 16 Jun 2022 10:14:28 +0300
+	const want = 1655363668
+
+	tests := []struct {
+		name string
+		s    string
+		want int64
+	}{
+		{"RFC1123Z", "Thu, 16 Jun 2022 10:14:28 +0300", want},
+		{"RFC1123 с MSK", "Thu, 16 Jun 2022 10:14:28 MSK", want},
+		{"без дня недели", "16 Jun 2022 10:14:28 +0300", want},
+		{"однозначный день", "Thu, 16 Jun 2022 07:14:28 GMT", want},
+		{"смещение с двоеточием", "16 Jun 2022 10:14:28 +03:00", want},
+		{"EST", "Thu, 16 Jun 2022 02:14:28 EST", want},
+		{"RFC3339", "2022-06-16T10:14:28+03:00", want},
+		{"RFC3339 с долями секунды", "2022-06-16T07:14:28.000Z", want},
+		{"ISO без пояса", "2022-06-16 07:14:28", want},
+		{"UnixDate", "Thu Jun 16 10:14:28 MSK 2022", want},
+		{"ANSIC", "Thu Jun 16 07:14:28 2022", want},
+		{"полное название месяца", "Thursday, 16 June 2022 10:14:28 +0300", want},
+		{"русский месяц", "Чт, 16 июня 2022 г. 10:14:28 MSK", want},
+		{"немецкий месяц", "Do, 16 Juni 2022 10:14:28 +0200", want + 3600},
+		{"французский месяц", "jeu., 16 juin 2022 10:14:28 +0300", want},
+		{"испанский месяц", "16 junio 2022 10:14:28 +0300", want},
+		{"без секунд", "Thu, 16 Jun 2022 10:14 +0300", want - 28},
+		{"только дата", "16 Jun 2022", want - 7*3600 - 14*60 - 28},
+		{"мартовский вторник", "Mar, 15 Mar 2022 00:00:00 GMT", 1647302400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.s)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.s, err)
+			}
+			if got.Unix() != tt.want {
+				t.Errorf("Parse(%q) got = %d (%v), want = %d", tt.s, got.Unix(), got.UTC(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_error(t *testing.T) {
+	for _, s := range []string{"", "вчера", "16 Jun 2022 10:14:28 XYZ", "Thu, 16 Foo 2022"} {
+		if _, err := Parse(s); !errors.Is(err, ErrUnknownFormat) {
+			t.Errorf("Parse(%q) got error = %v, want = %v", s, err, ErrUnknownFormat)
+		}
+	}
+}
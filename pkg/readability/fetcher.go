@@ -0,0 +1,211 @@
+package readability
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+// ErrForbiddenAddress - страница находится во внутренней сети.
+// Ссылки новостей приходят извне, поэтому по ним нельзя
+// обращаться к localhost, частным и link-local адресам
+var ErrForbiddenAddress = errors.New("readability: address is not public")
+
+// maxRedirects - сколько редиректов проходит загрузка страницы
+const maxRedirects = 10
+
+// hostsSweep - при каком числе сайтов в Fetcher.next из него
+// удаляются сайты, к которым уже можно обращаться
+const hostsSweep = 1000
+
+// Fetcher загружает страницы новостей и извлекает из них текст статьи.
+// Число одновременных загрузок и частота запросов к одному сайту
+// ограничены независимо от опроса rss-лент
+type Fetcher struct {
+	client   *http.Client
+	dialer   *net.Dialer   // проверяет адрес каждого соединения, в том числе после редиректа
+	sem      chan struct{} // бюджет одновременных загрузок
+	interval time.Duration // минимальный интервал между запросами к одному сайту
+	maxBytes int64         // максимальный размер страницы
+
+	mu   sync.Mutex
+	next map[string]time.Time // когда можно обращаться к сайту в следующий раз
+}
+
+// NewFetcher возвращает новый объект *Fetcher, который загружает
+// не больше concurrency страниц одновременно и обращается к одному
+// сайту не чаще rate раз в секунду. Нулевые значения заменяются
+// на 4 загрузки и 1 запрос в секунду
+func NewFetcher(concurrency int, rate float64) *Fetcher {
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+	if rate <= 0 {
+		rate = 1
+	}
+
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: publicOnly}
+
+	// без прокси: иначе проверялся бы адрес прокси, а не сайта
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &Fetcher{
+		client: &http.Client{
+			Timeout:       10 * time.Second,
+			Transport:     transport,
+			CheckRedirect: checkRedirect,
+		},
+		dialer:   dialer,
+		sem:      make(chan struct{}, concurrency),
+		interval: time.Duration(float64(time.Second) / rate),
+		maxBytes: 2 << 20,
+		next:     make(map[string]time.Time),
+	}
+}
+
+// publicOnly не даёт соединиться с адресом во внутренней сети.
+// Адрес проверяется после разрешения имени, поэтому имя,
+// которое указывает на внутренний адрес, тоже не проходит
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if !isPublic(net.ParseIP(host)) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+	return nil
+}
+
+// isPublic сообщает, что ip - адрес в интернете
+func isPublic(ip net.IP) bool {
+	return ip != nil &&
+		!ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() &&
+		!ip.IsUnspecified()
+}
+
+// checkRedirect ограничивает редиректы: только http(s) и сразу
+// отказ, если в адресе указан внутренний ip. Адреса с именами
+// проверяет publicOnly при соединении
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("readability: stopped after %d redirects", maxRedirects)
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return fmt.Errorf("readability: redirect to '%s' scheme", req.URL.Scheme)
+	}
+	if ip := net.ParseIP(req.URL.Hostname()); ip != nil && !isPublic(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, ip)
+	}
+	return nil
+}
+
+// Timeout устанавливает таймаут загрузки страницы
+func (f *Fetcher) Timeout(d time.Duration) *Fetcher {
+	if d > 0 {
+		f.client.Timeout = d
+	}
+	return f
+}
+
+// MaxBytes устанавливает максимальный размер страницы,
+// остаток страницы не читается
+func (f *Fetcher) MaxBytes(n int64) *Fetcher {
+	if n > 0 {
+		f.maxBytes = n
+	}
+	return f
+}
+
+// Fetch загружает страницу по ссылке link и извлекает из неё статью
+func (f *Fetcher) Fetch(ctx context.Context, link string) (Article, error) {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return Article{}, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+
+	select {
+	case f.sem <- struct{}{}:
+		defer func() { <-f.sem }()
+	case <-ctx.Done():
+		return Article{}, ctx.Err()
+	}
+
+	if err := f.wait(ctx, req.URL.Host); err != nil {
+		return Article{}, err
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return Article{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Article{}, fmt.Errorf("readability: response code is %d", resp.StatusCode)
+	}
+
+	ct := resp.Header.Get("Content-Type")
+	if !strings.Contains(ct, "html") {
+		return Article{}, fmt.Errorf("readability: Content-Type is '%s', want 'text/html'", ct)
+	}
+
+	body, err := charset.NewReader(io.LimitReader(resp.Body, f.maxBytes), ct)
+	if err != nil {
+		return Article{}, err
+	}
+
+	// после редиректов относительные ссылки
+	// разрешаются относительно итогового адреса
+	return Extract(body, resp.Request.URL)
+}
+
+// wait ждёт, пока к сайту host можно будет обратиться
+func (f *Fetcher) wait(ctx context.Context, host string) error {
+	f.mu.Lock()
+	now := time.Now()
+	at := f.next[host]
+	if at.Before(now) {
+		at = now
+	}
+	if len(f.next) >= hostsSweep {
+		f.sweep(now)
+	}
+	f.next[host] = at.Add(f.interval)
+	f.mu.Unlock()
+
+	select {
+	case <-time.After(time.Until(at)):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sweep удаляет из f.next сайты, к которым уже можно обращаться,
+// иначе карта растёт с каждым новым сайтом. Вызывается под f.mu
+func (f *Fetcher) sweep(now time.Time) {
+	for host, at := range f.next {
+		if !at.After(now) {
+			delete(f.next, host)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+// Package readability извлекает основной текст статьи
+// со страницы новости эвристикой в духе Readability:
+// абзацы начисляют очки родительским блокам, блок
+// с наибольшим счётом считается текстом статьи
+package readability
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/url"
+	"news/pkg/sanitize"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ErrNoContent возвращается, если на странице
+// не нашлось блока, похожего на текст статьи
+var ErrNoContent = errors.New("readability: no article content")
+
+// minArticleLen - минимальная длина текста статьи в символах.
+// Блоки короче - скорее всего меню или подписи, а не статья
+const minArticleLen = 250
+
+// minParagraphLen - абзацы короче не учитываются при подсчёте очков
+const minParagraphLen = 25
+
+var (
+	positive = regexp.MustCompile(`(?i)article|body|content|entry|main|page|post|text|blog|story`)
+	negative = regexp.MustCompile(`(?i)comment|meta|footer|footnote|sidebar|sponsor|banner|share|social|related|promo|nav|menu|widget|subscribe|advert|\bads?\b`)
+)
+
+// unlikely - теги, которые точно не содержат текст статьи
+var unlikely = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Nav:      true,
+	atom.Header:   true,
+	atom.Footer:   true,
+	atom.Aside:    true,
+	atom.Form:     true,
+	atom.Iframe:   true,
+	atom.Button:   true,
+	atom.Select:   true,
+}
+
+// Article - статья, извлечённая со страницы новости
+type Article struct {
+	Content string // основной текст статьи в безопасном html
+	Image   string // картинка статьи из og:image, если есть
+}
+
+// Extract возвращает статью из html-страницы r,
+// ссылки разрешаются относительно base
+func Extract(r io.Reader, base *url.URL) (Article, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return Article{}, err
+	}
+
+	image := metaImage(doc, base) // до очистки, meta может быть где угодно
+
+	clean(doc)
+
+	scores := make(map[*html.Node]float64)
+
+	walk(doc, func(n *html.Node) {
+		if n.DataAtom != atom.P && n.DataAtom != atom.Pre && n.DataAtom != atom.Td {
+			return
+		}
+
+		text := textOf(n)
+		length := utf8.RuneCountInString(text)
+		if length < minParagraphLen || n.Parent == nil {
+			return
+		}
+
+		// запятые и длина - признаки связного текста
+		score := 1 + float64(strings.Count(text, ",")+strings.Count(text, "，"))
+		score += float64(min(length/100, 3))
+
+		parent, grand := n.Parent, n.Parent.Parent
+		if _, ok := scores[parent]; !ok {
+			scores[parent] = weight(parent)
+		}
+		scores[parent] += score
+
+		if grand != nil && grand.Type == html.ElementNode {
+			if _, ok := scores[grand]; !ok {
+				scores[grand] = weight(grand)
+			}
+			scores[grand] += score / 2
+		}
+	})
+
+	var top *html.Node
+	var best float64
+
+	for n, s := range scores {
+		// ссылки в блоке снижают вероятность, что это статья
+		s *= 1 - linkDensity(n)
+		if top == nil || s > best {
+			top, best = n, s
+		}
+	}
+
+	if top == nil || utf8.RuneCountInString(textOf(top)) < minArticleLen {
+		return Article{}, ErrNoContent
+	}
+
+	var buf bytes.Buffer
+	for c := top.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&buf, c); err != nil {
+			return Article{}, err
+		}
+	}
+
+	return Article{Content: sanitize.HTML(buf.String(), base), Image: image}, nil
+}
+
+// metaImage возвращает адрес картинки из мета-тегов
+// og:image или twitter:image
+func metaImage(doc *html.Node, base *url.URL) string {
+	var og, twitter string
+
+	walk(doc, func(n *html.Node) {
+		if n.DataAtom != atom.Meta {
+			return
+		}
+
+		var key, content string
+		for _, a := range n.Attr {
+			switch a.Key {
+			case "property", "name":
+				key = strings.ToLower(a.Val)
+			case "content":
+				content = a.Val
+			}
+		}
+
+		switch key {
+		case "og:image", "og:image:url", "og:image:secure_url":
+			if og == "" {
+				og = content
+			}
+		case "twitter:image", "twitter:image:src":
+			if twitter == "" {
+				twitter = content
+			}
+		}
+	})
+
+	for _, ref := range []string{og, twitter} {
+		if ref == "" {
+			continue
+		}
+		if u, ok := sanitize.ImageURL(ref, base); ok {
+			return u
+		}
+	}
+
+	return ""
+}
+
+// clean удаляет из документа теги, которые
+// не могут быть частью статьи, и комментарии
+func clean(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+
+		if c.Type == html.CommentNode || (c.Type == html.ElementNode && isUnlikely(c)) {
+			n.RemoveChild(c)
+		} else {
+			clean(c)
+		}
+
+		c = next
+	}
+}
+
+// isUnlikely сообщает, что элемент не может быть частью статьи:
+// по тегу или по классу и id, которые похожи на меню, рекламу и т.п.
+func isUnlikely(n *html.Node) bool {
+	if unlikely[n.DataAtom] {
+		return true
+	}
+
+	switch n.DataAtom {
+	case atom.Html, atom.Body, atom.Main, atom.Article:
+		return false // классы у этих тегов часто описывают всю страницу
+	}
+
+	ci := classAndID(n)
+	return negative.MatchString(ci) && !positive.MatchString(ci)
+}
+
+// weight - начальный счёт блока по тегу, классу и id
+func weight(n *html.Node) float64 {
+	var w float64
+
+	switch n.DataAtom {
+	case atom.Article:
+		w += 10
+	case atom.Div, atom.Main, atom.Section:
+		w += 5
+	case atom.Pre, atom.Td, atom.Blockquote:
+		w += 3
+	case atom.Ol, atom.Ul, atom.Dl, atom.Dd, atom.Dt, atom.Li, atom.Form:
+		w -= 3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		w -= 5
+	}
+
+	ci := classAndID(n)
+	if negative.MatchString(ci) {
+		w -= 25
+	}
+	if positive.MatchString(ci) {
+		w += 25
+	}
+
+	return w
+}
+
+// linkDensity - доля текста блока, которая приходится на ссылки
+func linkDensity(n *html.Node) float64 {
+	total := utf8.RuneCountInString(textOf(n))
+	if total == 0 {
+		return 0
+	}
+
+	var links int
+	walk(n, func(c *html.Node) {
+		if c.DataAtom == atom.A {
+			links += utf8.RuneCountInString(textOf(c))
+		}
+	})
+
+	return float64(links) / float64(total)
+}
+
+func classAndID(n *html.Node) string {
+	var s []string
+	for _, a := range n.Attr {
+		if a.Key == "class" || a.Key == "id" {
+			s = append(s, a.Val)
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+// textOf возвращает текст узла с нормализованными пробелами
+func textOf(n *html.Node) string {
+	var b strings.Builder
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			b.WriteByte(' ')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// walk обходит элементы дерева в глубину
+func walk(n *html.Node, f func(*html.Node)) {
+	if n.Type == html.ElementNode {
+		f(n)
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c, f)
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
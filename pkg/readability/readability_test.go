@@ -0,0 +1,223 @@
+package readability
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var paragraph = "Горутины - легковесные потоки выполнения, которыми управляет рантайм Go, " +
+	"а не операционная система, поэтому их можно запускать тысячами, не заботясь о памяти."
+
+var page = `<!DOCTYPE html>
+<html>
+<head>
+	<title>Статья</title>
+	<meta property="og:image" content="/images/cover.png">
+	<script>var x = 1;</script>
+</head>
+<body>
+	<nav class="menu"><a href="/">Главная</a> <a href="/news">Новости</a></nav>
+	<div class="sidebar">
+		<p>Подпишитесь на нашу рассылку, чтобы не пропустить новые статьи, обзоры и новости.</p>
+	</div>
+	<div class="post-content">
+		<h1>Конкурентность в Go</h1>
+		<p>` + paragraph + `</p>
+		<p>` + paragraph + `</p>
+		<p>Подробнее в <a href="/docs/effective_go">документации</a>, раздел о каналах и горутинах.</p>
+		<p>` + paragraph + `</p>
+	</div>
+	<div class="comments">
+		<p>Отличная статья, спасибо автору, жду продолжения про каналы и select!</p>
+	</div>
+	<footer>© 2022</footer>
+</body>
+</html>`
+
+func TestExtract(t *testing.T) {
+	base, _ := url.Parse("https://test.com/post/1")
+
+	got, err := Extract(strings.NewReader(page), base)
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"<h1>Конкурентность в Go</h1>",
+		"<p>" + paragraph + "</p>",
+		`href="https://test.com/docs/effective_go"`,
+	} {
+		if !strings.Contains(got.Content, want) {
+			t.Errorf("Extract() got = %q, want to contain %q", got.Content, want)
+		}
+	}
+
+	for _, unwanted := range []string{"Главная", "рассылку", "Отличная статья", "2022", "var x"} {
+		if strings.Contains(got.Content, unwanted) {
+			t.Errorf("Extract() got = %q, want not to contain %q", got.Content, unwanted)
+		}
+	}
+
+	if want := "https://test.com/images/cover.png"; got.Image != want {
+		t.Errorf("Extract() got image = %q, want = %q", got.Image, want)
+	}
+
+	_, err = Extract(strings.NewReader(`<html><body><p>Коротко.</p></body></html>`), base)
+	if !errors.Is(err, ErrNoContent) {
+		t.Errorf("Extract() got error = %v, want = %v", err, ErrNoContent)
+	}
+}
+
+func TestFetcher_Fetch(t *testing.T) {
+
+	var mu sync.Mutex
+	var active, peak int
+	var times []time.Time
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		active++
+		if active > peak {
+			peak = active
+		}
+		times = append(times, time.Now())
+		mu.Unlock()
+
+		defer func() {
+			mu.Lock()
+			active--
+			mu.Unlock()
+		}()
+
+		if r.URL.Path == "/metadata" {
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+			return
+		}
+		if r.URL.Path == "/json" {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintln(w, `{}`)
+			return
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintln(w, page)
+	}))
+	defer ts.Close()
+
+	t.Run("ограничения", func(t *testing.T) {
+		rate := 50.0 // запросов в секунду
+		f := local(NewFetcher(2, rate))
+
+		var wg sync.WaitGroup
+		for i := 0; i < 6; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if _, err := f.Fetch(context.Background(), fmt.Sprintf("%s/post/%d", ts.URL, i)); err != nil {
+					t.Errorf("Fetcher.Fetch() error = %v", err)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if peak > 2 {
+			t.Errorf("Fetcher.Fetch() got concurrent requests = %d, want <= %d", peak, 2)
+		}
+
+		// запросы к одному сайту не чаще rate в секунду,
+		// с допуском на точность таймеров
+		span := times[len(times)-1].Sub(times[0])
+		want := time.Duration(float64(time.Second)/rate) * time.Duration(len(times)-1)
+		if span < want-10*time.Millisecond {
+			t.Errorf("Fetcher.Fetch() got %d requests in %v, want >= %v", len(times), span, want)
+		}
+	})
+
+	t.Run("не_html", func(t *testing.T) {
+		if _, err := local(NewFetcher(1, 100)).Fetch(context.Background(), ts.URL+"/json"); err == nil {
+			t.Errorf("Fetcher.Fetch() expected error, got nothing")
+		}
+	})
+
+	t.Run("внутренняя_сеть", func(t *testing.T) {
+		// тестовый сервер слушает 127.0.0.1
+		if _, err := NewFetcher(1, 100).Fetch(context.Background(), ts.URL+"/post/1"); !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("Fetcher.Fetch() error = %v, want = %v", err, ErrForbiddenAddress)
+		}
+	})
+
+	t.Run("редирект_во_внутреннюю_сеть", func(t *testing.T) {
+		if _, err := local(NewFetcher(1, 100)).Fetch(context.Background(), ts.URL+"/metadata"); !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("Fetcher.Fetch() error = %v, want = %v", err, ErrForbiddenAddress)
+		}
+	})
+}
+
+// local разрешает f загружать страницы тестового сервера на 127.0.0.1,
+// редиректы по-прежнему проверяются
+func local(f *Fetcher) *Fetcher {
+	f.dialer.Control = nil
+	return f
+}
+
+func TestFetcher_wait(t *testing.T) {
+	f := NewFetcher(1, 1000)
+	ctx := context.Background()
+
+	for i := 0; i < hostsSweep+10; i++ {
+		if err := f.wait(ctx, fmt.Sprintf("host%d.com", i)); err != nil {
+			t.Fatalf("Fetcher.wait() error = %v", err)
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	if err := f.wait(ctx, "last.com"); err != nil {
+		t.Fatalf("Fetcher.wait() error = %v", err)
+	}
+
+	// сайты, к которым уже можно обращаться, забываются
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.next) >= hostsSweep {
+		t.Errorf("Fetcher.wait() got hosts = %d, want < %d", len(f.next), hostsSweep)
+	}
+}
+
+func Test_isPublic(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"fd00::1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"::ffff:127.0.0.1", false},
+		{"224.0.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := isPublic(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("isPublic(%s) got = %v, want = %v", tt.ip, got, tt.want)
+		}
+	}
+}
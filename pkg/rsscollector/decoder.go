@@ -0,0 +1,294 @@
+package rsscollector
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+)
+
+// maxFeedSize - максимальный размер rss-ленты в байтах
+const maxFeedSize = 20 << 20
+
+// PartialError - лента прочитана частично: новости,
+// которые не удалось декодировать, пропущены,
+// остальные возвращаются вместе с этой ошибкой
+type PartialError struct {
+	Errs []error // по ошибке на каждую пропущенную новость
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("skipped %d invalid items", len(e.Errs))
+}
+
+// ItemError - ошибка декодирования одной новости
+type ItemError struct {
+	Offset int // смещение начала новости в ленте
+	Err    error
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("item at offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
+// decodeFeed читает rss-ленту по одной новости. Ошибка
+// в новости не портит всю ленту: после ошибки декодирование
+// продолжается со следующего тега <item>. Перед разбором лента
+// перекодируется в UTF-8 и исправляются частые ошибки: BOM,
+// неэкранированные амперсанды, недопустимые в xml символы.
+// Если ни одной новости прочитать не удалось из-за ошибки
+// вне новостей, возвращается эта ошибка. Если часть новостей
+// пропущена, возвращается *PartialError
+func decodeFeed(r io.Reader, contentType string) (container, error) {
+	body, err := io.ReadAll(io.LimitReader(r, maxFeedSize))
+	if err != nil {
+		return container{}, err
+	}
+
+	body = repair(toUTF8(body, contentType))
+
+	var cont container
+	var skipped []error
+
+	// вложенность тегов сохраняется между перезапусками
+	// декодера, чтобы найти <language> канала после битой новости
+	depth := 0
+	for offset := 0; offset < len(body); {
+		next, d, err := decodeItems(body, offset, depth, &cont, &skipped)
+		if err != nil {
+			if len(cont.Items) == 0 && len(skipped) == 0 {
+				return cont, err
+			}
+			break // мусор после последней новости
+		}
+		offset, depth = next, d
+	}
+
+	if len(skipped) > 0 {
+		return cont, &PartialError{Errs: skipped}
+	}
+	return cont, nil
+}
+
+// decodeItems декодирует ленту с позиции offset, на которой
+// вложенность тегов равна depth, пока не встретит ошибку. Если
+// ошибка в новости, то она добавляется в skipped и возвращаются
+// позиция, с которой можно продолжить, и вложенность на ней.
+// Ошибка вне новостей возвращается как есть
+func decodeItems(body []byte, offset, depth int, cont *container, skipped *[]error) (int, int, error) {
+	d := xmlDecoderWithSettings(bytes.NewReader(body[offset:]))
+
+	for {
+		pos := offset + int(d.InputOffset())
+
+		tok, err := d.Token()
+		if err == io.EOF {
+			return len(body), depth, nil
+		}
+		if err != nil {
+			return 0, depth, err
+		}
+
+		switch t := tok.(type) {
+		case xml.EndElement:
+			depth--
+
+		case xml.StartElement:
+			switch {
+			case t.Name.Local == "item":
+				var it item
+				if err := d.DecodeElement(&it, &t); err != nil {
+					*skipped = append(*skipped, &ItemError{Offset: pos, Err: err})
+					// декодер после ошибки непригоден, начинаем заново
+					// на той же вложенности, что и битая новость
+					return resume(body, pos), depth, nil
+				}
+				cont.Items = append(cont.Items, it)
+
+			case t.Name.Local == "language" && depth == 2 && cont.Language == "":
+				if err := d.DecodeElement(&cont.Language, &t); err != nil {
+					return 0, depth, err
+				}
+
+			default:
+				depth++
+			}
+		}
+	}
+}
+
+// resume возвращает позицию, с которой продолжить декодирование
+// после битой новости, которая начинается в from: следующий тег
+// <item> или тег <language> канала между концом битой новости
+// и следующим тегом <item>, чтобы не потерять язык ленты
+func resume(body []byte, from int) int {
+	next := nextTag(body, from+1, "item")
+	if end := bytes.Index(body[from:next], []byte("</item>")); end >= 0 {
+		if lang := nextTag(body[:next], from+end, "language"); lang < next {
+			return lang
+		}
+	}
+	return next
+}
+
+// nextTag возвращает позицию следующего тега name
+// начиная с from или конец ленты, если тега нет
+func nextTag(body []byte, from int, name string) int {
+	open := []byte("<" + name)
+	for from < len(body) {
+		i := bytes.Index(body[from:], open)
+		if i < 0 {
+			break
+		}
+		from += i
+		// <item> или <item attr="...">, но не <items>
+		if end := from + len(open); end < len(body) && strings.IndexByte(" \t\r\n/>", body[end]) >= 0 {
+			return from
+		}
+		from++
+	}
+	return len(body)
+}
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+
+	// кодировка из объявления <?xml ... encoding="windows-1251"?>
+	xmlEncoding = regexp.MustCompile(`^\s*<\?xml[^>]*?encoding\s*=\s*["']([A-Za-z0-9._:-]+)["']`)
+)
+
+// toUTF8 перекодирует ленту в UTF-8. Кодировка определяется
+// по BOM, затем по объявлению xml, затем по заголовку
+// Content-Type. Байты, которые не являются UTF-8 после
+// перекодирования, заменяются на символ U+FFFD
+func toUTF8(body []byte, contentType string) []byte {
+	var label string
+
+	switch {
+	case bytes.HasPrefix(body, utf8BOM):
+		body, label = body[len(utf8BOM):], "utf-8"
+	case bytes.HasPrefix(body, utf16BEBOM):
+		label = "utf-16be"
+	case bytes.HasPrefix(body, utf16LEBOM):
+		label = "utf-16le"
+	}
+
+	if m := xmlEncoding.FindSubmatch(body); label == "" && m != nil {
+		label = string(m[1])
+	}
+	if _, params, err := mime.ParseMediaType(contentType); label == "" && err == nil {
+		label = params["charset"]
+	}
+
+	if enc, name := charset.Lookup(label); enc != nil && name != "utf-8" {
+		if b, err := enc.NewDecoder().Bytes(body); err == nil {
+			// после UTF-16 в начале остаётся BOM
+			body = bytes.TrimPrefix(b, utf8BOM)
+		}
+	}
+
+	// лента уже в UTF-8, а объявление осталось старое
+	if m := xmlEncoding.FindSubmatchIndex(body); m != nil {
+		body = append(body[:m[2]:m[2]], append([]byte("UTF-8"), body[m[3]:]...)...)
+	}
+
+	return bytes.ToValidUTF8(body, []byte("\uFFFD"))
+}
+
+// repair исправляет частые ошибки в rss-лентах: удаляет символы,
+// недопустимые в xml, и экранирует амперсанды, за которыми
+// не следует известная сущность. Секции CDATA не меняются,
+// кроме удаления недопустимых символов
+func repair(body []byte) []byte {
+	out := make([]byte, 0, len(body)+len(body)/64)
+
+	for i := 0; i < len(body); {
+		if bytes.HasPrefix(body[i:], []byte("<![CDATA[")) {
+			end := bytes.Index(body[i:], []byte("]]>"))
+			if end < 0 {
+				end = len(body) - i
+			} else {
+				end += len("]]>")
+			}
+			out = appendValid(out, body[i:i+end])
+			i += end
+			continue
+		}
+
+		r, size := utf8.DecodeRune(body[i:])
+		switch {
+		case r == '&' && !isEntity(body[i:]):
+			out = append(out, "&amp;"...)
+		case isXMLChar(r):
+			out = append(out, body[i:i+size]...)
+		}
+		i += size
+	}
+
+	return out
+}
+
+// appendValid добавляет к out символы b, допустимые в xml
+func appendValid(out, b []byte) []byte {
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		if isXMLChar(r) {
+			out = append(out, b[:size]...)
+		}
+		b = b[size:]
+	}
+	return out
+}
+
+// isXMLChar сообщает, что символ допустим в xml
+func isXMLChar(r rune) bool {
+	return r == '\t' || r == '\n' || r == '\r' ||
+		(r >= 0x20 && r <= 0xD7FF) || (r >= 0xE000 && r <= 0xFFFD) || r >= 0x10000
+}
+
+// isEntity сообщает, что b начинается с числовой сущности
+// или сущности, которую знает декодер: xml или html
+func isEntity(b []byte) bool {
+	end := bytes.IndexByte(b, ';')
+	if end < 2 || end > 32 {
+		return false
+	}
+	name := string(b[1:end])
+
+	if strings.HasPrefix(name, "#x") || strings.HasPrefix(name, "#X") {
+		return len(name) > 2 && strings.Trim(name[2:], "0123456789abcdefABCDEF") == ""
+	}
+	if strings.HasPrefix(name, "#") {
+		return len(name) > 1 && strings.Trim(name[1:], "0123456789") == ""
+	}
+
+	switch name {
+	case "amp", "lt", "gt", "quot", "apos":
+		return true
+	}
+	_, ok := xml.HTMLEntity[name]
+	return ok
+}
+
+// isPartial сообщает, что лента прочитана частично,
+// и возвращает ошибки пропущенных новостей
+func isPartial(err error) ([]error, bool) {
+	var perr *PartialError
+	if errors.As(err, &perr) {
+		return perr.Errs, true
+	}
+	return nil, false
+}
@@ -0,0 +1,159 @@
+package rsscollector
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func Test_decodeFeed(t *testing.T) {
+
+	feed := func(items ...string) string {
+		return `<?xml version="1.0" encoding="UTF-8"?><rss><channel>
+			<language>ru</language><title>Лента</title>` +
+			strings.Join(items, "\n") + `</channel></rss>`
+	}
+
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		wantTitles  []string
+		wantSkipped int
+	}{
+		{
+			name:       "корректная лента",
+			body:       []byte(feed(`<item><title>1</title></item>`, `<item><title>2</title></item>`)),
+			wantTitles: []string{"1", "2"},
+		},
+		{
+			name: "битая новость пропускается",
+			body: []byte(feed(
+				`<item><title>1</title></item>`,
+				`<item><title>2</titl></item>`,
+				`<item><title>3 &#0; </title></item>`,
+				`<item><title>4</title></item>`)),
+			wantTitles:  []string{"1", "4"},
+			wantSkipped: 2,
+		},
+		{
+			name:       "неэкранированный амперсанд и html-сущность",
+			body:       []byte(feed(`<item><title>AT&T &amp; Co&nbsp;Ltd &copy</title><description><![CDATA[a &amp; b]]></description></item>`)),
+			wantTitles: []string{"AT&T & Co\u00a0Ltd &copy"},
+		},
+		{
+			name:       "BOM и управляющие символы",
+			body:       append([]byte{0xEF, 0xBB, 0xBF}, feed("<item><title>1\x0b\x00</title></item>")...),
+			wantTitles: []string{"1"},
+		},
+		{
+			name:       "невалидный UTF-8",
+			body:       []byte(feed("<item><title>a\xffb</title></item>")),
+			wantTitles: []string{"a\uFFFDb"},
+		},
+		{
+			name:        "кодировка из Content-Type",
+			body:        encode(t, charmap.Windows1251.NewEncoder().Bytes, `<rss><channel><item><title>Новость</title></item></channel></rss>`),
+			contentType: "text/xml; charset=windows-1251",
+			wantTitles:  []string{"Новость"},
+		},
+		{
+			name:       "кодировка из объявления",
+			body:       encode(t, charmap.Windows1251.NewEncoder().Bytes, strings.Replace(feed(`<item><title>Новость</title></item>`), "UTF-8", "windows-1251", 1)),
+			wantTitles: []string{"Новость"},
+		},
+		{
+			name:       "UTF-16 с BOM",
+			body:       encode(t, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().Bytes, strings.Replace(feed(`<item><title>Новость</title></item>`), "UTF-8", "UTF-16", 1)),
+			wantTitles: []string{"Новость"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeFeed(bytes.NewReader(tt.body), tt.contentType)
+
+			skipped, _ := isPartial(err)
+			if err != nil && skipped == nil {
+				t.Fatalf("decodeFeed() error = %v", err)
+			}
+			if len(skipped) != tt.wantSkipped {
+				t.Errorf("decodeFeed() got skipped = %d (%v), want = %d", len(skipped), skipped, tt.wantSkipped)
+			}
+
+			var titles []string
+			for _, it := range got.Items {
+				titles = append(titles, it.Title)
+			}
+			if strings.Join(titles, "|") != strings.Join(tt.wantTitles, "|") {
+				t.Errorf("decodeFeed() got titles = %q, want = %q", titles, tt.wantTitles)
+			}
+		})
+	}
+}
+
+func Test_decodeFeed_language(t *testing.T) {
+	body := `<rss><channel><language>ru-RU</language>
+		<item><title>1</title><language>en</language></item></channel></rss>`
+
+	got, err := decodeFeed(strings.NewReader(body), "")
+	if err != nil {
+		t.Fatalf("decodeFeed() error = %v", err)
+	}
+	if got.Language != "ru-RU" {
+		t.Errorf("decodeFeed() got language = %q, want = %q", got.Language, "ru-RU")
+	}
+}
+
+func Test_decodeFeed_language_skipped(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{
+			name: "язык после новостей",
+			body: `<rss><channel><item><title>1</titl></item>
+				<item><title>2</title></item><language>uk</language></channel></rss>`,
+		},
+		{
+			name: "язык сразу после битой новости",
+			body: `<rss><channel><item><title>1</titl><language>en</language></item>
+				<language>uk</language><item><title>2</title></item></channel></rss>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeFeed(strings.NewReader(tt.body), "")
+			if skipped, _ := isPartial(err); len(skipped) != 1 {
+				t.Fatalf("decodeFeed() error = %v, want 1 skipped item", err)
+			}
+			if got.Language != "uk" || len(got.Items) != 1 || got.Items[0].Title != "2" {
+				t.Errorf("decodeFeed() got language = %q, items = %d, want = %q, %d", got.Language, len(got.Items), "uk", 1)
+			}
+		})
+	}
+}
+
+func Test_decodeFeed_error(t *testing.T) {
+	_, err := decodeFeed(strings.NewReader("<html><body>not a feed</p></html>"), "")
+	if err == nil {
+		t.Fatalf("decodeFeed() error = nil, want error")
+	}
+	var perr *PartialError
+	if errors.As(err, &perr) {
+		t.Errorf("decodeFeed() got partial error = %v, want syntax error", err)
+	}
+}
+
+func encode(t *testing.T, f func([]byte) ([]byte, error), s string) []byte {
+	b, err := f([]byte(s))
+	if err != nil {
+		t.Fatalf("encode() error = %v", err)
+	}
+	return b
+}
@@ -64,11 +64,12 @@ func (c *Collector) Poll(ctx context.Context, interval time.Duration, links []st
 
 		go func(id int, values chan<- container, errors chan<- error, url string) {
 
-			var fails uint // считаем ошибки во время работы горутины
-			var polls uint // считаем опросы горутины
+			var fails uint   // считаем ошибки во время работы горутины
+			var polls uint   // считаем опросы горутины
+			var skipped uint // считаем пропущенные новости
 
 			defer func() {
-				c.logTotal(id, url, polls, fails) // лог общего итога
+				c.logTotal(id, url, polls, fails, skipped) // лог общего итога
 				close(values)
 				close(errors)
 			}()
@@ -76,6 +77,15 @@ func (c *Collector) Poll(ctx context.Context, interval time.Duration, links []st
 			poll := func() {
 				polls++
 				v, err := c.poll(ctx, url) // выполняем опрос
+
+				// лента прочитана частично - это не ошибка опроса,
+				// отдаём прочитанные новости и логгируем пропущенные
+				if errs, ok := isPartial(err); ok {
+					skipped += uint(len(errs))
+					c.logSkipped(id, url, errs)
+					err = nil
+				}
+
 				if err == nil {
 					values <- v
 				} else {
@@ -111,8 +121,14 @@ func (c *Collector) log(id int, url string, received int, err error) {
 	}
 }
 
-func (c *Collector) logTotal(id int, url string, polls, errors uint) {
-	c.logger.Printf("[INFO] unit #%03d >> totals: polls=%d errors=%d >> task=%s", id, polls, errors, url)
+func (c *Collector) logSkipped(id int, url string, errs []error) {
+	for _, err := range errs {
+		c.logger.Printf("[ERROR] unit #%03d >> skipped item: %v; task=%s", id, err, url)
+	}
+}
+
+func (c *Collector) logTotal(id int, url string, polls, errors, skipped uint) {
+	c.logger.Printf("[INFO] unit #%03d >> totals: polls=%d errors=%d skipped_items=%d >> task=%s", id, polls, errors, skipped, url)
 }
 
 // merge демультиплексирует(собирает) переданные
@@ -177,20 +193,45 @@ func poll(ctx context.Context, url string) (container, error) {
 	request := requestFunc(req) // функция для выполнения запроса по сети
 
 	var cont container
-	// функция чтения тела ответа
-	decfunc := responseHandlerFunc(func(r *http.Response) error {
-		return xmlDecoderWithSettings(r.Body).Decode(&cont)
+	// функция чтения тела ответа, ленту читаем по одной
+	// новости, чтобы ошибка в новости не портила всю ленту
+	decfunc := responseHandlerFunc(func(r *http.Response) (err error) {
+		cont, err = decodeFeed(r.Body, r.Header.Get("Content-Type"))
+		return err
 	})
 
 	chain := bodyCloser(statusChecker(xmlEnforcer(decfunc))) // цепочка обработчиков ответа
 
-	return cont, request(chain)
+	// при частичном чтении ленты возвращаем
+	// прочитанные новости вместе с ошибкой
+	err = request(chain)
+	if _, ok := isPartial(err); err != nil && !ok {
+		return cont, err
+	}
+
+	// помечаем новости rss-лентой, из которой они
+	// получены, и определяем язык новостей. Если дату
+	// публикации разобрать не удалось, берём время, когда
+	// новость увидели впервые: хранилище не перезаписывает
+	// уже сохранённые новости, поэтому при следующих
+	// опросах дата не изменится
+	seen := time.Now().Unix()
+	for i := range cont.Items {
+		cont.Items[i].Source = url
+		cont.Items[i].DetectLang(cont.Language)
+		if cont.Items[i].PubDate == 0 {
+			cont.Items[i].PubDate = seen
+		}
+	}
+
+	return cont, err
 }
 
 // decoderWithSettings возвращает *xml.Decoder с настройками
 func xmlDecoderWithSettings(r io.Reader) *xml.Decoder {
 	decoder := xml.NewDecoder(r)
 	decoder.CharsetReader = charset.NewReaderLabel // некоторые rss-каналы возвращают не UTF-8
+	decoder.Entity = xml.HTMLEntity                // и используют html-сущности вроде &nbsp;
 	return decoder
 }
 
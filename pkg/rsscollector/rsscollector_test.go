@@ -7,6 +7,7 @@ import (
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"sync"
 	"testing"
 	"time"
@@ -15,6 +16,7 @@ import (
 const xmlblob = `
 		<rss>
 			<channel>
+				<language>ru-RU</language>
 				<item>
 					<title>Тестовый заголовок</title>
 					<link>https://test.com</link>
@@ -32,7 +34,9 @@ func Test_poll(t *testing.T) {
 		Title:       "Тестовый заголовок",
 		PubDate:     1655363668,
 		Description: "Тестовое описание",
+		HTML:        "Тестовое описание",
 		Link:        "https://test.com",
+		Lang:        "ru",
 	}
 
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -41,6 +45,8 @@ func Test_poll(t *testing.T) {
 	}))
 	defer ts.Close()
 
+	want.Source = ts.URL
+
 	got, err := poll(context.Background(), ts.URL)
 	if err != nil {
 		t.Fatalf("poll() error = %v", err)
@@ -50,11 +56,46 @@ func Test_poll(t *testing.T) {
 		t.Fatalf("poll() got results = %d, want = %d", len(got.Items), 1)
 	}
 
-	if got.Items[0] != want {
+	if !reflect.DeepEqual(got.Items[0], want) {
 		t.Fatalf("poll() got = %v, want = %v", got, want)
 	}
 }
 
+func Test_poll_badDate(t *testing.T) {
+
+	blob := `<rss><channel>
+		<item><title>1</title><link>https://test.com/1</link><pubDate>вчера</pubDate></item>
+		<item><title>2</title><link>https://test.com/2</link><pubDate>16 июня 2022 10:14:28 MSK</pubDate></item>
+		<item><title>3</title><link>https://test.com/3</link></item>
+	</channel></rss>`
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprintln(w, blob)
+	}))
+	defer ts.Close()
+
+	before := time.Now().Unix()
+
+	got, err := poll(context.Background(), ts.URL)
+	if err != nil {
+		t.Fatalf("poll() error = %v", err)
+	}
+	if len(got.Items) != 3 {
+		t.Fatalf("poll() got results = %d, want = %d", len(got.Items), 3)
+	}
+
+	// дата не разобрана или не указана - время первого опроса
+	for _, i := range []int{0, 2} {
+		if d := got.Items[i].PubDate; d < before || d > time.Now().Unix() {
+			t.Errorf("poll() got pubDate = %d, want first seen time >= %d", d, before)
+		}
+	}
+	if d := got.Items[1].PubDate; d != 1655363668 {
+		t.Errorf("poll() got pubDate = %d, want = %d", d, 1655363668)
+	}
+}
+
 func TestCollector_Poll(t *testing.T) {
 
 	var m sync.Mutex
@@ -0,0 +1,422 @@
+// Package sanitize готовит html из описаний rss-новостей к показу:
+// Text возвращает простой текст, HTML - безопасный html
+// только с разрешёнными тегами и атрибутами
+package sanitize
+
+import (
+	"html"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	nethtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// allowed - разрешённые теги и их разрешённые атрибуты.
+// Остальные теги удаляются, а их содержимое остаётся
+var allowed = map[atom.Atom][]string{
+	atom.A:          {"href", "title"},
+	atom.Img:        {"src", "alt", "title", "width", "height"},
+	atom.P:          nil,
+	atom.Br:         nil,
+	atom.Hr:         nil,
+	atom.H1:         nil,
+	atom.H2:         nil,
+	atom.H3:         nil,
+	atom.H4:         nil,
+	atom.H5:         nil,
+	atom.H6:         nil,
+	atom.Ul:         nil,
+	atom.Ol:         nil,
+	atom.Li:         nil,
+	atom.B:          nil,
+	atom.Strong:     nil,
+	atom.I:          nil,
+	atom.Em:         nil,
+	atom.U:          nil,
+	atom.S:          nil,
+	atom.Sub:        nil,
+	atom.Sup:        nil,
+	atom.Blockquote: nil,
+	atom.Code:       nil,
+	atom.Pre:        nil,
+	atom.Figure:     nil,
+	atom.Figcaption: nil,
+}
+
+// dropped - теги, которые удаляются вместе с содержимым
+var dropped = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Iframe:   true,
+	atom.Object:   true,
+	atom.Embed:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Form:     true,
+	atom.Svg:      true,
+	atom.Math:     true,
+	atom.Head:     true,
+	atom.Title:    true,
+}
+
+// blocks - теги, которые в простом тексте начинают новую строку
+var blocks = map[atom.Atom]bool{
+	atom.P:          true,
+	atom.Br:         true,
+	atom.Hr:         true,
+	atom.Div:        true,
+	atom.H1:         true,
+	atom.H2:         true,
+	atom.H3:         true,
+	atom.H4:         true,
+	atom.H5:         true,
+	atom.H6:         true,
+	atom.Ul:         true,
+	atom.Ol:         true,
+	atom.Li:         true,
+	atom.Blockquote: true,
+	atom.Pre:        true,
+	atom.Figure:     true,
+	atom.Figcaption: true,
+	atom.Table:      true,
+	atom.Tr:         true,
+}
+
+// schemes - разрешённые схемы ссылок
+var schemes = map[string]bool{
+	"http":   true,
+	"https":  true,
+	"mailto": true,
+}
+
+// parse разбирает фрагмент html как содержимое <body>
+func parse(s string) []*nethtml.Node {
+	body := &nethtml.Node{Type: nethtml.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := nethtml.ParseFragment(strings.NewReader(s), body)
+	if err != nil {
+		// html.ParseFragment возвращает ошибку
+		// только при ошибке чтения, здесь её не бывает
+		return []*nethtml.Node{{Type: nethtml.TextNode, Data: s}}
+	}
+	return nodes
+}
+
+// Text возвращает простой текст из фрагмента html: теги удаляются,
+// html-сущности заменяются символами, блочные теги разделяют строки,
+// повторяющиеся пробелы заменяются одним
+func Text(s string) string {
+	var b strings.Builder
+	for _, n := range parse(s) {
+		text(&b, n)
+	}
+
+	lines := strings.Split(b.String(), "\n")
+	out := lines[:0]
+	for _, l := range lines {
+		if l = strings.Join(strings.Fields(l), " "); l != "" {
+			out = append(out, l)
+		}
+	}
+
+	return strings.Join(out, "\n")
+}
+
+func text(b *strings.Builder, n *nethtml.Node) {
+	switch n.Type {
+	case nethtml.TextNode:
+		b.WriteString(n.Data)
+		return
+	case nethtml.ElementNode:
+		if dropped[n.DataAtom] {
+			return
+		}
+	}
+
+	block := n.Type == nethtml.ElementNode && blocks[n.DataAtom]
+	if block {
+		b.WriteByte('\n')
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		text(b, c)
+	}
+	if block {
+		b.WriteByte('\n')
+	}
+}
+
+// HTML возвращает фрагмент html, в котором оставлены только
+// разрешённые теги и атрибуты. Относительные ссылки разрешаются
+// относительно base, ссылки с другими схемами, кроме http(s)
+// и mailto, удаляются. base может быть nil
+func HTML(s string, base *url.URL) string {
+	var b strings.Builder
+	for _, n := range parse(s) {
+		render(&b, n, base)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func render(b *strings.Builder, n *nethtml.Node, base *url.URL) {
+	switch n.Type {
+	case nethtml.TextNode:
+		b.WriteString(html.EscapeString(n.Data))
+		return
+	case nethtml.ElementNode:
+		// обрабатывается ниже
+	case nethtml.DocumentNode:
+		renderChildren(b, n, base)
+		return
+	default: // комментарии, doctype
+		return
+	}
+
+	if dropped[n.DataAtom] {
+		return
+	}
+
+	attrs, ok := allowed[n.DataAtom]
+	if !ok {
+		renderChildren(b, n, base) // неразрешённый тег убираем, содержимое оставляем
+		return
+	}
+
+	kept := keepAttrs(n, attrs, base)
+
+	if n.DataAtom == atom.Img && !has(kept, "src") {
+		return // картинка без адреса бесполезна
+	}
+	if n.DataAtom == atom.A && has(kept, "href") {
+		kept = append(kept,
+			nethtml.Attribute{Key: "rel", Val: "nofollow noopener noreferrer"},
+			nethtml.Attribute{Key: "target", Val: "_blank"})
+	}
+
+	b.WriteByte('<')
+	b.WriteString(n.Data)
+	for _, a := range kept {
+		b.WriteByte(' ')
+		b.WriteString(a.Key)
+		b.WriteString(`="`)
+		b.WriteString(html.EscapeString(a.Val))
+		b.WriteByte('"')
+	}
+	b.WriteByte('>')
+
+	if void(n.DataAtom) {
+		return
+	}
+
+	renderChildren(b, n, base)
+
+	b.WriteString("</")
+	b.WriteString(n.Data)
+	b.WriteByte('>')
+}
+
+func renderChildren(b *strings.Builder, n *nethtml.Node, base *url.URL) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		render(b, c, base)
+	}
+}
+
+// keepAttrs возвращает разрешённые атрибуты тега,
+// адреса в href и src приводятся к абсолютным
+func keepAttrs(n *nethtml.Node, allowed []string, base *url.URL) []nethtml.Attribute {
+	var kept []nethtml.Attribute
+
+	for _, a := range n.Attr {
+		if a.Namespace != "" || !contains(allowed, a.Key) {
+			continue
+		}
+
+		if a.Key == "href" || a.Key == "src" {
+			u, ok := resolve(a.Val, base)
+			if !ok {
+				continue
+			}
+			a.Val = u
+		}
+
+		kept = append(kept, nethtml.Attribute{Key: a.Key, Val: a.Val})
+	}
+
+	return kept
+}
+
+// resolve разрешает ссылку относительно base
+// и проверяет, что её схема разрешена
+func resolve(ref string, base *url.URL) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return "", false
+	}
+
+	if !u.IsAbs() && base != nil {
+		u = base.ResolveReference(u)
+	}
+
+	if !schemes[strings.ToLower(u.Scheme)] {
+		return "", false // javascript:, data:, или относительная ссылка без base
+	}
+
+	return u.String(), true
+}
+
+// ImageURL разрешает адрес картинки ref относительно base
+// и возвращает его, если это http(s) адрес. base может быть nil
+func ImageURL(ref string, base *url.URL) (string, bool) {
+	u, ok := resolve(ref, base)
+	if !ok || strings.HasPrefix(u, "mailto:") {
+		return "", false
+	}
+	return u, true
+}
+
+// FirstImage возвращает абсолютный адрес первой картинки
+// во фрагменте html или пустую строку, если картинок нет
+func FirstImage(s string, base *url.URL) string {
+	var src string
+
+	var find func(*nethtml.Node) bool
+	find = func(n *nethtml.Node) bool {
+		if n.Type == nethtml.ElementNode {
+			if dropped[n.DataAtom] {
+				return false
+			}
+			if n.DataAtom == atom.Img {
+				for _, a := range n.Attr {
+					if a.Key == "src" {
+						if u, ok := ImageURL(a.Val, base); ok {
+							src = u
+							return true
+						}
+					}
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if find(c) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, n := range parse(s) {
+		if find(n) {
+			break
+		}
+	}
+
+	return src
+}
+
+// Truncate обрезает фрагмент html до n символов текста, заменяя
+// последний символ многоточием, как обрезается описание новости.
+// Теги, открытые до места обрезки, закрываются. Фрагмент сначала
+// проходит через HTML. Ноль означает отсутствие ограничения
+func Truncate(s string, n int) string {
+	s = HTML(s, nil)
+	nodes := parse(s)
+
+	total := 0
+	for _, node := range nodes {
+		total += textLen(node)
+	}
+	if n <= 0 || total <= n {
+		return s
+	}
+
+	var b strings.Builder
+	left := n - 1
+	for _, node := range nodes {
+		if !cut(&b, node, &left) {
+			break
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// textLen возвращает число символов текста в узле n
+func textLen(n *nethtml.Node) int {
+	if n.Type == nethtml.TextNode {
+		return utf8.RuneCountInString(n.Data)
+	}
+	l := 0
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		l += textLen(c)
+	}
+	return l
+}
+
+// cut выводит узел n уже очищенного фрагмента, пока в left
+// остаются символы текста. Возвращает false, если текст обрезан
+func cut(b *strings.Builder, n *nethtml.Node, left *int) bool {
+	switch n.Type {
+	case nethtml.TextNode:
+		r := []rune(n.Data)
+		if len(r) <= *left {
+			*left -= len(r)
+			b.WriteString(html.EscapeString(n.Data))
+			return true
+		}
+		b.WriteString(html.EscapeString(strings.TrimSpace(string(r[:*left]))))
+		b.WriteString("…")
+		*left = 0
+		return false
+	case nethtml.ElementNode:
+		// обрабатывается ниже
+	default:
+		return true
+	}
+
+	b.WriteByte('<')
+	b.WriteString(n.Data)
+	for _, a := range n.Attr {
+		b.WriteByte(' ')
+		b.WriteString(a.Key)
+		b.WriteString(`="`)
+		b.WriteString(html.EscapeString(a.Val))
+		b.WriteByte('"')
+	}
+	b.WriteByte('>')
+
+	if void(n.DataAtom) {
+		return true
+	}
+
+	ok := true
+	for c := n.FirstChild; c != nil && ok; c = c.NextSibling {
+		ok = cut(b, c, left)
+	}
+
+	b.WriteString("</")
+	b.WriteString(n.Data)
+	b.WriteByte('>')
+	return ok
+}
+
+// void сообщает, что у тега нет закрывающего тега
+func void(a atom.Atom) bool {
+	return a == atom.Br || a == atom.Hr || a == atom.Img
+}
+
+func has(attrs []nethtml.Attribute, key string) bool {
+	for _, a := range attrs {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package sanitize
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "сущности",
+			in:   "Go&nbsp;1.19 &amp; дженерики &laquo;в деле&raquo;",
+			want: "Go 1.19 & дженерики «в деле»",
+		},
+		{
+			name: "абзацы_и_списки",
+			in:   "<p>Первый абзац</p><p>Второй <b>абзац</b></p><ul><li>один</li><li>два</li></ul>",
+			want: "Первый абзац\nВторой абзац\nодин\nдва",
+		},
+		{
+			name: "скрипты_удаляются_с_содержимым",
+			in:   "Текст<script>alert(1)</script><style>p{}</style> новости<br>ещё",
+			want: "Текст новости\nещё",
+		},
+		{
+			name: "простой_текст",
+			in:   "  a < b,   но b > c  ",
+			want: "a < b, но b > c",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Text(tt.in); got != tt.want {
+				t.Errorf("Text() got = %q, want = %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTML(t *testing.T) {
+	base, _ := url.Parse("https://habr.com/ru/post/672746/")
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "разрешённые_теги",
+			in:   `<p class="x" style="color:red">Текст <em>новости</em></p>`,
+			want: `<p>Текст <em>новости</em></p>`,
+		},
+		{
+			name: "относительные_ссылки",
+			in:   `<a href="/ru/users/gopher/" onclick="steal()">автор</a><img src="img/1.png" alt="схема">`,
+			want: `<a href="https://habr.com/ru/users/gopher/" rel="nofollow noopener noreferrer" target="_blank">автор</a>` +
+				`<img src="https://habr.com/ru/post/672746/img/1.png" alt="схема">`,
+		},
+		{
+			name: "опасные_ссылки",
+			in:   `<a href="javascript:alert(1)">жми</a><img src="data:image/png;base64,AAAA">`,
+			want: `<a>жми</a>`,
+		},
+		{
+			name: "скрипты_и_фреймы",
+			in:   `<div>до<script>alert(1)</script><iframe src="https://evil.com"></iframe>после</div>`,
+			want: `допосле`,
+		},
+		{
+			name: "экранирование_текста",
+			in:   `&lt;script&gt;alert(1)&lt;/script&gt; &nbsp;`,
+			want: "&lt;script&gt;alert(1)&lt;/script&gt;",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTML(tt.in, base); got != tt.want {
+				t.Errorf("HTML() got = %q, want = %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		n    int
+		want string
+	}{
+		{
+			name: "короткий_текст",
+			in:   `<p>Текст</p>`,
+			n:    10,
+			want: `<p>Текст</p>`,
+		},
+		{
+			name: "без_ограничения",
+			in:   `<p>Текст новости</p>`,
+			n:    0,
+			want: `<p>Текст новости</p>`,
+		},
+		{
+			name: "теги_закрываются",
+			in:   `<p>Текст <a href="https://test.com">длинной</a> новости</p><p>ещё</p>`,
+			n:    10,
+			want: `<p>Текст <a href="https://test.com" rel="nofollow noopener noreferrer" target="_blank">дли…</a></p>`,
+		},
+		{
+			name: "экранирование",
+			in:   `<b>&lt;script&gt;</b> и ещё`,
+			n:    9,
+			want: `<b>&lt;script&gt;</b>…`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Truncate(tt.in, tt.n); got != tt.want {
+				t.Errorf("Truncate() got = %q, want = %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFirstImage(t *testing.T) {
+	base, _ := url.Parse("https://habr.com/ru/post/672746/")
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "первая_картинка",
+			in:   `<p>Текст</p><img src="/img/1.png"><img src="/img/2.png">`,
+			want: "https://habr.com/img/1.png",
+		},
+		{
+			name: "картинки_нет",
+			in:   `<p>Текст</p>`,
+			want: "",
+		},
+		{
+			name: "пропуск_недопустимых",
+			in:   `<script><img src="/in-script.png"></script><img src="javascript:x"><img src="https://test.com/ok.jpg">`,
+			want: "https://test.com/ok.jpg",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FirstImage(tt.in, base); got != tt.want {
+				t.Errorf("FirstImage() got = %q, want = %q", got, tt.want)
+			}
+		})
+	}
+}
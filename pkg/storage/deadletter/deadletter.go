@@ -0,0 +1,260 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"news/pkg/storage"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNotFound возвращается, когда в хранилище
+// нет записи с запрошенным номером
+var ErrNotFound = errors.New("deadletter: not found")
+
+// Letter - новость, которую не удалось записать в БД
+type Letter struct {
+	ID       uint64       `json:"id"`       // номер записи
+	Item     storage.Item `json:"item"`     // новость
+	Error    string       `json:"error"`    // последняя ошибка записи
+	Attempts int          `json:"attempts"` // сколько раз новость не удалось записать
+	FailedAt int64        `json:"failedAt"` // время последней ошибки, unix timestamp
+}
+
+// DefaultMax - сколько записей хранит Store по-умолчанию
+const DefaultMax = 10000
+
+// Store - хранилище новостей, которые не удалось записать в БД.
+// Если задан путь к файлу, то содержимое хранилища сохраняется
+// в файл после каждого изменения, иначе хранится только в памяти
+type Store struct {
+	mu      sync.Mutex
+	path    string
+	seq     uint64
+	letters map[uint64]Letter
+	// сколько записей хранить, при переполнении
+	// удаляются самые старые, по-умолчанию DefaultMax
+	max int
+	// получатель новостей, записанных в БД
+	// при повторной записи, по-умолчанию nil
+	pub publisher
+}
+
+// publisher - получатель новостей, записанных в БД,
+// например *broker.Broker
+type publisher interface {
+	Publish(items ...storage.Item)
+}
+
+// file - формат файла хранилища
+type file struct {
+	Seq     uint64   `json:"seq"`
+	Letters []Letter `json:"letters"`
+}
+
+// Open открывает хранилище в файле path. Если path пустой,
+// хранилище работает только в памяти
+func Open(path string) (*Store, error) {
+	s := &Store{
+		path:    path,
+		letters: make(map[uint64]Letter),
+		max:     DefaultMax,
+	}
+
+	if path == "" {
+		return s, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var f file
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, err
+	}
+
+	s.seq = f.Seq
+	for _, l := range f.Letters {
+		s.letters[l.ID] = l
+	}
+
+	return s, nil
+}
+
+// Max устанавливает, сколько записей хранить. Если БД долго
+// не принимает новости, хранилище не растёт бесконечно:
+// при переполнении удаляются самые старые записи
+func (s *Store) Max(n int) *Store {
+	if n > 0 {
+		s.mu.Lock()
+		s.max = n
+		s.mu.Unlock()
+	}
+	return s
+}
+
+// Publisher подключает получателя новостей, которые
+// записаны в БД при повторной записи, так же, как
+// streamwriter.StreamWriter публикует новые новости
+func (s *Store) Publisher(p publisher) *Store {
+	s.pub = p
+	return s
+}
+
+// Put сохраняет новость, которую не удалось записать из-за ошибки
+// cause. Если хранилище заполнено, самые старые записи удаляются
+func (s *Store) Put(item storage.Item, cause error) (Letter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.letters) >= s.max {
+		delete(s.letters, s.oldest())
+	}
+
+	s.seq++
+	l := Letter{
+		ID:       s.seq,
+		Item:     item,
+		Error:    cause.Error(),
+		Attempts: 1,
+		FailedAt: time.Now().Unix(),
+	}
+	s.letters[l.ID] = l
+
+	return l, s.save()
+}
+
+// List возвращает все записи по возрастанию номера
+func (s *Store) List() []Letter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]Letter, 0, len(s.letters))
+	for _, l := range s.letters {
+		out = append(out, l)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+
+	return out
+}
+
+// Get возвращает запись с номером id
+func (s *Store) Get(id uint64) (Letter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.letters[id]
+	if !ok {
+		return Letter{}, ErrNotFound
+	}
+	return l, nil
+}
+
+// Delete удаляет запись с номером id
+func (s *Store) Delete(id uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.letters[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.letters, id)
+
+	return s.save()
+}
+
+// Len возвращает количество записей
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.letters)
+}
+
+// Replay повторно записывает новость с номером id в БД db.
+// При успехе запись удаляется из хранилища, а записанная
+// новость публикуется, иначе у записи обновляются ошибка
+// и количество попыток
+func (s *Store) Replay(ctx context.Context, db storage.Storage, id uint64) error {
+	l, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+
+	res, werr := db.AddItems(ctx, []storage.Item{l.Item})
+	if s.pub != nil && len(res.Inserted) > 0 {
+		s.pub.Publish(res.Inserted...)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.letters[id]; !ok {
+		return ErrNotFound // запись удалили, пока шла запись в БД
+	}
+
+	if werr == nil {
+		delete(s.letters, id)
+		return s.save()
+	}
+
+	l.Error = werr.Error()
+	l.Attempts++
+	l.FailedAt = time.Now().Unix()
+	s.letters[id] = l
+
+	if err := s.save(); err != nil {
+		return err
+	}
+	return werr
+}
+
+// oldest возвращает номер самой старой записи,
+// вызывается под блокировкой
+func (s *Store) oldest() uint64 {
+	var min uint64
+	for id := range s.letters {
+		if min == 0 || id < min {
+			min = id
+		}
+	}
+	return min
+}
+
+// save записывает хранилище в файл, вызывается под блокировкой
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	f := file{Seq: s.seq, Letters: make([]Letter, 0, len(s.letters))}
+	for _, l := range s.letters {
+		f.Letters = append(f.Letters, l)
+	}
+	sort.Slice(f.Letters, func(i, j int) bool { return f.Letters[i].ID < f.Letters[j].ID })
+
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+
+	// пишем во временный файл и переименовываем,
+	// чтобы не оставить файл недописанным
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,144 @@
+package deadletter
+
+import (
+	"context"
+	"errors"
+	"news/pkg/storage"
+	"news/pkg/storage/memdb"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var testItem = storage.Item{
+	Title:       "Заголовок",
+	Description: "Описание",
+	PubDate:     -1,
+	Link:        "https://test.com/1",
+}
+
+// failingDB - хранилище, которое не принимает ни одной новости
+type failingDB struct {
+	*memdb.MemDB
+}
+
+func (db failingDB) AddItems(_ context.Context, _ []storage.Item) (storage.AddResult, error) {
+	return storage.AddResult{}, errors.New("check constraint violation")
+}
+
+// recorder запоминает опубликованные новости
+type recorder struct {
+	items []storage.Item
+}
+
+func (r *recorder) Publish(items ...storage.Item) {
+	r.items = append(r.items, items...)
+}
+
+func TestStore(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "deadletters.json")
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	l, err := s.Put(testItem, errors.New("pub_date check"))
+	if err != nil {
+		t.Fatalf("Store.Put() error = %v", err)
+	}
+
+	t.Run("сохранение_в_файл", func(t *testing.T) {
+		s2, err := Open(path)
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+
+		got, err := s2.Get(l.ID)
+		if err != nil {
+			t.Fatalf("Store.Get() error = %v", err)
+		}
+
+		if !reflect.DeepEqual(got, l) {
+			t.Fatalf("Store.Get() got = %v, want = %v", got, l)
+		}
+	})
+
+	t.Run("неудачный_replay", func(t *testing.T) {
+		err := s.Replay(context.Background(), failingDB{memdb.New()}, l.ID)
+		if err == nil {
+			t.Fatalf("Store.Replay() expected error, got nothing")
+		}
+
+		got, err := s.Get(l.ID)
+		if err != nil {
+			t.Fatalf("Store.Get() error = %v", err)
+		}
+
+		if got.Attempts != 2 || got.Error != "check constraint violation" {
+			t.Fatalf("Store.Replay() got attempts = %d, error = %q", got.Attempts, got.Error)
+		}
+	})
+
+	t.Run("успешный_replay", func(t *testing.T) {
+		rec := &recorder{}
+		s.Publisher(rec)
+		defer s.Publisher(nil)
+
+		err := s.Replay(context.Background(), memdb.New(), l.ID)
+		if err != nil {
+			t.Fatalf("Store.Replay() error = %v", err)
+		}
+
+		// подписчики получают новость так же, как при первой записи
+		if len(rec.items) != 1 || rec.items[0].Link != testItem.Link {
+			t.Fatalf("Store.Replay() published = %v, want = %v", rec.items, testItem)
+		}
+
+		if _, err := s.Get(l.ID); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Store.Get() got error = %v, want = %v", err, ErrNotFound)
+		}
+
+		if s.Len() != 0 {
+			t.Fatalf("Store.Len() got = %d, want = %d", s.Len(), 0)
+		}
+	})
+
+	t.Run("переполнение", func(t *testing.T) {
+		s, err := Open("")
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		s.Max(2)
+
+		var ids []uint64
+		for i := 0; i < 3; i++ {
+			l, err := s.Put(testItem, errors.New("pub_date check"))
+			if err != nil {
+				t.Fatalf("Store.Put() error = %v", err)
+			}
+			ids = append(ids, l.ID)
+		}
+
+		// самая старая запись вытеснена
+		if got := s.List(); len(got) != 2 || got[0].ID != ids[1] || got[1].ID != ids[2] {
+			t.Fatalf("Store.Put() got letters = %v, want ids = %v", got, ids[1:])
+		}
+	})
+
+	t.Run("удаление", func(t *testing.T) {
+		l, err := s.Put(testItem, errors.New("pub_date check"))
+		if err != nil {
+			t.Fatalf("Store.Put() error = %v", err)
+		}
+
+		if err := s.Delete(l.ID); err != nil {
+			t.Fatalf("Store.Delete() error = %v", err)
+		}
+
+		if err := s.Delete(l.ID); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Store.Delete() got error = %v, want = %v", err, ErrNotFound)
+		}
+	})
+}
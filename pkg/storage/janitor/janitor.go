@@ -0,0 +1,157 @@
+package janitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"log"
+	"news/pkg/storage"
+	"time"
+)
+
+// Metrics - метрики *Janitor. Реализует expvar.Var,
+// поэтому публикуется через expvar.Publish
+type Metrics struct {
+	runs     expvar.Int // выполненные проходы
+	byAge    expvar.Int // удалено новостей по возрасту, без удалённых самой БД (TTL-индекс mongodb)
+	bySource expvar.Int // удалено новостей сверх лимита rss-ленты
+	byTotal  expvar.Int // удалено новостей сверх общего лимита
+	errs     expvar.Int // полученные ошибки
+	lastRun  expvar.Int // время последнего прохода, unix timestamp
+}
+
+// NewMetrics возвращает новый объект *Metrics
+func NewMetrics() *Metrics {
+	return &Metrics{}
+}
+
+// String возвращает метрики в json
+func (m *Metrics) String() string {
+	b, _ := json.Marshal(map[string]json.RawMessage{
+		"runs":      json.RawMessage(m.runs.String()),
+		"by_age":    json.RawMessage(m.byAge.String()),
+		"by_source": json.RawMessage(m.bySource.String()),
+		"by_total":  json.RawMessage(m.byTotal.String()),
+		"errors":    json.RawMessage(m.errs.String()),
+		"last_run":  json.RawMessage(m.lastRun.String()),
+	})
+	return string(b)
+}
+
+// Janitor периодически удаляет из БД новости,
+// не подходящие под политику хранения
+type Janitor struct {
+	log       *log.Logger
+	pruner    storage.Pruner
+	retention storage.Retention
+	interval  time.Duration
+	// метрики проходов, по-умолчанию nil
+	metrics *Metrics
+	// когда установлен в true, логгирует каждый проход,
+	// даже если ничего не удалено, по-умолчанию false
+	debugMode bool
+}
+
+// New возвращает новый объект *Janitor. По-умолчанию
+// проход выполняется раз в час
+func New(log *log.Logger, pruner storage.Pruner, r storage.Retention) *Janitor {
+	return &Janitor{
+		log:       log,
+		pruner:    pruner,
+		retention: r,
+		interval:  time.Hour,
+		debugMode: false,
+	}
+}
+
+// Interval устанавливает период между проходами *Janitor
+func (j *Janitor) Interval(d time.Duration) *Janitor {
+	j.interval = d
+	return j
+}
+
+// Metrics устанавливает, куда записывать метрики проходов
+func (j *Janitor) Metrics(m *Metrics) *Janitor {
+	j.metrics = m
+	return j
+}
+
+// DebugMode переключает debug режим у *Janitor
+func (j *Janitor) DebugMode(on bool) *Janitor {
+	j.debugMode = on
+	return j
+}
+
+// Stats - статистика работы *Janitor
+type Stats struct {
+	Runs     uint // выполненные проходы
+	ByAge    uint // удалено новостей по возрасту
+	BySource uint // удалено новостей сверх лимита rss-ленты
+	ByTotal  uint // удалено новостей сверх общего лимита
+	Errs     uint // полученные ошибки
+}
+
+// Pruned - всего удалено новостей
+func (s Stats) Pruned() uint {
+	return s.ByAge + s.BySource + s.ByTotal
+}
+
+// Run выполняет первый проход сразу, затем повторяет их
+// с заданным интервалом до отмены контекста.
+// Возвращает статистику своей работы
+func (j *Janitor) Run(ctx context.Context) Stats {
+	var stats Stats
+
+	if j.retention.IsZero() {
+		// один проход: хранилище снимает правила, оставшиеся
+		// от прежней политики, например TTL-индекс mongodb
+		j.prune(ctx, &stats)
+		j.log.Printf("[INFO] retention policy is not set, nothing to prune")
+		return stats
+	}
+
+	j.prune(ctx, &stats) // первый проход сразу
+
+	for {
+		select {
+		case <-time.After(j.interval):
+			j.prune(ctx, &stats)
+		case <-ctx.Done():
+			j.log.Printf("[INFO] totals: runs=%d pruned=%d by_age=%d by_source=%d by_total=%d errors=%d",
+				stats.Runs, stats.Pruned(), stats.ByAge, stats.BySource, stats.ByTotal, stats.Errs)
+			return stats
+		}
+	}
+}
+
+// prune выполняет один проход и обновляет статистику
+func (j *Janitor) prune(ctx context.Context, stats *Stats) {
+	res, err := j.pruner.Prune(ctx, j.retention)
+
+	stats.Runs++
+	stats.ByAge += uint(res.ByAge)
+	stats.BySource += uint(res.BySource)
+	stats.ByTotal += uint(res.ByTotal)
+
+	if err != nil && !errors.Is(err, context.Canceled) {
+		stats.Errs++
+		j.log.Printf("[ERROR] prune_error=%v", err)
+	}
+
+	if m := j.metrics; m != nil {
+		m.runs.Add(1)
+		m.byAge.Add(res.ByAge)
+		m.bySource.Add(res.BySource)
+		m.byTotal.Add(res.ByTotal)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			m.errs.Add(1)
+		}
+		m.lastRun.Set(time.Now().Unix())
+	}
+
+	if res.Total() > 0 || j.debugMode {
+		j.log.Printf("[INFO] pruned=%d by_age=%d by_source=%d by_total=%d",
+			res.Total(), res.ByAge, res.BySource, res.ByTotal)
+	}
+}
@@ -0,0 +1,104 @@
+package janitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"news/pkg/storage"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakePruner каждый проход "удаляет" по одной
+// новости каждого вида и считает вызовы
+type fakePruner struct {
+	mu    sync.Mutex
+	calls int
+	err   error
+	got   storage.Retention
+}
+
+func (f *fakePruner) Prune(_ context.Context, r storage.Retention) (storage.PruneResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	f.got = r
+	if f.err != nil {
+		return storage.PruneResult{}, f.err
+	}
+	return storage.PruneResult{ByAge: 1, BySource: 1, ByTotal: 1}, nil
+}
+
+func TestJanitor_Run(t *testing.T) {
+
+	r := storage.Retention{MaxAge: time.Hour, MaxPerSource: 10, MaxTotal: 100}
+
+	t.Run("статистика_проходов", func(t *testing.T) {
+		p := &fakePruner{}
+		j := New(log.New(io.Discard, "", 0), p, r).Interval(10 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 55*time.Millisecond)
+		defer cancel()
+
+		stats := j.Run(ctx)
+
+		if stats.Runs == 0 || stats.Runs != uint(p.calls) {
+			t.Fatalf("Janitor.Run() got runs = %d, want = %d", stats.Runs, p.calls)
+		}
+
+		if stats.Pruned() != stats.Runs*3 {
+			t.Errorf("Janitor.Run() got pruned = %d, want = %d", stats.Pruned(), stats.Runs*3)
+		}
+
+		if p.got != r {
+			t.Errorf("Janitor.Run() got retention = %v, want = %v", p.got, r)
+		}
+	})
+
+	t.Run("подсчёт_ошибок", func(t *testing.T) {
+		p := &fakePruner{err: errors.New("db is down")}
+		j := New(log.New(io.Discard, "", 0), p, r).Interval(10 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 35*time.Millisecond)
+		defer cancel()
+
+		stats := j.Run(ctx)
+
+		if stats.Errs != stats.Runs || stats.Pruned() != 0 {
+			t.Errorf("Janitor.Run() got errors = %d, pruned = %d, want = %d, %d",
+				stats.Errs, stats.Pruned(), stats.Runs, 0)
+		}
+	})
+
+	t.Run("политика_не_задана", func(t *testing.T) {
+		// один проход, чтобы хранилище сняло прежние правила
+		p := &fakePruner{}
+		stats := New(log.New(io.Discard, "", 0), p, storage.Retention{}).Run(context.Background())
+
+		if stats.Runs != 1 || p.calls != 1 || p.got != (storage.Retention{}) {
+			t.Errorf("Janitor.Run() got runs = %d, want = %d", stats.Runs, 1)
+		}
+	})
+
+	t.Run("метрики", func(t *testing.T) {
+		m := NewMetrics()
+		p := &fakePruner{}
+		j := New(log.New(io.Discard, "", 0), p, r).Interval(time.Hour).Metrics(m)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		j.Run(ctx)
+
+		var got map[string]int64
+		if err := json.Unmarshal([]byte(m.String()), &got); err != nil {
+			t.Fatalf("Metrics.String() error = %v", err)
+		}
+		if got["runs"] != 1 || got["by_age"] != 1 || got["by_source"] != 1 || got["by_total"] != 1 ||
+			got["errors"] != 0 || got["last_run"] == 0 {
+			t.Errorf("Metrics.String() got = %v", got)
+		}
+	})
+}
@@ -3,6 +3,9 @@ package memdb
 import (
 	"context"
 	"news/pkg/storage"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // MemDB - заглушка настоящей БД
@@ -20,15 +23,43 @@ var SampleItem = storage.Item{
 	PubDate:     5555555,
 	Description: "sample discription",
 	Link:        "https://test.com",
+	Image:       "https://test.com/sample.png",
+	Lang:        "en",
+	Categories:  []string{"Go"},
+}
+
+// Item возвращает SampleItem, если ссылка совпадает,
+// иначе storage.ErrNotFound
+func (db *MemDB) Item(_ context.Context, link string) (storage.Item, error) {
+	if link != SampleItem.Link {
+		return storage.Item{}, storage.ErrNotFound
+	}
+	return SampleItem, nil
+}
+
+// ItemByID возвращает SampleItem, если идентификатор
+// совпадает, иначе storage.ErrNotFound
+func (db *MemDB) ItemByID(_ context.Context, id int64) (storage.Item, error) {
+	if id != SampleItem.Id {
+		return storage.Item{}, storage.ErrNotFound
+	}
+	return SampleItem, nil
 }
 
-// Item возвращает один экземпляр SampleItem
-func (db *MemDB) Item(_ context.Context, _ string) (storage.Item, error) {
+// ItemByOID возвращает SampleItem, если идентификатор
+// совпадает, иначе storage.ErrNotFound
+func (db *MemDB) ItemByOID(_ context.Context, oid primitive.ObjectID) (storage.Item, error) {
+	if oid != SampleItem.Oid {
+		return storage.Item{}, storage.ErrNotFound
+	}
 	return SampleItem, nil
 }
 
 // Items возвращает столько Item, сколько запрошено
 func (db *MemDB) Items(_ context.Context, n int) ([]storage.Item, error) {
+	if n < 0 {
+		return nil, storage.ErrInvalidLimit
+	}
 	items := make([]storage.Item, 0, n)
 	for i := 0; i < n; i++ {
 		items = append(items, SampleItem)
@@ -36,26 +67,67 @@ func (db *MemDB) Items(_ context.Context, n int) ([]storage.Item, error) {
 	return items, nil
 }
 
+// FilterItems возвращает столько Item, сколько запрошено,
+// если SampleItem подходит под условия f, иначе ничего
+func (db *MemDB) FilterItems(ctx context.Context, n int, f storage.Filter) ([]storage.Item, error) {
+	if n < 0 {
+		return nil, storage.ErrInvalidLimit
+	}
+	text := strings.ToLower(SampleItem.Title + "\n" + SampleItem.Description)
+	if (f.Lang != "" && f.Lang != SampleItem.Lang) ||
+		(f.Source != "" && f.Source != SampleItem.Source) ||
+		!strings.Contains(text, strings.ToLower(f.Query)) ||
+		(f.Category != "" && !hasCategory(SampleItem, f.Category)) {
+		return nil, nil
+	}
+	return db.Items(ctx, n)
+}
+
+// hasCategory сообщает, есть ли у новости категория c без учёта регистра
+func hasCategory(it storage.Item, c string) bool {
+	for _, ic := range it.Categories {
+		if strings.EqualFold(ic, c) {
+			return true
+		}
+	}
+	return false
+}
+
 // AddItem - no-op
 func (db *MemDB) AddItem(_ context.Context, _ storage.Item) error {
 	return nil
 }
 
-// AddItems - no-op
-func (db *MemDB) AddItems(_ context.Context, _ []storage.Item) error {
-	return nil
+// AddItems - no-op, все переданные новости
+// считаются новыми
+func (db *MemDB) AddItems(_ context.Context, items []storage.Item) (storage.AddResult, error) {
+	return storage.AddResult{Inserted: items}, nil
 }
 
-// DeleteItem - no-op
-func (db *MemDB) DeleteItem(_ context.Context, _ storage.Item) error {
+// DeleteItem - no-op, если ссылка не совпадает
+// с SampleItem, возвращает storage.ErrNotFound
+func (db *MemDB) DeleteItem(_ context.Context, link string) error {
+	if link != SampleItem.Link {
+		return storage.ErrNotFound
+	}
 	return nil
 }
 
-// UpdateItem - no-op
-func (db *MemDB) UpdateItem(_ context.Context, _ storage.Item) error {
+// UpdateItem - no-op, если ссылка не совпадает
+// с SampleItem, возвращает storage.ErrNotFound
+func (db *MemDB) UpdateItem(_ context.Context, item storage.Item) error {
+	if item.Link != SampleItem.Link {
+		return storage.ErrNotFound
+	}
 	return nil
 }
 
+// Prune - no-op, MemDB ничего не хранит,
+// поэтому удалять нечего
+func (db *MemDB) Prune(_ context.Context, _ storage.Retention) (storage.PruneResult, error) {
+	return storage.PruneResult{}, nil
+}
+
 // Close - no-op
 func (db *MemDB) Close() error {
 	return nil
@@ -0,0 +1,246 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// bootstrapCollection - коллекция, в которой хранятся
+// записи о применённых к коллекциям новостей шагах bootstrap
+const bootstrapCollection = "bootstrap"
+
+// codeNamespaceExists - код ошибки mongodb,
+// когда создаваемая коллекция уже существует
+const codeNamespaceExists = 48
+
+// migration - шаг подготовки коллекции новостей.
+// Шаги применяются строго по возрастанию версии,
+// уже применённые шаги повторно не выполняются
+type migration struct {
+	version int
+	name    string
+	// index - шаг создаёт индекс с именем name,
+	// по нему Bootstrap проверяет, что шаг не утерян
+	index bool
+	// validator - шаг устанавливает валидатор коллекции
+	validator bool
+	apply     func(ctx context.Context, col *mongo.Collection) error
+}
+
+// migrations - список шагов bootstrap. Новые индексы и изменения
+// валидатора добавляются в конец списка со следующей версией,
+// существующие шаги менять нельзя
+var migrations = []migration{
+	{version: 1, name: "link_unique_idx", index: true, apply: createLinkIndex},
+	{version: 2, name: "pub_date_idx", index: true, apply: createPubDateIndex},
+	{version: 3, name: "json_schema_validator", validator: true, apply: setValidator},
+	{version: 4, name: "pub_at_backfill", apply: backfillPubAt},
+	{version: 5, name: "source_pub_date_idx", index: true, apply: createSourceIndex},
+	{version: 6, name: "lang_pub_date_idx", index: true, apply: createLangIndex},
+}
+
+// bootstrapRecord - запись о версии bootstrap коллекции
+type bootstrapRecord struct {
+	Collection string    `bson:"_id"`
+	Version    int       `bson:"version"`
+	Step       string    `bson:"step"`
+	AppliedAt  time.Time `bson:"appliedAt"`
+}
+
+// Bootstrap создаёт необходимые индексы и валидатор для текущей
+// коллекции. Применяются только те шаги, версия которых больше
+// версии, сохранённой в записи bootstrap коллекции. Если коллекция,
+// её валидатор или индекс уже применённого шага пропали, например
+// коллекцию удалили вручную, шаги применяются заново с утерянного
+func (m *Mongo) Bootstrap(ctx context.Context) error {
+
+	db := m.client.Database(m.database)
+	col := db.Collection(m.collection)
+	meta := db.Collection(bootstrapCollection)
+
+	var rec bootstrapRecord
+	err := meta.FindOne(ctx, bson.D{bson.E{Key: "_id", Value: m.collection}}).Decode(&rec)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return fmt.Errorf("mongo bootstrap: %w", err)
+	}
+
+	if rec.Version > 0 {
+		if rec.Version, err = verified(ctx, col, rec.Version); err != nil {
+			return fmt.Errorf("mongo bootstrap: %w", err)
+		}
+	}
+
+	for _, mg := range migrations {
+		if mg.version <= rec.Version {
+			continue
+		}
+
+		if err := mg.apply(ctx, col); err != nil {
+			return fmt.Errorf("mongo bootstrap: step %d %q: %w", mg.version, mg.name, err)
+		}
+
+		rec = bootstrapRecord{
+			Collection: m.collection,
+			Version:    mg.version,
+			Step:       mg.name,
+			AppliedAt:  time.Now().UTC(),
+		}
+
+		// фиксируем версию после каждого шага, чтобы при сбое
+		// не повторять уже выполненные шаги
+		_, err := meta.ReplaceOne(ctx, bson.D{bson.E{Key: "_id", Value: m.collection}},
+			rec, options.Replace().SetUpsert(true))
+		if err != nil {
+			return fmt.Errorf("mongo bootstrap: step %d %q: %w", mg.version, mg.name, err)
+		}
+	}
+
+	return nil
+}
+
+// verified возвращает версию, до которой шаги bootstrap
+// действительно применены к коллекции col: версию шага перед
+// первым утерянным индексом или валидатором или 0, если
+// коллекции нет. Шаги идемпотентны, поэтому повторное
+// применение следующих за утерянным шагов безопасно
+func verified(ctx context.Context, col *mongo.Collection, version int) (int, error) {
+	specs, err := col.Database().ListCollectionSpecifications(ctx,
+		bson.D{bson.E{Key: "name", Value: col.Name()}})
+	if err != nil {
+		return 0, err
+	}
+	if len(specs) == 0 {
+		return 0, nil
+	}
+	_, lookupErr := specs[0].Options.LookupErr("validator")
+
+	indexes, err := col.Indexes().ListSpecifications(ctx)
+	if err != nil {
+		return 0, err
+	}
+	names := make(map[string]bool, len(indexes))
+	for _, idx := range indexes {
+		names[idx.Name] = true
+	}
+
+	for _, mg := range migrations {
+		if mg.version > version {
+			break
+		}
+		if (mg.index && !names[mg.name]) || (mg.validator && lookupErr != nil) {
+			return mg.version - 1, nil
+		}
+	}
+
+	return version, nil
+}
+
+// createLinkIndex создаёт уникальный индекс по ссылке,
+// на который опирается upsert в AddItems
+func createLinkIndex(ctx context.Context, col *mongo.Collection) error {
+	_, err := col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{bson.E{Key: "link", Value: 1}},
+		Options: options.Index().SetName("link_unique_idx").SetUnique(true),
+	})
+	return err
+}
+
+// createPubDateIndex создаёт нисходящий индекс по дате публикации,
+// так как выборка в Items идёт по последним новостям
+func createPubDateIndex(ctx context.Context, col *mongo.Collection) error {
+	_, err := col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{bson.E{Key: "pubDate", Value: -1}},
+		Options: options.Index().SetName("pub_date_idx"),
+	})
+	return err
+}
+
+// itemSchema - JSON-схема документа rss-новости,
+// повторяет ограничения таблицы news в postgres
+var itemSchema = bson.D{
+	bson.E{Key: "bsonType", Value: "object"},
+	bson.E{Key: "required", Value: bson.A{"title", "description", "pubDate", "link"}},
+	bson.E{Key: "properties", Value: bson.D{
+		bson.E{Key: "title", Value: bson.D{bson.E{Key: "bsonType", Value: "string"}}},
+		bson.E{Key: "description", Value: bson.D{bson.E{Key: "bsonType", Value: "string"}}},
+		bson.E{Key: "link", Value: bson.D{
+			bson.E{Key: "bsonType", Value: "string"},
+			bson.E{Key: "minLength", Value: 1},
+		}},
+		bson.E{Key: "pubDate", Value: bson.D{
+			bson.E{Key: "bsonType", Value: bson.A{"long", "int"}},
+			bson.E{Key: "minimum", Value: 1},
+		}},
+	}},
+}
+
+// setValidator устанавливает JSON-схему в качестве валидатора
+// коллекции, создавая коллекцию, если её ещё нет
+func setValidator(ctx context.Context, col *mongo.Collection) error {
+	validator := bson.D{bson.E{Key: "$jsonSchema", Value: itemSchema}}
+
+	opts := options.CreateCollection().
+		SetValidator(validator).
+		SetValidationLevel("moderate").
+		SetValidationAction("error")
+
+	err := col.Database().CreateCollection(ctx, col.Name(), opts)
+	if err == nil {
+		return nil
+	}
+
+	var cmdErr mongo.CommandError
+	if !errors.As(err, &cmdErr) || !cmdErr.HasErrorCode(codeNamespaceExists) {
+		return err
+	}
+
+	// коллекция уже есть - меняем валидатор через collMod
+	cmd := bson.D{
+		bson.E{Key: "collMod", Value: col.Name()},
+		bson.E{Key: "validator", Value: validator},
+		bson.E{Key: "validationLevel", Value: "moderate"},
+		bson.E{Key: "validationAction", Value: "error"},
+	}
+
+	return col.Database().RunCommand(ctx, cmd).Err()
+}
+
+// backfillPubAt заполняет дату публикации в формате BSON Date
+// у документов, добавленных до появления политики хранения
+func backfillPubAt(ctx context.Context, col *mongo.Collection) error {
+	filter := bson.D{bson.E{Key: "pubAt", Value: bson.D{bson.E{Key: "$exists", Value: false}}}}
+	toDate := bson.D{bson.E{Key: "$toDate", Value: bson.D{
+		bson.E{Key: "$multiply", Value: bson.A{"$pubDate", 1000}}}}}
+	update := mongo.Pipeline{
+		bson.D{bson.E{Key: "$set", Value: bson.D{bson.E{Key: "pubAt", Value: toDate}}}},
+	}
+
+	_, err := col.UpdateMany(ctx, filter, update)
+	return err
+}
+
+// createSourceIndex создаёт индекс для удаления старых
+// новостей каждой rss-ленты согласно политике хранения
+func createSourceIndex(ctx context.Context, col *mongo.Collection) error {
+	_, err := col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{bson.E{Key: "source", Value: 1}, bson.E{Key: "pubDate", Value: -1}},
+		Options: options.Index().SetName("source_pub_date_idx"),
+	})
+	return err
+}
+
+// createLangIndex создаёт индекс для выборки
+// последних новостей на одном языке
+func createLangIndex(ctx context.Context, col *mongo.Collection) error {
+	_, err := col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{bson.E{Key: "lang", Value: 1}, bson.E{Key: "pubDate", Value: -1}},
+		Options: options.Index().SetName("lang_pub_date_idx"),
+	})
+	return err
+}
@@ -2,20 +2,37 @@ package mongo
 
 import (
 	"context"
+	"errors"
 	"news/pkg/storage"
+	"regexp"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // Когда при выполнении операции не найдено
-// ни одного документа
-var ErrNoDocuments = mongo.ErrNoDocuments
+// ни одного документа, то же, что storage.ErrNotFound
+var ErrNoDocuments = storage.ErrNotFound
 
 // псевдоним для объекта хранения БД
 type item = storage.Item
 
+// document - представление новости в коллекции. Кроме полей
+// новости хранит дату публикации в формате BSON Date,
+// по которой работает TTL-индекс политики хранения
+type document struct {
+	storage.Item `bson:",inline"`
+	PubAt        time.Time `bson:"pubAt"`
+}
+
+// newDocument возвращает документ для новости i
+func newDocument(i item) document {
+	return document{Item: i, PubAt: time.Unix(i.PubDate, 0).UTC()}
+}
+
 // Mongo структура для выполнения CRUD операций с БД
 type Mongo struct {
 	client *mongo.Client // клиент mongo
@@ -25,10 +42,17 @@ type Mongo struct {
 	// название текущей collection,
 	// переключается методом Collection()
 	collection string
+	// время жизни новостей, установленное в TTL-индексе,
+	// ttlUnknown - индекс ещё не проверялся
+	ttl time.Duration
 }
 
+// ttlUnknown - состояние TTL-индекса коллекции неизвестно
+const ttlUnknown time.Duration = -1
+
 // New подключается к БД, используя connstr, и возвращает
-// объект для работы с БД
+// объект для работы с БД. Перед возвратом выполняет Bootstrap
+// коллекции: создаёт индексы и валидатор документов
 func New(connstr, database, collection string) (*Mongo, error) {
 
 	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(connstr))
@@ -36,26 +60,41 @@ func New(connstr, database, collection string) (*Mongo, error) {
 		return nil, err
 	}
 
-	return &Mongo{
+	m := &Mongo{
 		client:     client,
 		database:   database,
 		collection: collection,
-	}, client.Ping(context.Background(), nil)
+		ttl:        ttlUnknown,
+	}
+
+	if err := client.Ping(context.Background(), nil); err != nil {
+		return m, err
+	}
+
+	return m, m.Bootstrap(context.Background())
 }
 
 // Database переключает имя базы данных mongodb
 // в структуре *Mongo
 func (m *Mongo) Database(database string) *Mongo {
 	m.database = database
+	m.ttl = ttlUnknown
 	return m
 }
 
 // Collection переключает имя коллекции в структуре *Mongo.
+// Для новой коллекции нужно вызвать Bootstrap
 func (m *Mongo) Collection(collection string) *Mongo {
 	m.collection = collection
+	m.ttl = ttlUnknown
 	return m
 }
 
+// Ping проверяет соединение с БД
+func (m *Mongo) Ping(ctx context.Context) error {
+	return m.client.Ping(ctx, nil)
+}
+
 // Close закрывает соединение с БД
 func (m *Mongo) Close() error {
 	return m.client.Disconnect(context.Background())
@@ -63,7 +102,7 @@ func (m *Mongo) Close() error {
 
 // AddItems добавляет в БД слайс rss-новостей,
 // ингорирует те новости, что уже есть в БД
-func (m *Mongo) AddItems(ctx context.Context, items []item) error {
+func (m *Mongo) AddItems(ctx context.Context, items []item) (storage.AddResult, error) {
 
 	col := m.client.Database(m.database).Collection(m.collection)
 
@@ -72,7 +111,7 @@ func (m *Mongo) AddItems(ctx context.Context, items []item) error {
 	for i := range items {
 		filter := bson.D{bson.E{Key: "link", Value: items[i].Link}}
 		// bson.MarshalValue()
-		update := bson.D{bson.E{Key: "$setOnInsert", Value: items[i]}}
+		update := bson.D{bson.E{Key: "$setOnInsert", Value: newDocument(items[i])}}
 
 		models[i] = mongo.NewUpdateOneModel().
 			SetFilter(filter).
@@ -81,19 +120,80 @@ func (m *Mongo) AddItems(ctx context.Context, items []item) error {
 	}
 
 	opts := options.BulkWrite().SetOrdered(false)
-	_, err := col.BulkWrite(ctx, models, opts)
+	br, err := col.BulkWrite(ctx, models, opts)
+	// при ошибках отдельных моделей остальные всё равно
+	// выполняются, и записанные новости нужно вернуть
+	var bwe mongo.BulkWriteException
+	if err != nil && (br == nil || !errors.As(err, &bwe)) {
+		return storage.AddResult{}, err
+	}
 
-	return err
+	var res storage.AddResult
+
+	// UpsertedIDs содержит индексы моделей,
+	// которые привели к вставке нового документа
+	for i := range items {
+		id, ok := br.UpsertedIDs[int64(i)]
+		if !ok {
+			continue
+		}
+		it := items[i]
+		if oid, ok := id.(primitive.ObjectID); ok {
+			it.Oid = oid
+		}
+		res.Inserted = append(res.Inserted, it)
+	}
+	if err != nil {
+		return res, err
+	}
+	// $setOnInsert не меняет существующий документ,
+	// как ON CONFLICT DO NOTHING в postgres
+	res.Skipped = len(items) - len(res.Inserted)
+
+	return res, nil
 }
 
 // Items возвращает списком по крайней мере n rss-новостей
 // отсортированных по дате публикации по убыванию
 func (m *Mongo) Items(ctx context.Context, n int) ([]item, error) {
+	return m.FilterItems(ctx, n, storage.Filter{})
+}
+
+// FilterItems возвращает не больше n rss-новостей, подходящих
+// под условия f, отсортированных по дате публикации по убыванию.
+// Если n отрицательное, возвращает storage.ErrInvalidLimit
+func (m *Mongo) FilterItems(ctx context.Context, n int, f storage.Filter) ([]item, error) {
+	if n < 0 {
+		return nil, storage.ErrInvalidLimit
+	}
 
 	col := m.client.Database(m.database).Collection(m.collection)
 
-	opts := options.Find().SetSort(bson.D{bson.E{Key: "pubDate", Value: -1}}).SetLimit(int64(n))
-	cursor, err := col.Find(ctx, bson.D{}, opts)
+	filter := bson.D{}
+	if f.Lang != "" {
+		filter = append(filter, bson.E{Key: "lang", Value: f.Lang})
+	}
+	if f.Source != "" {
+		filter = append(filter, bson.E{Key: "source", Value: f.Source})
+	}
+	if f.Query != "" {
+		re := primitive.Regex{Pattern: regexp.QuoteMeta(f.Query), Options: "i"}
+		filter = append(filter, bson.E{Key: "$or", Value: bson.A{
+			bson.D{bson.E{Key: "title", Value: re}},
+			bson.D{bson.E{Key: "description", Value: re}},
+		}})
+	}
+	if f.Category != "" {
+		// категория совпадает целиком, без учёта регистра
+		re := primitive.Regex{Pattern: "^" + regexp.QuoteMeta(f.Category) + "$", Options: "i"}
+		filter = append(filter, bson.E{Key: "categories", Value: re})
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{bson.E{Key: "pubDate", Value: -1}, bson.E{Key: "_id", Value: -1}}).
+		SetSkip(int64(f.Offset)).
+		SetLimit(int64(n))
+	cursor, err := col.Find(ctx, filter, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -115,7 +215,7 @@ func (m *Mongo) AddItem(ctx context.Context, item item) error {
 	opts := options.Update().SetUpsert(true)
 	upd := bson.D{
 		bson.E{
-			Key: "$setOnInsert", Value: item},
+			Key: "$setOnInsert", Value: newDocument(item)},
 	}
 
 	_, err := col.UpdateOne(ctx, filter, upd, opts)
@@ -126,22 +226,43 @@ func (m *Mongo) AddItem(ctx context.Context, item item) error {
 // Item находит по ссылке и возвращает rss-новость.
 // Возвращает ошибку ErrNoDocuments в случае если документ не найден
 func (m *Mongo) Item(ctx context.Context, link string) (item, error) {
+	return m.item(ctx, bson.D{bson.E{Key: "link", Value: link}})
+}
+
+// ItemByOID находит по идентификатору и возвращает rss-новость.
+// Возвращает ошибку ErrNoDocuments в случае если документ не найден
+func (m *Mongo) ItemByOID(ctx context.Context, oid primitive.ObjectID) (item, error) {
+	return m.item(ctx, bson.D{bson.E{Key: "_id", Value: oid}})
+}
+
+// item возвращает rss-новость, подходящую под filter
+func (m *Mongo) item(ctx context.Context, filter bson.D) (item, error) {
 
 	col := m.client.Database(m.database).Collection(m.collection)
 
 	var item item
 
-	return item, col.FindOne(ctx, bson.D{bson.E{Key: "link", Value: link}}).Decode(&item)
+	err := col.FindOne(ctx, filter).Decode(&item)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return item, ErrNoDocuments
+	}
+
+	return item, err
 }
 
-// DeleteItem удаляет из БД rss-новость
+// DeleteItem удаляет из БД rss-новость по ссылке.
+// Возвращает ошибку ErrNoDocuments в случае если документ не найден
 func (m *Mongo) DeleteItem(ctx context.Context, link string) error {
 	col := m.client.Database(m.database).Collection(m.collection)
-	_, err := col.DeleteOne(ctx, bson.D{bson.E{Key: "link", Value: link}})
+	res, err := col.DeleteOne(ctx, bson.D{bson.E{Key: "link", Value: link}})
+	if err == nil && res.DeletedCount == 0 {
+		return ErrNoDocuments
+	}
 	return err
 }
 
-// UpdateItem обновляет в БД rss-новость
+// UpdateItem обновляет в БД rss-новость, новость определяется ссылкой.
+// Возвращает ошибку ErrNoDocuments в случае если документ не найден
 func (m *Mongo) UpdateItem(ctx context.Context, item item) error {
 
 	col := m.client.Database(m.database).Collection(m.collection)
@@ -149,9 +270,162 @@ func (m *Mongo) UpdateItem(ctx context.Context, item item) error {
 	filter := bson.D{bson.E{Key: "link", Value: item.Link}}
 	upd := bson.D{
 		bson.E{
-			Key: "$set", Value: item},
+			Key: "$set", Value: newDocument(item)},
+	}
+	res, err := col.UpdateOne(ctx, filter, upd)
+	if err == nil && res.MatchedCount == 0 {
+		return ErrNoDocuments
+	}
+
+	return err
+}
+
+// ttlIndex - имя TTL-индекса политики хранения
+const ttlIndex = "pub_at_ttl_idx"
+
+// коды ошибок mongodb: индекс с таким именем уже существует,
+// но с другими параметрами, или индекса нет
+const (
+	codeIndexOptionsConflict  = 85
+	codeIndexKeySpecsConflict = 86
+	codeIndexNotFound         = 27
+)
+
+// pruneBatch - сколько новостей удаляется одним запросом
+const pruneBatch = 1000
+
+// Prune удаляет новости согласно политике хранения r.
+// Новости старше r.MaxAge удаляет сам сервер по TTL-индексу,
+// поэтому они не учитываются в storage.PruneResult. Если
+// r.MaxAge не задан, TTL-индекс удаляется, иначе сервер
+// продолжал бы удалять новости по прежней политике
+func (m *Mongo) Prune(ctx context.Context, r storage.Retention) (storage.PruneResult, error) {
+
+	col := m.client.Database(m.database).Collection(m.collection)
+
+	var res storage.PruneResult
+
+	if r.MaxAge != m.ttl {
+		var err error
+		if r.MaxAge > 0 {
+			err = setTTL(ctx, col, r.MaxAge)
+		} else {
+			err = dropTTL(ctx, col)
+		}
+		if err != nil {
+			return res, err
+		}
+		m.ttl = r.MaxAge
+	}
+
+	if r.MaxPerSource > 0 {
+		sources, err := col.Distinct(ctx, "source", bson.D{})
+		if err != nil {
+			return res, err
+		}
+
+		for _, src := range sources {
+			n, err := deleteBeyond(ctx, col, bson.D{bson.E{Key: "source", Value: src}}, r.MaxPerSource)
+			res.BySource += n
+			if err != nil {
+				return res, err
+			}
+		}
 	}
-	_, err := col.UpdateOne(ctx, filter, upd)
 
+	if r.MaxTotal > 0 {
+		n, err := deleteBeyond(ctx, col, bson.D{}, r.MaxTotal)
+		res.ByTotal += n
+		if err != nil {
+			return res, err
+		}
+	}
+
+	return res, nil
+}
+
+// setTTL создаёт TTL-индекс по дате публикации или
+// меняет время жизни документов у существующего индекса
+func setTTL(ctx context.Context, col *mongo.Collection, ttl time.Duration) error {
+	seconds := int32(ttl / time.Second)
+
+	_, err := col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{bson.E{Key: "pubAt", Value: 1}},
+		Options: options.Index().SetName(ttlIndex).SetExpireAfterSeconds(seconds),
+	})
+	if err == nil {
+		return nil
+	}
+
+	var cmdErr mongo.CommandError
+	if !errors.As(err, &cmdErr) ||
+		!(cmdErr.HasErrorCode(codeIndexOptionsConflict) || cmdErr.HasErrorCode(codeIndexKeySpecsConflict)) {
+		return err
+	}
+
+	cmd := bson.D{
+		bson.E{Key: "collMod", Value: col.Name()},
+		bson.E{Key: "index", Value: bson.D{
+			bson.E{Key: "name", Value: ttlIndex},
+			bson.E{Key: "expireAfterSeconds", Value: seconds},
+		}},
+	}
+
+	return col.Database().RunCommand(ctx, cmd).Err()
+}
+
+// dropTTL удаляет TTL-индекс, если он есть
+func dropTTL(ctx context.Context, col *mongo.Collection) error {
+	_, err := col.Indexes().DropOne(ctx, ttlIndex)
+
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.HasErrorCode(codeIndexNotFound) {
+		return nil
+	}
 	return err
 }
+
+// deleteBeyond удаляет пачками по pruneBatch все документы, подходящие
+// под filter, кроме keep последних по дате публикации
+func deleteBeyond(ctx context.Context, col *mongo.Collection, filter bson.D, keep int) (int64, error) {
+	var total int64
+
+	for {
+		opts := options.Find().
+			SetSort(bson.D{bson.E{Key: "pubDate", Value: -1}}).
+			SetSkip(int64(keep)).
+			SetLimit(pruneBatch).
+			SetProjection(bson.D{bson.E{Key: "_id", Value: 1}})
+
+		cursor, err := col.Find(ctx, filter, opts)
+		if err != nil {
+			return total, err
+		}
+
+		var docs []struct {
+			ID any `bson:"_id"`
+		}
+		if err := cursor.All(ctx, &docs); err != nil {
+			return total, err
+		}
+
+		if len(docs) == 0 {
+			return total, nil
+		}
+
+		ids := make(bson.A, len(docs))
+		for i := range docs {
+			ids[i] = docs[i].ID
+		}
+
+		dr, err := col.DeleteMany(ctx, bson.D{bson.E{Key: "_id", Value: bson.D{bson.E{Key: "$in", Value: ids}}}})
+		if err != nil {
+			return total, err
+		}
+		total += dr.DeletedCount
+
+		if len(docs) < pruneBatch {
+			return total, nil
+		}
+	}
+}
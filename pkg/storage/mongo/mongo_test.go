@@ -2,10 +2,15 @@ package mongo
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"news/pkg/storage"
 	"os"
+	"reflect"
 	"testing"
+	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -41,6 +46,9 @@ func restoreTestDB(db *Mongo) error {
 	if err != nil {
 		return err
 	}
+	if err := db.Bootstrap(context.Background()); err != nil {
+		return err
+	}
 	col := db.client.Database(db.database).Collection(db.collection)
 	_, err = col.InsertMany(context.Background(), testData)
 	return err
@@ -78,6 +86,76 @@ func TestMongo(t *testing.T) {
 		t.Skipf("environment variable %s not set, skipping tests", DbEnv)
 	}
 
+	t.Run("Bootstrap()", func(t *testing.T) {
+
+		// повторный вызов не должен ничего менять
+		err := tdb.Bootstrap(context.Background())
+		if err != nil {
+			t.Fatalf("Mongo.Bootstrap() error = %v", err)
+		}
+
+		col := tdb.client.Database(tdb.database).Collection(tdb.collection)
+		specs, err := col.Indexes().ListSpecifications(context.Background())
+		if err != nil {
+			t.Fatalf("Mongo.Bootstrap() error = %v", err)
+		}
+
+		want := map[string]bool{"link_unique_idx": true, "pub_date_idx": true}
+		for _, s := range specs {
+			if s.Name == "link_unique_idx" && (s.Unique == nil || !*s.Unique) {
+				t.Errorf("Mongo.Bootstrap() index %q is not unique", s.Name)
+			}
+			delete(want, s.Name)
+		}
+		if len(want) != 0 {
+			t.Errorf("Mongo.Bootstrap() missing indexes = %v", want)
+		}
+
+		var rec bootstrapRecord
+		meta := tdb.client.Database(tdb.database).Collection(bootstrapCollection)
+		err = meta.FindOne(context.Background(), bson.D{bson.E{Key: "_id", Value: tdb.collection}}).Decode(&rec)
+		if err != nil {
+			t.Fatalf("Mongo.Bootstrap() error = %v", err)
+		}
+
+		if got, want := rec.Version, migrations[len(migrations)-1].version; got != want {
+			t.Errorf("Mongo.Bootstrap() got version = %d, want = %d", got, want)
+		}
+
+		// валидатор не должен пропускать новость без ссылки
+		_, err = col.InsertOne(context.Background(), item{Title: "t", Description: "d", PubDate: 1})
+		if err == nil {
+			t.Errorf("Mongo.Bootstrap() validator accepted item without link")
+		}
+	})
+
+	t.Run("Bootstrap()_утерянный_индекс", func(t *testing.T) {
+
+		// версия в записи bootstrap остаётся прежней,
+		// но индекс должен быть создан заново
+		col := tdb.client.Database(tdb.database).Collection(tdb.collection)
+		if _, err := col.Indexes().DropOne(context.Background(), "pub_date_idx"); err != nil {
+			t.Fatalf("Mongo.Bootstrap() error = %v", err)
+		}
+
+		if err := tdb.Bootstrap(context.Background()); err != nil {
+			t.Fatalf("Mongo.Bootstrap() error = %v", err)
+		}
+
+		specs, err := col.Indexes().ListSpecifications(context.Background())
+		if err != nil {
+			t.Fatalf("Mongo.Bootstrap() error = %v", err)
+		}
+
+		found := false
+		for _, s := range specs {
+			found = found || s.Name == "pub_date_idx"
+		}
+		if !found {
+			t.Errorf("Mongo.Bootstrap() index %q was not recreated", "pub_date_idx")
+		}
+	})
+
 	t.Run("DeleteItem()", func(t *testing.T) {
 
 		want := testItem2
@@ -87,12 +165,17 @@ func TestMongo(t *testing.T) {
 			t.Fatalf("Mongo.DeleteItem() error = %v", err)
 		}
 
+		err = tdb.DeleteItem(context.Background(), want.Link)
+		if !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("Mongo.DeleteItem() got error = %v, want = %v", err, storage.ErrNotFound)
+		}
+
 		got, err := tdb.Item(context.Background(), want.Link)
 		if err != nil && err != ErrNoDocuments {
 			t.Fatalf("Mongo.Item() error = %v", err)
 		}
 
-		if got != (item{}) {
+		if !reflect.DeepEqual(got, item{}) {
 			t.Errorf("Mongo.Items() got = %v, want = nothing", got)
 		}
 	})
@@ -111,7 +194,7 @@ func TestMongo(t *testing.T) {
 			t.Fatalf("Mongo.Item() error = %v", err)
 		}
 
-		if got != want {
+		if !reflect.DeepEqual(got, want) {
 			t.Errorf("Mongo.AddItem() got = %v, want = %v", got, want)
 		}
 
@@ -128,7 +211,7 @@ func TestMongo(t *testing.T) {
 			t.Fatalf("Mongo.Item() error = %v", err)
 		}
 
-		if got == want {
+		if reflect.DeepEqual(got, want) {
 			t.Errorf("Mongo.AddItem() got = %v, want = %v", got, got)
 		}
 	})
@@ -150,14 +233,21 @@ func TestMongo(t *testing.T) {
 				PubDate:     253417963065,
 				Description: "new desc 2",
 				Link:        "https://testnewitem2.com",
+				Lang:        "en",
+				Categories:  []string{"Go", "Программирование"},
 			},
 		}
 
-		err := tdb.AddItems(context.Background(), want)
+		res, err := tdb.AddItems(context.Background(), want)
 		if err != nil {
 			t.Fatalf("Mongo.AddItems() error = %v", err)
 		}
 
+		if len(res.Inserted) != len(want) || res.Skipped != 0 {
+			t.Fatalf("Mongo.AddItems() got inserted = %d, skipped = %d, want = %d, %d",
+				len(res.Inserted), res.Skipped, len(want), 0)
+		}
+
 		got, err := tdb.Items(context.Background(), len(want))
 		if err != nil {
 			t.Fatalf("Mongo.Items() error = %v", err)
@@ -168,10 +258,79 @@ func TestMongo(t *testing.T) {
 		}
 
 		for i := range want {
-			if got[i] != want[i] {
+			if !reflect.DeepEqual(got[i], want[i]) {
 				t.Errorf("Mongo.AddItems() got = %v, want = %v", got[i], want[i])
 			}
 		}
+
+		// повторная вставка - только дубликаты
+		res, err = tdb.AddItems(context.Background(), want)
+		if err != nil {
+			t.Fatalf("Mongo.AddItems() error = %v", err)
+		}
+
+		if len(res.Inserted) != 0 || res.Skipped != len(want) {
+			t.Fatalf("Mongo.AddItems() got inserted = %d, skipped = %d, want = %d, %d",
+				len(res.Inserted), res.Skipped, 0, len(want))
+		}
+	})
+
+	t.Run("FilterItems()", func(t *testing.T) {
+		got, err := tdb.FilterItems(context.Background(), 10, storage.Filter{Lang: "en"})
+		if err != nil {
+			t.Fatalf("Mongo.FilterItems() error = %v", err)
+		}
+
+		if len(got) != 1 || got[0].Link != "https://testnewitem2.com" {
+			t.Fatalf("Mongo.FilterItems() got = %v, want only %s", got, "https://testnewitem2.com")
+		}
+
+		for _, f := range []storage.Filter{
+			{Query: "NEW DESC 2"},
+			{Category: "программирование"},
+		} {
+			got, err := tdb.FilterItems(context.Background(), 10, f)
+			if err != nil {
+				t.Fatalf("Mongo.FilterItems() error = %v", err)
+			}
+			if len(got) != 1 || got[0].Link != "https://testnewitem2.com" {
+				t.Errorf("Mongo.FilterItems(%+v) got = %v, want only %s", f, got, "https://testnewitem2.com")
+			}
+		}
+
+		for _, f := range []storage.Filter{
+			{Lang: "en", Offset: 1},
+			{Query: ".*"},
+			{Source: "https://other.com/rss"},
+			{Category: "Go.*"},
+		} {
+			got, err := tdb.FilterItems(context.Background(), 10, f)
+			if err != nil {
+				t.Fatalf("Mongo.FilterItems() error = %v", err)
+			}
+			if len(got) != 0 {
+				t.Errorf("Mongo.FilterItems(%+v) got = %v, want nothing", f, got)
+			}
+		}
+	})
+
+	t.Run("ItemByOID()", func(t *testing.T) {
+		want, err := tdb.Item(context.Background(), "https://testnewitem2.com")
+		if err != nil {
+			t.Fatalf("Mongo.Item() error = %v", err)
+		}
+
+		got, err := tdb.ItemByOID(context.Background(), want.Oid)
+		if err != nil {
+			t.Fatalf("Mongo.ItemByOID() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Mongo.ItemByOID() got = %v, want = %v", got, want)
+		}
+
+		if _, err := tdb.ItemByOID(context.Background(), primitive.NewObjectID()); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("Mongo.ItemByOID() got error = %v, want = %v", err, storage.ErrNotFound)
+		}
 	})
 
 	t.Run("UpdateItem()", func(t *testing.T) {
@@ -189,8 +348,55 @@ func TestMongo(t *testing.T) {
 			t.Fatalf("Mongo.Item() error = %v", err)
 		}
 
-		if got != want {
+		if !reflect.DeepEqual(got, want) {
 			t.Errorf("Mongo.UpdateItem() got = %v, want = %v", got, want)
 		}
 	})
+
+	t.Run("Prune()", func(t *testing.T) {
+
+		all, err := tdb.Items(context.Background(), 1000)
+		if err != nil {
+			t.Fatalf("Mongo.Items() error = %v", err)
+		}
+
+		res, err := tdb.Prune(context.Background(), storage.Retention{MaxAge: time.Hour, MaxTotal: 1})
+		if err != nil {
+			t.Fatalf("Mongo.Prune() error = %v", err)
+		}
+
+		if want := int64(len(all) - 1); res.ByTotal != want {
+			t.Errorf("Mongo.Prune() got by total = %d, want = %d", res.ByTotal, want)
+		}
+
+		col := tdb.client.Database(tdb.database).Collection(tdb.collection)
+		specs, err := col.Indexes().ListSpecifications(context.Background())
+		if err != nil {
+			t.Fatalf("Mongo.Prune() error = %v", err)
+		}
+
+		var ttl *int32
+		for _, s := range specs {
+			if s.Name == ttlIndex {
+				ttl = s.ExpireAfterSeconds
+			}
+		}
+		if ttl == nil || *ttl != int32(time.Hour/time.Second) {
+			t.Errorf("Mongo.Prune() got ttl index = %v, want = %d", ttl, time.Hour/time.Second)
+		}
+
+		// без MaxAge TTL-индекс удаляется
+		if _, err := tdb.Prune(context.Background(), storage.Retention{MaxTotal: 1}); err != nil {
+			t.Fatalf("Mongo.Prune() error = %v", err)
+		}
+		specs, err = col.Indexes().ListSpecifications(context.Background())
+		if err != nil {
+			t.Fatalf("Mongo.Prune() error = %v", err)
+		}
+		for _, s := range specs {
+			if s.Name == ttlIndex {
+				t.Errorf("Mongo.Prune() got ttl index = %v, want none", s.ExpireAfterSeconds)
+			}
+		}
+	})
 }
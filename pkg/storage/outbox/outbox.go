@@ -0,0 +1,280 @@
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"news/pkg/storage"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// container - объекты, которые хранит outbox
+type container = storage.ItemContainer
+
+// ErrFull возвращает Push, когда очередь заполнена
+// и установлена политика RejectNew
+var ErrFull = errors.New("outbox: full")
+
+// ErrEmpty возвращает Peek, когда очередь пуста
+var ErrEmpty = errors.New("outbox: empty")
+
+// Overflow - поведение очереди при превышении ограничений
+type Overflow int
+
+const (
+	DropOldest Overflow = iota // удалять самые старые записи, чтобы поместить новую
+	RejectNew                  // отклонять новую запись с ошибкой ErrFull
+)
+
+// ParseOverflow возвращает политику переполнения по имени:
+// "drop_oldest" или "reject_new"
+func ParseOverflow(s string) (Overflow, error) {
+	switch s {
+	case "", "drop_oldest":
+		return DropOldest, nil
+	case "reject_new":
+		return RejectNew, nil
+	}
+	return DropOldest, fmt.Errorf("outbox: unknown overflow policy %q", s)
+}
+
+// расширения файлов записей
+const (
+	ext    = ".json"
+	tmpExt = ".tmp"
+)
+
+// Entry - запись очереди
+type Entry struct {
+	Seq       uint64    // порядковый номер записи
+	Container container // контейнер с новостями
+}
+
+// meta - то, что очередь держит в памяти о записи
+type meta struct {
+	seq  uint64
+	size int64
+}
+
+// Outbox - очередь контейнеров с новостями на диске.
+// Каждая запись хранится в отдельном файле каталога,
+// поэтому очередь переживает перезапуск приложения
+type Outbox struct {
+	mu         sync.Mutex
+	dir        string
+	maxEntries int   // максимум записей, 0 - без ограничения
+	maxBytes   int64 // максимум байт на диске, 0 - без ограничения
+	overflow   Overflow
+	entries    []meta // записи по возрастанию номера
+	size       int64  // суммарный размер записей
+	seq        uint64 // номер последней записи
+	dropped    uint   // записи, удалённые из-за переполнения
+}
+
+// Open открывает очередь в каталоге dir, создавая его при
+// необходимости, и загружает уже сохранённые записи
+func Open(dir string, maxEntries int, maxBytes int64) (*Outbox, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &Outbox{
+		dir:        dir,
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		overflow:   DropOldest,
+	}
+
+	for _, f := range files {
+		name := f.Name()
+
+		// недописанные записи остались от аварийного завершения
+		if strings.HasSuffix(name, tmpExt) {
+			_ = os.Remove(filepath.Join(dir, name))
+			continue
+		}
+
+		if !strings.HasSuffix(name, ext) {
+			continue // чужой файл
+		}
+
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, ext), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		info, err := f.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		o.entries = append(o.entries, meta{seq: seq, size: info.Size()})
+		o.size += info.Size()
+		if seq > o.seq {
+			o.seq = seq
+		}
+	}
+
+	sort.Slice(o.entries, func(i, j int) bool { return o.entries[i].seq < o.entries[j].seq })
+
+	return o, nil
+}
+
+// Overflow устанавливает политику переполнения *Outbox,
+// по-умолчанию DropOldest
+func (o *Outbox) Overflow(p Overflow) *Outbox {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.overflow = p
+	return o
+}
+
+// Push добавляет контейнер в конец очереди. Запись сначала
+// пишется во временный файл, а затем переименовывается,
+// чтобы в очереди не оказалось недописанных записей
+func (o *Outbox) Push(c container) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	size := int64(len(b))
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.maxBytes > 0 && size > o.maxBytes {
+		return ErrFull // запись не поместится никогда
+	}
+
+	for o.full(size) {
+		if o.overflow == RejectNew || len(o.entries) == 0 {
+			return ErrFull
+		}
+		if err := o.remove(o.entries[0].seq); err != nil {
+			return err
+		}
+		o.dropped++
+	}
+
+	seq := o.seq + 1
+	path := o.path(seq)
+
+	if err := writeFile(path+tmpExt, b); err != nil {
+		return err
+	}
+	if err := os.Rename(path+tmpExt, path); err != nil {
+		return err
+	}
+
+	o.seq = seq
+	o.entries = append(o.entries, meta{seq: seq, size: size})
+	o.size += size
+
+	return nil
+}
+
+// Peek возвращает самую старую запись очереди, не удаляя её.
+// Если очередь пуста, возвращает ErrEmpty. Если запись
+// не удалось прочитать, номер записи всё равно возвращается,
+// чтобы её можно было удалить
+func (o *Outbox) Peek() (Entry, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.entries) == 0 {
+		return Entry{}, ErrEmpty
+	}
+
+	seq := o.entries[0].seq
+
+	e := Entry{Seq: seq}
+
+	b, err := os.ReadFile(o.path(seq))
+	if err != nil {
+		return e, err
+	}
+
+	if err := json.Unmarshal(b, &e.Container); err != nil {
+		return e, fmt.Errorf("outbox: entry %d: %w", seq, err)
+	}
+
+	return e, nil
+}
+
+// Remove удаляет запись с номером seq из очереди
+func (o *Outbox) Remove(seq uint64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.remove(seq)
+}
+
+// Len возвращает количество записей в очереди
+func (o *Outbox) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.entries)
+}
+
+// Dropped возвращает количество записей,
+// удалённых из-за переполнения очереди
+func (o *Outbox) Dropped() uint {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.dropped
+}
+
+// full сообщает, что запись размера size не помещается в очередь
+func (o *Outbox) full(size int64) bool {
+	if o.maxEntries > 0 && len(o.entries)+1 > o.maxEntries {
+		return true
+	}
+	return o.maxBytes > 0 && o.size+size > o.maxBytes
+}
+
+// remove удаляет запись, вызывается под блокировкой
+func (o *Outbox) remove(seq uint64) error {
+	for i := range o.entries {
+		if o.entries[i].seq != seq {
+			continue
+		}
+		if err := os.Remove(o.path(seq)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		o.size -= o.entries[i].size
+		o.entries = append(o.entries[:i], o.entries[i+1:]...)
+		return nil
+	}
+	return nil
+}
+
+// path возвращает путь к файлу записи
+func (o *Outbox) path(seq uint64) string {
+	return filepath.Join(o.dir, fmt.Sprintf("%020d%s", seq, ext))
+}
+
+// writeFile записывает данные и сбрасывает их на диск
+func writeFile(path string, b []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,121 @@
+package outbox
+
+import (
+	"errors"
+	"fmt"
+	"news/pkg/storage"
+	"reflect"
+	"testing"
+)
+
+func testContainer(n int) container {
+	return container{Items: []storage.Item{
+		{
+			Title:       fmt.Sprintf("Заголовок %d", n),
+			Description: "Описание",
+			PubDate:     1655806394,
+			Link:        fmt.Sprintf("https://test.com/%d", n),
+			Source:      "https://test.com/rss",
+		},
+	}}
+}
+
+func TestOutbox(t *testing.T) {
+
+	t.Run("очередь_переживает_перезапуск", func(t *testing.T) {
+		dir := t.TempDir()
+
+		o, err := Open(dir, 0, 0)
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+
+		for i := 1; i <= 3; i++ {
+			if err := o.Push(testContainer(i)); err != nil {
+				t.Fatalf("Outbox.Push() error = %v", err)
+			}
+		}
+
+		o, err = Open(dir, 0, 0) // открываем заново
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+
+		if o.Len() != 3 {
+			t.Fatalf("Outbox.Len() got = %d, want = %d", o.Len(), 3)
+		}
+
+		for i := 1; i <= 3; i++ {
+			e, err := o.Peek()
+			if err != nil {
+				t.Fatalf("Outbox.Peek() error = %v", err)
+			}
+			if got, want := e.Container.Items[0], testContainer(i).Items[0]; !reflect.DeepEqual(got, want) {
+				t.Fatalf("Outbox.Peek() got = %v, want = %v", got, want)
+			}
+			if err := o.Remove(e.Seq); err != nil {
+				t.Fatalf("Outbox.Remove() error = %v", err)
+			}
+		}
+
+		if _, err := o.Peek(); !errors.Is(err, ErrEmpty) {
+			t.Fatalf("Outbox.Peek() got error = %v, want = %v", err, ErrEmpty)
+		}
+	})
+
+	t.Run("переполнение_drop_oldest", func(t *testing.T) {
+		o, err := Open(t.TempDir(), 2, 0)
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+
+		for i := 1; i <= 3; i++ {
+			if err := o.Push(testContainer(i)); err != nil {
+				t.Fatalf("Outbox.Push() error = %v", err)
+			}
+		}
+
+		if o.Len() != 2 || o.Dropped() != 1 {
+			t.Fatalf("Outbox got len = %d, dropped = %d, want = %d, %d", o.Len(), o.Dropped(), 2, 1)
+		}
+
+		e, err := o.Peek()
+		if err != nil {
+			t.Fatalf("Outbox.Peek() error = %v", err)
+		}
+		if got, want := e.Container.Items[0].Link, testContainer(2).Items[0].Link; got != want {
+			t.Fatalf("Outbox.Peek() got = %s, want = %s", got, want)
+		}
+	})
+
+	t.Run("переполнение_reject_new", func(t *testing.T) {
+		o, err := Open(t.TempDir(), 1, 0)
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		o.Overflow(RejectNew)
+
+		if err := o.Push(testContainer(1)); err != nil {
+			t.Fatalf("Outbox.Push() error = %v", err)
+		}
+
+		if err := o.Push(testContainer(2)); !errors.Is(err, ErrFull) {
+			t.Fatalf("Outbox.Push() got error = %v, want = %v", err, ErrFull)
+		}
+
+		if o.Len() != 1 || o.Dropped() != 0 {
+			t.Fatalf("Outbox got len = %d, dropped = %d, want = %d, %d", o.Len(), o.Dropped(), 1, 0)
+		}
+	})
+
+	t.Run("ограничение_по_размеру", func(t *testing.T) {
+		o, err := Open(t.TempDir(), 0, 10)
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+
+		if err := o.Push(testContainer(1)); !errors.Is(err, ErrFull) {
+			t.Fatalf("Outbox.Push() got error = %v, want = %v", err, ErrFull)
+		}
+	})
+}
@@ -2,13 +2,17 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"news/pkg/storage"
+	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
-var ErrNoRows = pgx.ErrNoRows
+// ErrNoRows - новость не найдена, то же, что storage.ErrNotFound
+var ErrNoRows = storage.ErrNotFound
 
 // Postgres выполняет CRUD операции с БД
 type Postgres struct {
@@ -27,32 +31,61 @@ func New(connString string) (*Postgres, error) {
 	return &Postgres{db: pool}, pool.Ping(context.Background())
 }
 
+// Ping проверяет соединение с БД
+func (p *Postgres) Ping(ctx context.Context) error {
+	return p.db.Ping(ctx)
+}
+
 // Close выполняет закрытие подключения к БД
 func (p *Postgres) Close() error {
 	p.db.Close()
 	return nil
 }
 
-// Item находит по ссылке и возвращает rss-новость
+// Item находит по ссылке и возвращает rss-новость.
+// Если новости нет, возвращает storage.ErrNotFound
 func (p *Postgres) Item(ctx context.Context, link string) (storage.Item, error) {
+	return p.item(ctx, "n.link = $1", link)
+}
+
+// ItemByID находит по идентификатору и возвращает rss-новость.
+// Если новости нет, возвращает storage.ErrNotFound
+func (p *Postgres) ItemByID(ctx context.Context, id int64) (storage.Item, error) {
+	return p.item(ctx, "n.id = $1", id)
+}
+
+// item возвращает rss-новость, подходящую под условие where
+func (p *Postgres) item(ctx context.Context, where string, arg any) (storage.Item, error) {
 	stmt := `
 		SELECT
 			n.id,
 			n.title,
 			n.description,
+			n.html,
+			n.article,
+			n.image,
+			n.lang,
 			n.pub_date,
-			n.link
+			n.link,
+			n.source,
+			n.categories
 		FROM news as n
-		WHERE n.link = $1;`
+		WHERE ` + where + `;`
 
 	var item storage.Item
 
-	err := p.db.QueryRow(ctx, stmt, link).Scan(
-		&item.Id, &item.Title, &item.Description,
-		&item.PubDate, &item.Link)
+	err := p.db.QueryRow(ctx, stmt, arg).Scan(
+		&item.Id, &item.Title, &item.Description, &item.HTML,
+		&item.Article, &item.Image, &item.Lang, &item.PubDate, &item.Link, &item.Source, &item.Categories)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return item, storage.ErrNotFound
+	}
 	if err != nil {
 		return item, err
 	}
+	if len(item.Categories) == 0 {
+		item.Categories = nil // пустой массив в БД
+	}
 
 	return item, nil
 }
@@ -60,20 +93,48 @@ func (p *Postgres) Item(ctx context.Context, link string) (storage.Item, error)
 // Items возвращает списком по крайней мере n rss-новостей
 // отсортированных по дате публикации по убыванию
 func (p *Postgres) Items(ctx context.Context, n int) ([]storage.Item, error) {
+	return p.FilterItems(ctx, n, storage.Filter{})
+}
+
+// FilterItems возвращает не больше n rss-новостей, подходящих
+// под условия f, отсортированных по дате публикации по убыванию.
+// Если n отрицательное, возвращает storage.ErrInvalidLimit
+func (p *Postgres) FilterItems(ctx context.Context, n int, f storage.Filter) ([]storage.Item, error) {
+	if n < 0 {
+		return nil, storage.ErrInvalidLimit
+	}
+
 	stmt := `
 		SELECT
 			n.id,
 			n.title,
 			n.description,
+			n.html,
+			n.article,
+			n.image,
+			n.lang,
 			n.pub_date,
-			n.link
+			n.link,
+			n.source,
+			n.categories
 		FROM news as n
-		ORDER BY n.pub_date DESC
-		LIMIT $1;`
+		WHERE ($2 = '' OR n.lang = $2)
+			AND ($3 = '' OR n.source = $3)
+			AND ($4 = '' OR n.title ILIKE $4 OR n.description ILIKE $4)
+			AND ($6 = '' OR EXISTS (
+				SELECT 1 FROM unnest(n.categories) AS c WHERE lower(c) = lower($6)))
+		ORDER BY n.pub_date DESC, n.id DESC
+		LIMIT $1 OFFSET $5;`
+
+	// подстрока для ILIKE, спецсимволы шаблона экранируются
+	var query string
+	if f.Query != "" {
+		query = "%" + likeEscaper.Replace(f.Query) + "%"
+	}
 
 	var items []storage.Item
 
-	rows, err := p.db.Query(ctx, stmt, n)
+	rows, err := p.db.Query(ctx, stmt, n, f.Lang, f.Source, query, f.Offset, f.Category)
 	if err != nil {
 		return nil, err
 	}
@@ -83,11 +144,14 @@ func (p *Postgres) Items(ctx context.Context, n int) ([]storage.Item, error) {
 
 		var item storage.Item
 
-		err := rows.Scan(&item.Id, &item.Title,
-			&item.Description, &item.PubDate, &item.Link)
+		err := rows.Scan(&item.Id, &item.Title, &item.Description, &item.HTML,
+			&item.Article, &item.Image, &item.Lang, &item.PubDate, &item.Link, &item.Source, &item.Categories)
 		if err != nil {
 			return nil, err
 		}
+		if len(item.Categories) == 0 {
+			item.Categories = nil // пустой массив в БД
+		}
 
 		items = append(items, item)
 	}
@@ -95,105 +159,357 @@ func (p *Postgres) Items(ctx context.Context, n int) ([]storage.Item, error) {
 	return items, rows.Err()
 }
 
+// likeEscaper экранирует спецсимволы шаблона LIKE
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// categories возвращает категории новости для записи в БД:
+// nil-слайс pgx записывает как NULL, а не как пустой массив
+func categories(item storage.Item) []string {
+	if item.Categories == nil {
+		return []string{}
+	}
+	return item.Categories
+}
+
+// copyThreshold - размер пачки новостей, начиная с которого
+// AddItems вносит их через COPY во временную таблицу,
+// а не через *pgx.Batch
+const copyThreshold = 64
+
 // AddItems добавляет в БД слайс rss-новостей,
 // ингорирует те новости, что уже есть в БД
-func (p *Postgres) AddItems(ctx context.Context, items []storage.Item) error {
+func (p *Postgres) AddItems(ctx context.Context, items []storage.Item) (storage.AddResult, error) {
+	if len(items) >= copyThreshold {
+		return p.addItemsByCopy(ctx, items)
+	}
 	return p.addItemsByBatch(ctx, items)
 }
 
 // addItemsByBatch вносит в БД слайс rss-новостей,
-// используя *pgx.Batch
-func (p *Postgres) addItemsByBatch(ctx context.Context, items []storage.Item) error {
+// используя *pgx.Batch. Действительно добавленные
+// новости возвращаются в storage.AddResult
+func (p *Postgres) addItemsByBatch(ctx context.Context, items []storage.Item) (storage.AddResult, error) {
 
-	return p.db.BeginFunc(ctx, func(tx pgx.Tx) error {
+	var res storage.AddResult
+
+	err := p.db.BeginFunc(ctx, func(tx pgx.Tx) error {
 
 		b := new(pgx.Batch) // создаем объект pgx.Batch
 
 		stmt := `
-		INSERT INTO news(title, description, pub_date, link)
-		VALUES ($1, $2, $3, $4)
-		ON CONFLICT (link) DO NOTHING;`
+		INSERT INTO news(title, description, html, article, image, lang, pub_date, link, source, categories)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (link) DO NOTHING
+		RETURNING id;`
 
 		// добавляем все запросы в очередь
 		for i := range items {
-			b.Queue(stmt, items[i].Title, items[i].Description,
-				items[i].PubDate, items[i].Link)
+			b.Queue(stmt, items[i].Title, items[i].Description, items[i].HTML,
+				items[i].Article, items[i].Image, items[i].Lang, items[i].PubDate, items[i].Link, items[i].Source,
+				categories(items[i]))
 		}
 
-		return tx.SendBatch(ctx, b).Close() // исполняем запросы и закрываем операцию
+		br := tx.SendBatch(ctx, b) // исполняем запросы
+
+		for i := range items {
+			item := items[i]
+			err := br.QueryRow().Scan(&item.Id)
+			if err == pgx.ErrNoRows { // конфликт по ссылке - дубликат
+				res.Skipped++
+				continue
+			}
+			if err != nil {
+				_ = br.Close()
+				return err
+			}
+			res.Inserted = append(res.Inserted, item)
+		}
 
+		return br.Close() // закрываем операцию
 	})
+	if err != nil {
+		return storage.AddResult{}, err
+	}
+
+	return res, nil
 }
 
 // addItemsByCopy вносит в БД слайс rss-новостей,
-// используя Posrgresql copy protocol
-func (p *Postgres) addItemsByCopy(ctx context.Context, items []storage.Item) error {
-
-	return p.db.BeginFunc(ctx, func(tx pgx.Tx) error {
+// используя Posrgresql copy protocol. COPY не умеет ON CONFLICT,
+// поэтому новости сначала копируются во временную таблицу,
+// а затем переносятся в news с пропуском дубликатов.
+// Действительно добавленные новости возвращаются в storage.AddResult
+func (p *Postgres) addItemsByCopy(ctx context.Context, items []storage.Item) (storage.AddResult, error) {
+
+	var res storage.AddResult
+
+	err := p.db.BeginFunc(ctx, func(tx pgx.Tx) error {
+
+		// временная таблица живёт до конца транзакции
+		_, err := tx.Exec(ctx, `
+		CREATE TEMPORARY TABLE news_staging (
+			title TEXT,
+			description TEXT,
+			html TEXT,
+			article TEXT,
+			image TEXT,
+			lang TEXT,
+			pub_date BIGINT,
+			link TEXT,
+			source TEXT,
+			categories TEXT[]
+		) ON COMMIT DROP;`)
+		if err != nil {
+			return err
+		}
 
 		cf := pgx.CopyFromSlice(len(items), func(i int) ([]interface{}, error) {
-			return []any{items[i].Title, items[i].Description, items[i].PubDate, items[i].Link}, nil
+			return []any{items[i].Title, items[i].Description, items[i].HTML,
+				items[i].Article, items[i].Image, items[i].Lang, items[i].PubDate, items[i].Link, items[i].Source,
+				categories(items[i])}, nil
 		}) // // функция копирования из слайса
 
-		table := pgx.Identifier{"news"}                                       // имя таблицы
-		columns := pgx.Identifier{"title", "description", "pub_date", "link"} // имена атрибутов
+		table := pgx.Identifier{"news_staging"} // имя таблицы
+		columns := pgx.Identifier{"title", "description", "html", "article", "image",
+			"lang", "pub_date", "link", "source", "categories"} // имена атрибутов
 
-		_, err := tx.CopyFrom(ctx, table, columns, cf) // вносим данные в БД с помощью postgres COPY FROM
+		_, err = tx.CopyFrom(ctx, table, columns, cf) // вносим данные с помощью postgres COPY FROM
 		if err != nil {
 			return err
 		}
-		return nil
 
+		rows, err := tx.Query(ctx, `
+		INSERT INTO news(title, description, html, article, image, lang, pub_date, link, source, categories)
+		SELECT title, description, html, article, image, lang, pub_date, link, source, categories
+		FROM news_staging
+		ON CONFLICT (link) DO NOTHING
+		RETURNING id, link;`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		// индексы новостей по ссылке, чтобы сопоставить
+		// возвращённые идентификаторы с исходными новостями
+		idx := make(map[string]int, len(items))
+		for i := range items {
+			idx[items[i].Link] = i
+		}
+
+		for rows.Next() {
+			var id int64
+			var link string
+			if err := rows.Scan(&id, &link); err != nil {
+				return err
+			}
+			item := items[idx[link]]
+			item.Id = id
+			res.Inserted = append(res.Inserted, item)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		res.Skipped = len(items) - len(res.Inserted)
+
+		return nil
 	})
+	if err != nil {
+		return storage.AddResult{}, err
+	}
+
+	return res, nil
 }
 
 // AddItem добавляет в БД rss-новость, если новость уже
 // есть в БД, то no-op
 func (p *Postgres) AddItem(ctx context.Context, item storage.Item) error {
 	stmt := `
-		INSERT INTO news(title, description, pub_date, link)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO news(title, description, html, article, image, lang, pub_date, link, source, categories)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 		ON CONFLICT (link) DO NOTHING;`
 
-	return p.exec(ctx, stmt, item.Title, item.Description, item.PubDate, item.Link)
+	_, err := p.exec(ctx, stmt, item.Title, item.Description, item.HTML,
+		item.Article, item.Image, item.Lang, item.PubDate, item.Link, item.Source, categories(item))
+	return err
 }
 
-// DeleteItem удаляет из БД rss-новость
-func (p *Postgres) DeleteItem(ctx context.Context, item storage.Item) error {
+// DeleteItem удаляет из БД rss-новость по ссылке.
+// Если новости нет, возвращает storage.ErrNotFound
+func (p *Postgres) DeleteItem(ctx context.Context, link string) error {
 	stmt := `
 		DELETE FROM news
 		WHERE link = $1;`
 
-	return p.exec(ctx, stmt, item.Link)
+	n, err := p.exec(ctx, stmt, link)
+	if err == nil && n == 0 {
+		return storage.ErrNotFound
+	}
+	return err
 }
 
-// UpdateItem обновляет в БД rss-новость
+// UpdateItem обновляет в БД rss-новость, новость
+// определяется ссылкой. Если новости нет,
+// возвращает storage.ErrNotFound
 func (p *Postgres) UpdateItem(ctx context.Context, item storage.Item) error {
 	stmt := `
 		UPDATE news
 		SET 
 			title = $1,
 			description = $2,
-			pub_date = $3
-			WHERE link = $4;`
+			html = $3,
+			article = $4,
+			image = $5,
+			lang = $6,
+			pub_date = $7,
+			categories = $8
+			WHERE link = $9;`
+
+	n, err := p.exec(ctx, stmt, item.Title, item.Description, item.HTML,
+		item.Article, item.Image, item.Lang, item.PubDate, categories(item), item.Link)
+	if err == nil && n == 0 {
+		return storage.ErrNotFound
+	}
+	return err
+}
+
+// pruneBatch - сколько новостей удаляется одним запросом,
+// чтобы не держать долгих блокировок на таблице
+const pruneBatch = 1000
+
+// Prune удаляет новости согласно политике хранения r.
+// Удаление идёт пачками по pruneBatch записей
+func (p *Postgres) Prune(ctx context.Context, r storage.Retention) (storage.PruneResult, error) {
+	var res storage.PruneResult
+	var err error
+
+	if r.MaxAge > 0 {
+		stmt := `
+		DELETE FROM news
+		WHERE id IN (
+			SELECT id FROM news
+			WHERE pub_date < $1
+			LIMIT $2);`
+
+		cutoff := time.Now().Add(-r.MaxAge).Unix()
+		res.ByAge, err = p.deleteInBatches(ctx, stmt, cutoff)
+		if err != nil {
+			return res, err
+		}
+	}
+
+	if r.MaxPerSource > 0 {
+		// окно по всей таблице считается один раз за проход,
+		// а не для каждой пачки
+		stmt := `
+		SELECT id FROM (
+			SELECT
+				id,
+				row_number() OVER (PARTITION BY source ORDER BY pub_date DESC) AS rn
+			FROM news) AS ranked
+		WHERE rn > $1;`
+
+		res.BySource, err = p.deleteSelected(ctx, stmt, r.MaxPerSource)
+		if err != nil {
+			return res, err
+		}
+	}
+
+	if r.MaxTotal > 0 {
+		stmt := `
+		SELECT id FROM news
+		ORDER BY pub_date DESC
+		OFFSET $1;`
+
+		res.ByTotal, err = p.deleteSelected(ctx, stmt, r.MaxTotal)
+		if err != nil {
+			return res, err
+		}
+	}
+
+	return res, nil
+}
+
+// deleteInBatches повторяет запрос на удаление, пока он удаляет
+// полную пачку записей. Размер пачки передаётся последним аргументом
+func (p *Postgres) deleteInBatches(ctx context.Context, sql string, args ...any) (int64, error) {
+	var total int64
+
+	args = append(args, pruneBatch)
+
+	for {
+		ct, err := p.db.Exec(ctx, sql, args...)
+		if err != nil {
+			return total, err
+		}
+		total += ct.RowsAffected()
+
+		if ct.RowsAffected() < pruneBatch {
+			return total, nil
+		}
+	}
+}
+
+// deleteSelected удаляет пачками по pruneBatch записи,
+// идентификаторы которых возвращает запрос sql
+func (p *Postgres) deleteSelected(ctx context.Context, sql string, args ...any) (int64, error) {
+	ids, err := p.selectIDs(ctx, sql, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for len(ids) > 0 {
+		n := pruneBatch
+		if n > len(ids) {
+			n = len(ids)
+		}
+
+		ct, err := p.db.Exec(ctx, `DELETE FROM news WHERE id = ANY($1);`, ids[:n])
+		if err != nil {
+			return total, err
+		}
+		total += ct.RowsAffected()
+		ids = ids[n:]
+	}
+
+	return total, nil
+}
+
+// selectIDs возвращает идентификаторы, которые выбирает запрос sql
+func (p *Postgres) selectIDs(ctx context.Context, sql string, args ...any) ([]int64, error) {
+	rows, err := p.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
 
-	return p.exec(ctx, stmt, item.Title,
-		item.Description, item.PubDate, item.Link)
+	return ids, rows.Err()
 }
 
 // exec вспомогательная функция, выполняет
-// *pgx.conn.Exec() в транзакции
-func (p *Postgres) exec(ctx context.Context, sql string, args ...any) error {
+// *pgx.conn.Exec() в транзакции и возвращает
+// количество затронутых строк
+func (p *Postgres) exec(ctx context.Context, sql string, args ...any) (int64, error) {
 	tx, err := p.db.Begin(ctx)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer tx.Rollback(ctx)
 
-	_, err = p.db.Exec(ctx, sql, args...)
+	tag, err := p.db.Exec(ctx, sql, args...)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return tx.Commit(ctx)
+	return tag.RowsAffected(), tx.Commit(ctx)
 }
@@ -2,11 +2,14 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"news/pkg/storage"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
 )
 
 var tdb *Postgres // тестовая БД
@@ -20,7 +23,8 @@ func restoreTestDB(testdb *Postgres) error {
 		return err
 	}
 
-	return tdb.exec(context.Background(), string(b))
+	_, err = tdb.exec(context.Background(), string(b))
+	return err
 }
 
 func TestMain(m *testing.M) {
@@ -56,11 +60,16 @@ func TestPostgres(t *testing.T) {
 	t.Run("AddItems()", func(t *testing.T) {
 		wantItems := []storage.Item{testItem1, testItem2, testItem3, testItem4}
 
-		err := tdb.AddItems(context.Background(), wantItems)
+		res, err := tdb.AddItems(context.Background(), wantItems)
 		if err != nil {
 			t.Fatalf("Postgres.AddItems() error = %v", err)
 		}
 
+		if len(res.Inserted) != len(wantItems) || res.Skipped != 0 {
+			t.Fatalf("Postgres.AddItems() got inserted = %d, skipped = %d, want = %d, %d",
+				len(res.Inserted), res.Skipped, len(wantItems), 0)
+		}
+
 		gotItems, err := tdb.Items(context.Background(), len(wantItems))
 		if err != nil {
 			t.Fatalf("Postgres.Items() error = %v", err)
@@ -71,7 +80,7 @@ func TestPostgres(t *testing.T) {
 		}
 
 		for i := range wantItems {
-			if gotItems[i] != wantItems[i] {
+			if !reflect.DeepEqual(gotItems[i], wantItems[i]) {
 				t.Fatalf("Postgres.AddItems() got = %v, want = %v", gotItems[i], wantItems[i])
 			}
 		}
@@ -85,7 +94,7 @@ func TestPostgres(t *testing.T) {
 			t.Fatalf("Postgres.Item() error = %v", err)
 		}
 
-		if got != want {
+		if !reflect.DeepEqual(got, want) {
 			t.Fatalf("Postgres.Item() got = %v, want = %v", got, want)
 		}
 	})
@@ -106,11 +115,65 @@ func TestPostgres(t *testing.T) {
 
 		got := v[0]
 
-		if got != want {
+		if !reflect.DeepEqual(got, want) {
 			t.Fatalf("Postgres.Items() got = %v, want = %v", got, want)
 		}
 	})
 
+	t.Run("FilterItems()", func(t *testing.T) {
+		got, err := tdb.FilterItems(context.Background(), 10, storage.Filter{Lang: "en"})
+		if err != nil {
+			t.Fatalf("Postgres.FilterItems() error = %v", err)
+		}
+
+		if len(got) != 1 || !reflect.DeepEqual(got[0], testItem2) {
+			t.Fatalf("Postgres.FilterItems() got = %v, want = %v", got, testItem2)
+		}
+
+		for _, f := range []storage.Filter{
+			{Query: "заголовок 2"},
+			{Lang: "en", Query: "ОПИСАНИЕ"},
+			{Category: "программирование"},
+		} {
+			got, err := tdb.FilterItems(context.Background(), 10, f)
+			if err != nil {
+				t.Fatalf("Postgres.FilterItems() error = %v", err)
+			}
+			if len(got) != 1 || !reflect.DeepEqual(got[0], testItem2) {
+				t.Errorf("Postgres.FilterItems(%+v) got = %v, want = %v", f, got, testItem2)
+			}
+		}
+
+		for _, f := range []storage.Filter{
+			{Lang: "en", Offset: 1},
+			{Query: "%"},
+			{Source: "https://other.com/rss"},
+			{Category: "Rust"},
+		} {
+			got, err := tdb.FilterItems(context.Background(), 10, f)
+			if err != nil {
+				t.Fatalf("Postgres.FilterItems() error = %v", err)
+			}
+			if len(got) != 0 {
+				t.Errorf("Postgres.FilterItems(%+v) got = %v, want nothing", f, got)
+			}
+		}
+	})
+
+	t.Run("ItemByID()", func(t *testing.T) {
+		got, err := tdb.ItemByID(context.Background(), testItem2.Id)
+		if err != nil {
+			t.Fatalf("Postgres.ItemByID() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, testItem2) {
+			t.Errorf("Postgres.ItemByID() got = %v, want = %v", got, testItem2)
+		}
+
+		if _, err := tdb.ItemByID(context.Background(), -1); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("Postgres.ItemByID() got error = %v, want = %v", err, storage.ErrNotFound)
+		}
+	})
+
 	t.Run("UpdateItem()", func(t *testing.T) {
 
 		testItem1.Link = testItem4.Link
@@ -126,33 +189,166 @@ func TestPostgres(t *testing.T) {
 			t.Fatalf("Postgres.Item() error = %v", err)
 		}
 
-		if got != testItem1 {
+		if !reflect.DeepEqual(got, testItem1) {
 			t.Fatalf("Postgres.UpdateItem() got = %v, want = %v", got, testItem1)
 		}
 	})
 
 	t.Run("DeleteItem()", func(t *testing.T) {
 
-		err := tdb.DeleteItem(context.Background(), testItem1)
+		err := tdb.DeleteItem(context.Background(), testItem1.Link)
 		if err != nil {
 			t.Fatalf("Postgres.DeleteItem() error = %v", err)
 		}
 
+		err = tdb.DeleteItem(context.Background(), testItem1.Link)
+		if !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("Postgres.DeleteItem() got error = %v, want = %v", err, storage.ErrNotFound)
+		}
+
 		got, err := tdb.Item(context.Background(), testItem1.Link)
 		if err != nil && err != ErrNoRows {
 			t.Fatalf("Postgres.Item() error = %v", err)
 		}
 
-		if got != (storage.Item{}) {
+		if !reflect.DeepEqual(got, storage.Item{}) {
 			t.Fatalf("Postgres.DeleteItem() got = %v, want nothing", got)
 		}
 	})
+
+	t.Run("addItemsByCopy()", func(t *testing.T) {
+
+		items := benchItems(copyThreshold, "copy")
+		items = append(items, testItem2) // testItem2 уже есть в БД
+
+		res, err := tdb.addItemsByCopy(context.Background(), items)
+		if err != nil {
+			t.Fatalf("Postgres.addItemsByCopy() error = %v", err)
+		}
+
+		if got, want := len(res.Inserted), copyThreshold; got != want {
+			t.Fatalf("Postgres.addItemsByCopy() got inserted = %d, want = %d", got, want)
+		}
+
+		if res.Skipped != 1 {
+			t.Fatalf("Postgres.addItemsByCopy() got skipped = %d, want = %d", res.Skipped, 1)
+		}
+
+		for _, it := range res.Inserted {
+			if it.Id == 0 {
+				t.Fatalf("Postgres.addItemsByCopy() got item without id = %v", it)
+			}
+		}
+
+		// повторная вставка ничего не добавляет
+		res, err = tdb.addItemsByBatch(context.Background(), items)
+		if err != nil {
+			t.Fatalf("Postgres.addItemsByBatch() error = %v", err)
+		}
+
+		if len(res.Inserted) != 0 || res.Skipped != len(items) {
+			t.Fatalf("Postgres.addItemsByBatch() got inserted = %d, skipped = %d, want = %d, %d",
+				len(res.Inserted), res.Skipped, 0, len(items))
+		}
+	})
+
+	t.Run("Prune()", func(t *testing.T) {
+
+		feed := benchItems(3, "prune")
+		for i := range feed {
+			feed[i].Source = "https://feed.test"
+			feed[i].PubDate += 1000 // самые свежие новости
+		}
+		if _, err := tdb.AddItems(context.Background(), feed); err != nil {
+			t.Fatalf("Postgres.AddItems() error = %v", err)
+		}
+
+		before, err := tdb.Items(context.Background(), 1000)
+		if err != nil {
+			t.Fatalf("Postgres.Items() error = %v", err)
+		}
+
+		// остаются по две новости двух лент
+		res, err := tdb.Prune(context.Background(), storage.Retention{MaxPerSource: 2})
+		if err != nil {
+			t.Fatalf("Postgres.Prune() error = %v", err)
+		}
+		if want := int64(len(before) - 4); res.BySource != want {
+			t.Fatalf("Postgres.Prune() got by source = %d, want = %d", res.BySource, want)
+		}
+
+		res, err = tdb.Prune(context.Background(), storage.Retention{MaxTotal: 1})
+		if err != nil {
+			t.Fatalf("Postgres.Prune() error = %v", err)
+		}
+		if res.ByTotal != 3 {
+			t.Fatalf("Postgres.Prune() got by total = %d, want = %d", res.ByTotal, 3)
+		}
+
+		// все тестовые новости опубликованы давно
+		res, err = tdb.Prune(context.Background(), storage.Retention{MaxAge: time.Hour})
+		if err != nil {
+			t.Fatalf("Postgres.Prune() error = %v", err)
+		}
+		if res.ByAge != 1 {
+			t.Fatalf("Postgres.Prune() got by age = %d, want = %d", res.ByAge, 1)
+		}
+	})
+}
+
+// benchItems возвращает n новостей с уникальными ссылками
+func benchItems(n int, prefix string) []storage.Item {
+	items := make([]storage.Item, n)
+	for i := range items {
+		items[i] = storage.Item{
+			Title:       fmt.Sprintf("Заголовок %s %d", prefix, i),
+			Description: fmt.Sprintf("Описание %s %d", prefix, i),
+			PubDate:     1655806390 - int64(i),
+			Link:        fmt.Sprintf("https://bench.com/%s/%d", prefix, i),
+		}
+	}
+	return items
+}
+
+func benchmarkAddItems(b *testing.B, add func(context.Context, []storage.Item) (storage.AddResult, error)) {
+	if _, ok := os.LookupEnv(DbEnv); !ok {
+		b.Skipf("environment variable %s not set, skipping benchmarks", DbEnv)
+	}
+
+	for _, size := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("size_%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				items := benchItems(size, fmt.Sprintf("%s/%d/%d", b.Name(), size, i))
+				if _, err := add(context.Background(), items); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+
+	b.Cleanup(func() {
+		_, _ = tdb.exec(context.Background(), `DELETE FROM news WHERE link LIKE 'https://bench.com/%';`)
+	})
+}
+
+func BenchmarkPostgres_addItemsByBatch(b *testing.B) {
+	benchmarkAddItems(b, func(ctx context.Context, items []storage.Item) (storage.AddResult, error) {
+		return tdb.addItemsByBatch(ctx, items)
+	})
+}
+
+func BenchmarkPostgres_addItemsByCopy(b *testing.B) {
+	benchmarkAddItems(b, func(ctx context.Context, items []storage.Item) (storage.AddResult, error) {
+		return tdb.addItemsByCopy(ctx, items)
+	})
 }
 
 var testItem1 = storage.Item{
 	Id:          1,
 	Title:       "Заголовок 1",
 	Description: "Описание 1",
+	HTML:        "<p>Описание 1</p>",
+	Lang:        "ru",
 	PubDate:     1655806394,
 	Link:        "https://test.com/14987527",
 }
@@ -161,8 +357,10 @@ var testItem2 = storage.Item{
 	Id:          2,
 	Title:       "Заголовок 2",
 	Description: "Описание 2",
+	Lang:        "en",
 	PubDate:     1655806393,
 	Link:        "https://test.com/14987528",
+	Categories:  []string{"Go", "Программирование"},
 }
 
 var testItem3 = storage.Item{
@@ -5,15 +5,36 @@ CREATE TABLE IF NOT EXISTS news (
     id BIGSERIAL PRIMARY KEY,
     title TEXT NOT NULL,
 	description TEXT NOT NULL,
+    -- описание в безопасном html
+    html TEXT NOT NULL DEFAULT '',
+    -- полный текст статьи со страницы новости
+    article TEXT NOT NULL DEFAULT '',
+    -- адрес картинки для превью
+    image TEXT NOT NULL DEFAULT '',
+    -- язык новости по ISO 639-1, пустой - не определён
+    lang TEXT NOT NULL DEFAULT '',
     pub_date BIGINT CHECK(pub_date > 0) DEFAULT extract(epoch from now()),
 
     -- Согласно RSS 2.0 у новости(item) есть три обязательных атрибута 
     -- (title, description и link).
     -- link - хороший кандидат в качестве ключа поиска новости.
-    link TEXT NOT NULL UNIQUE
+    link TEXT NOT NULL UNIQUE,
+
+    -- rss-лента, из которой получена новость
+    source TEXT NOT NULL DEFAULT '',
+
+    -- категории новости из rss-ленты
+    categories TEXT[] NOT NULL DEFAULT '{}'
 );
 
 -- индекс для атрибута pub_date.
 -- нисходящий B-tree индекс, так как модель данных предполагает
 -- выборку последних по дате публикации новостей
-CREATE INDEX IF NOT EXISTS pub_date_idx ON news(pub_date DESC);
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS pub_date_idx ON news(pub_date DESC);
+
+-- индекс для удаления старых новостей каждой rss-ленты
+-- согласно политике хранения
+CREATE INDEX IF NOT EXISTS source_pub_date_idx ON news(source, pub_date DESC);
+
+-- индекс для выборки последних новостей на одном языке
+CREATE INDEX IF NOT EXISTS lang_pub_date_idx ON news(lang, pub_date DESC);
@@ -4,6 +4,12 @@ CREATE TABLE IF NOT EXISTS news (
     id BIGSERIAL PRIMARY KEY,
     title TEXT NOT NULL,
 	description TEXT,
+    html TEXT NOT NULL DEFAULT '',
+    article TEXT NOT NULL DEFAULT '',
+    image TEXT NOT NULL DEFAULT '',
+    lang TEXT NOT NULL DEFAULT '',
     pub_date BIGINT CHECK(pub_date > 0) DEFAULT extract(epoch from now()),
-    link TEXT UNIQUE
-);
\ No newline at end of file
+    link TEXT UNIQUE,
+    source TEXT NOT NULL DEFAULT '',
+    categories TEXT[] NOT NULL DEFAULT '{}'
+);
@@ -3,18 +3,120 @@ package storage
 import (
 	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
+	"net/url"
+	"news/pkg/langdetect"
+	"news/pkg/pubdate"
+	"news/pkg/sanitize"
+	"path"
+	"strings"
 	"time"
 
-	strip "github.com/grokify/html-strip-tags-go"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // Storage - контракт на работу с БД
 type Storage interface {
-	Items(ctx context.Context, n int) ([]Item, error) // Получить все новости списком
-	AddItems(context.Context, []Item) error           // Добавить новости списком
-	Close() error                                     // закрыть БД
+	Items(ctx context.Context, n int) ([]Item, error)    // Получить все новости списком
+	AddItems(context.Context, []Item) (AddResult, error) // Добавить новости списком
+	Close() error                                        // закрыть БД
+}
+
+var (
+	// ErrNotFound - новость не найдена
+	ErrNotFound = errors.New("storage: item not found")
+	// ErrInvalidLimit - запрошено отрицательное количество новостей
+	ErrInvalidLimit = errors.New("storage: negative limit")
+)
+
+// AddResult - итог добавления новостей списком. Если AddItems
+// вернула ошибку, в Inserted могут быть новости, которые всё же
+// записаны до ошибки, остальные поля тогда не заполняются
+type AddResult struct {
+	Inserted []Item // новости, которых не было в БД, с присвоенными БД идентификаторами
+	Updated  int    // новости, которые уже были в БД и были обновлены
+	Skipped  int    // дубликаты, оставленные без изменений
+}
+
+// Retention - политика хранения новостей.
+// Нулевое значение поля означает отсутствие ограничения
+type Retention struct {
+	MaxAge       time.Duration // новости, опубликованные раньше, удаляются
+	MaxPerSource int           // сколько последних новостей хранить для каждой rss-ленты
+	MaxTotal     int           // сколько последних новостей хранить всего
+}
+
+// IsZero сообщает, что политика ничего не ограничивает
+func (r Retention) IsZero() bool {
+	return r == Retention{}
+}
+
+// PruneResult - сколько новостей удалено по каждому из правил Retention
+type PruneResult struct {
+	// удалено по возрасту. Всегда 0 у хранилищ, в которых
+	// старые новости удаляет сама БД, например у mongodb
+	// по TTL-индексу: сколько удалила БД, неизвестно
+	ByAge    int64
+	BySource int64
+	ByTotal  int64
+}
+
+// Total - всего удалено новостей
+func (r PruneResult) Total() int64 {
+	return r.ByAge + r.BySource + r.ByTotal
+}
+
+// Pruner - хранилище, которое умеет удалять новости
+// согласно политике хранения. Удаление по возрасту хранилище
+// может поручить самой БД, тогда оно не попадает в PruneResult
+type Pruner interface {
+	Prune(ctx context.Context, r Retention) (PruneResult, error)
+}
+
+// Filter - условия выборки новостей.
+// Пустое поле не ограничивает выборку
+type Filter struct {
+	Lang     string // язык новости, например "ru"
+	Source   string // rss-лента новости
+	Query    string // подстрока заголовка или описания, без учёта регистра
+	Category string // категория новости, без учёта регистра
+	Offset   int    // сколько первых подходящих новостей пропустить
+}
+
+// Filterer - хранилище, которое умеет выбирать новости по условиям
+type Filterer interface {
+	// FilterItems возвращает не больше n новостей, подходящих под f,
+	// отсортированных по дате публикации по убыванию. Если n
+	// отрицательное, возвращает ErrInvalidLimit
+	FilterItems(ctx context.Context, n int, f Filter) ([]Item, error)
+}
+
+// Editor - хранилище, которое умеет работать с отдельными
+// новостями. Новость определяется ссылкой, если новости
+// нет, методы возвращают ErrNotFound
+type Editor interface {
+	Item(ctx context.Context, link string) (Item, error)
+	AddItem(ctx context.Context, item Item) error // если новость уже есть, то no-op
+	UpdateItem(ctx context.Context, item Item) error
+	DeleteItem(ctx context.Context, link string) error
+}
+
+// IDGetter - хранилище, которое находит новость
+// по идентификатору Id, например postgres
+type IDGetter interface {
+	ItemByID(ctx context.Context, id int64) (Item, error)
+}
+
+// OIDGetter - хранилище, которое находит новость
+// по идентификатору Oid, например mongodb
+type OIDGetter interface {
+	ItemByOID(ctx context.Context, oid primitive.ObjectID) (Item, error)
+}
+
+// Pinger - хранилище, которое умеет проверять соединение с БД
+type Pinger interface {
+	Ping(ctx context.Context) error
 }
 
 // Item - модель данных rss-новости
@@ -23,8 +125,31 @@ type Item struct {
 	Oid         primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
 	Title       string             `json:"title" bson:"title"`
 	PubDate     int64              `json:"pubTime" bson:"pubDate"`
-	Description string             `json:"content" bson:"description"`
+	Description string             `json:"content" bson:"description"` // описание простым текстом
+	HTML        string             `json:"html" bson:"html"`           // описание в безопасном html
+	Article     string             `json:"article" bson:"article"`     // полный текст статьи в безопасном html, если загружен
+	Image       string             `json:"image" bson:"image"`         // адрес картинки для превью
+	Lang        string             `json:"lang" bson:"lang"`           // язык новости по ISO 639-1
 	Link        string             `json:"link" bson:"link"`
+	Source      string             `json:"source" bson:"source"` // rss-лента, из которой получена новость
+
+	// автор нужен для фильтрации новостей перед записью,
+	// в БД не хранится. Категории хранятся для выборки по ним
+	Author     string   `json:"author,omitempty" bson:"-"`
+	Categories []string `json:"categories,omitempty" bson:"categories,omitempty"`
+}
+
+// DetectLang определяет язык новости, если он ещё не известен:
+// сначала берётся язык ленты lang, например из тега <language>,
+// затем язык определяется по заголовку и описанию
+func (i *Item) DetectLang(lang string) {
+	if i.Lang != "" {
+		return
+	}
+	if i.Lang = langdetect.Normalize(lang); i.Lang != "" {
+		return
+	}
+	i.Lang = langdetect.Detect(i.Title + "\n" + i.Description)
 }
 
 func (i Item) String() string {
@@ -35,7 +160,8 @@ func (i Item) String() string {
 // ItemContainer - контейнер содержащий rss-новости.
 // Используется для декодирования xml
 type ItemContainer struct {
-	Items []Item `xml:"channel>item"`
+	Items    []Item `xml:"channel>item"`
+	Language string `xml:"channel>language" json:"-"` // язык rss-ленты, если указан
 }
 
 // xmlItem - копия Item, единственная польза
@@ -46,18 +172,106 @@ type xmlItem struct {
 	XMLName     xml.Name `xml:"item"`
 	Title       string   `xml:"title"`
 	PubDate     unix     `xml:"pubDate"`
+	Date        unix     `xml:"date"` // dc:date, если pubDate не указан
 	Description string   `xml:"description"`
 	Link        string   `xml:"link"`
+	Author      string   `xml:"author"`
+	Creator     string   `xml:"creator"` // dc:creator, если автор не указан
+	Categories  []string `xml:"category"`
+	Lang        string   `xml:"lang,attr"` // xml:lang
+
+	// картинки из расширения Media RSS и вложений
+	Media      []xmlMedia `xml:"content"`   // media:content
+	Thumbnails []xmlMedia `xml:"thumbnail"` // media:thumbnail
+	Group      struct {
+		Media      []xmlMedia `xml:"content"`
+		Thumbnails []xmlMedia `xml:"thumbnail"`
+	} `xml:"group"` // media:group
+	Enclosures []xmlMedia `xml:"enclosure"`
+}
+
+// xmlMedia - медиа-файл новости: media:content,
+// media:thumbnail или enclosure
+type xmlMedia struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Medium string `xml:"medium,attr"`
+}
+
+// isImage сообщает, что медиа-файл - картинка. Если ни тип,
+// ни вид файла не указаны, то судим по расширению
+func (m xmlMedia) isImage() bool {
+	if m.Medium != "" || m.Type != "" {
+		return m.Medium == "image" || strings.HasPrefix(m.Type, "image/")
+	}
+	ext := strings.ToLower(path.Ext(strings.SplitN(m.URL, "?", 2)[0]))
+	switch ext {
+	case ".jpg", ".jpeg", ".png", ".gif", ".webp", ".avif":
+		return true
+	}
+	return false
+}
+
+// image возвращает адрес картинки для превью: из media:content
+// и media:thumbnail, затем из вложений, затем первую картинку
+// описания. Пустая строка, если картинки нет
+func (xi *xmlItem) image(base *url.URL) string {
+	var candidates []string
+
+	for _, media := range [][]xmlMedia{xi.Media, xi.Group.Media} {
+		for _, m := range media {
+			if m.isImage() {
+				candidates = append(candidates, m.URL)
+			}
+		}
+	}
+	for _, thumbs := range [][]xmlMedia{xi.Thumbnails, xi.Group.Thumbnails} {
+		for _, m := range thumbs {
+			candidates = append(candidates, m.URL)
+		}
+	}
+	for _, m := range xi.Enclosures {
+		if strings.HasPrefix(m.Type, "image/") {
+			candidates = append(candidates, m.URL)
+		}
+	}
+
+	for _, c := range candidates {
+		if u, ok := sanitize.ImageURL(c, base); ok {
+			return u
+		}
+	}
+
+	return sanitize.FirstImage(xi.Description, base)
 }
 
 func (xi *xmlItem) toItem() Item {
+	author := xi.Author
+	if author == "" {
+		author = xi.Creator
+	}
+
+	// если ссылка не разбирается, base == nil и
+	// относительные ссылки из описания удаляются
+	base, _ := url.Parse(strings.TrimSpace(xi.Link))
+
+	pubDate := xi.PubDate
+	if pubDate == 0 {
+		pubDate = xi.Date
+	}
+
 	return Item{
 		Id:          0,
 		Oid:         primitive.NilObjectID,
 		Title:       xi.Title,
-		PubDate:     int64(xi.PubDate),
-		Description: strip.StripTags(xi.Description),
+		PubDate:     int64(pubDate),
+		Description: sanitize.Text(xi.Description),
+		HTML:        sanitize.HTML(xi.Description, base),
 		Link:        xi.Link,
+		Image:       xi.image(base),
+		Lang:        langdetect.Normalize(xi.Lang),
+		Author:      strings.TrimSpace(author),
+		Categories:  xi.Categories,
 	}
 }
 
@@ -71,31 +285,21 @@ func (i *Item) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	return nil
 }
 
-// для конвертирования из RFC1123Z, RFC1123...
-// 'Mon, 02 Jan 2006 15:04:05 -0700' и подобных
-// в unix timestamp
+// unix - дата публикации в unix timestamp. Дата разбирается
+// пакетом pubdate, если её разобрать не удалось или
+// её нет, остаётся 0 - время публикации неизвестно,
+// а лента декодируется дальше
 type unix int64
 
-var layouts = []string{time.RFC1123Z, time.RFC1123,
-	time.UnixDate, "02 Jan 2006 15:04:05 -0700", "Mon, 2 Jan 2006 15:04:05 -0700",
-	time.ANSIC, time.RFC850, time.RFC822, time.RFC822Z}
-
 func (t *unix) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	var s string
 	if err := d.DecodeElement(&s, &start); err != nil {
 		return err
 	}
 
-	var pt time.Time
-	var err error
-
-	for i := range layouts {
-		pt, err = time.Parse(layouts[i], s)
-		if err == nil {
-			break
-		}
+	if pt, err := pubdate.Parse(s); err == nil {
+		*t = unix(pt.Unix())
 	}
-	*t = unix(pt.Unix())
 
-	return err
+	return nil
 }
@@ -2,32 +2,39 @@ package storage
 
 import (
 	"encoding/xml"
+	"reflect"
 	"strings"
 	"testing"
 )
 
 func TestItem_UnmarshalXML(t *testing.T) {
 	blob := `
-		<root>
+		<root xmlns:dc="http://purl.org/dc/elements/1.1/">
 			<nested>
-				<item>
+				<item xml:lang="ru-RU">
 					<title>Тестовый заголовок</title>
 					<link>https://test.com</link>
-					<description>Тестовое описание</description>
+					<description><![CDATA[<p>Тестовое&nbsp;описание</p><a href="/post/1">далее</a><script>alert(1)</script>]]></description>
 					<pubDate>Thu, 16 Jun 2022 10:14:28 +0300</pubDate>
+					<category>Go</category>
+					<category>Программирование</category>
+					<dc:creator>Тестовый автор</dc:creator>
 				</item>
-				<item>
+				<item xml:lang="ru-RU">
 					<title>Тестовый заголовок</title>
 					<link>https://test.com</link>
-					<description>Тестовое описание</description>
+					<description><![CDATA[<p>Тестовое&nbsp;описание</p><a href="/post/1">далее</a><script>alert(1)</script>]]></description>
 					<pubDate>Thu, 16 Jun 2022 10:14:28 +0300</pubDate>
+					<category>Go</category>
+					<category>Программирование</category>
+					<dc:creator>Тестовый автор</dc:creator>
 				</item>
 			</nested>
 		</root>
 		`
 
 	r := struct {
-		Items []Item `xml:">item"`
+		Items []Item `xml:"nested>item"`
 	}{}
 
 	err := xml.NewDecoder(strings.NewReader(blob)).Decode(&r)
@@ -39,14 +46,143 @@ func TestItem_UnmarshalXML(t *testing.T) {
 		Id:          0,
 		Title:       "Тестовый заголовок",
 		PubDate:     1655363668,
-		Description: "Тестовое описание",
-		Link:        "https://test.com",
+		Description: "Тестовое описание\nдалее",
+		HTML: "<p>Тестовое\u00a0описание</p>" +
+			`<a href="https://test.com/post/1" rel="nofollow noopener noreferrer" target="_blank">далее</a>`,
+		Link:       "https://test.com",
+		Lang:       "ru",
+		Author:     "Тестовый автор",
+		Categories: []string{"Go", "Программирование"},
+	}
+
+	if len(r.Items) != 2 {
+		t.Fatalf("Item.UnmarshalXML() got items = %d, want = %d", len(r.Items), 2)
 	}
 
 	for _, got := range r.Items {
-		if got != want {
+		if !reflect.DeepEqual(got, want) {
 			t.Fatalf("Item.UnmarshalXML() got = %v, want %v", got, want)
 		}
 	}
 
 }
+
+func TestItem_UnmarshalXML_Image(t *testing.T) {
+	tests := []struct {
+		name string
+		item string
+		want string
+	}{
+		{
+			name: "media_content",
+			item: `<media:content url="https://test.com/video.mp4" medium="video"/>
+				<media:content url="https://test.com/1.jpg" medium="image"/>
+				<enclosure url="https://test.com/2.jpg" type="image/jpeg"/>`,
+			want: "https://test.com/1.jpg",
+		},
+		{
+			name: "media_group_thumbnail",
+			item: `<media:group><media:thumbnail url="/thumb.png"/></media:group>`,
+			want: "https://test.com/thumb.png",
+		},
+		{
+			name: "enclosure",
+			item: `<enclosure url="https://test.com/podcast.mp3" type="audio/mpeg"/>
+				<enclosure url="https://test.com/2.jpg" type="image/jpeg"/>`,
+			want: "https://test.com/2.jpg",
+		},
+		{
+			name: "картинка_из_описания",
+			item: `<description><![CDATA[<p>Текст</p><img src="/img/3.png">]]></description>`,
+			want: "https://test.com/img/3.png",
+		},
+		{
+			name: "картинки_нет",
+			item: `<description>Текст</description>`,
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blob := `<item xmlns:media="http://search.yahoo.com/mrss/">
+				<link>https://test.com/post/1</link>` + tt.item + `</item>`
+
+			var got Item
+			if err := xml.Unmarshal([]byte(blob), &got); err != nil {
+				t.Fatalf("Item.UnmarshalXML() error = %v", err)
+			}
+
+			if got.Image != tt.want {
+				t.Errorf("Item.UnmarshalXML() got image = %q, want = %q", got.Image, tt.want)
+			}
+		})
+	}
+}
+
+func TestItem_UnmarshalXML_PubDate(t *testing.T) {
+	tests := []struct {
+		name string
+		item string
+		want int64
+	}{
+		{"pubDate", `<pubDate>Thu, 16 Jun 2022 10:14:28 MSK</pubDate>`, 1655363668},
+		{"dc_date", `<dc:date>2022-06-16T10:14:28+03:00</dc:date>`, 1655363668},
+		{"pubDate_важнее_dc_date", `<pubDate>16 июня 2022 10:14:28 +0300</pubDate>
+			<dc:date>2022-06-17T10:14:28+03:00</dc:date>`, 1655363668},
+		{"дата_не_разбирается", `<pubDate>вчера</pubDate>`, 0},
+		{"даты_нет", ``, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blob := `<item xmlns:dc="http://purl.org/dc/elements/1.1/">
+				<link>https://test.com/post/1</link>` + tt.item + `</item>`
+
+			var got Item
+			if err := xml.Unmarshal([]byte(blob), &got); err != nil {
+				t.Fatalf("Item.UnmarshalXML() error = %v", err)
+			}
+
+			if got.PubDate != tt.want {
+				t.Errorf("Item.UnmarshalXML() got pubDate = %d, want = %d", got.PubDate, tt.want)
+			}
+		})
+	}
+}
+
+func TestItem_DetectLang(t *testing.T) {
+	tests := []struct {
+		name string
+		item Item
+		lang string
+		want string
+	}{
+		{
+			name: "xml_lang",
+			item: Item{Title: "Why you should avoid global state", Lang: "de"},
+			lang: "ru",
+			want: "de",
+		},
+		{
+			name: "язык_ленты",
+			item: Item{Title: "Why you should avoid global state"},
+			lang: "en-US",
+			want: "en",
+		},
+		{
+			name: "определение_по_тексту",
+			item: Item{Title: "Почему стоит избегать глобального состояния", Description: "И что делать вместо этого"},
+			want: "ru",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.item.DetectLang(tt.lang)
+			if tt.item.Lang != tt.want {
+				t.Errorf("Item.DetectLang() got = %q, want = %q", tt.item.Lang, tt.want)
+			}
+		})
+	}
+}
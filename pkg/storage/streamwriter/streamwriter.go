@@ -2,8 +2,12 @@ package streamwriter
 
 import (
 	"context"
+	"errors"
 	"log"
 	"news/pkg/storage"
+	"news/pkg/storage/deadletter"
+	"news/pkg/storage/outbox"
+	"sync"
 	"time"
 )
 
@@ -14,10 +18,43 @@ type item = storage.Item
 // stor - хранилище, в которое пишет streamwriter
 type stor = storage.Storage
 
+// задержки между попытками повторной записи из outbox
+const (
+	retryMin = time.Second
+	retryMax = time.Minute
+)
+
+// настройки пакетной записи по-умолчанию
+const (
+	batchSize    = 500
+	batchLatency = time.Second
+	flushers     = 2
+)
+
 // StreamWriter пишет в БД, то что читает из канала
 type StreamWriter struct {
 	log     *log.Logger
 	storage storage.Storage
+	// очередь на диске для контейнеров, которые
+	// не удалось записать в БД, по-умолчанию nil
+	outbox *outbox.Outbox
+	// хранилище новостей, которые не удаётся записать
+	// при доступной БД, по-умолчанию nil
+	dead *deadletter.Store
+	// получатель новостей, которые только что
+	// записаны в БД, по-умолчанию nil
+	pub publisher
+	// минимальная и максимальная задержка
+	// между попытками записи из outbox
+	retryMin, retryMax time.Duration
+	// размер пачки новостей, максимальное время ожидания
+	// до записи пачки и количество одновременных записей в БД
+	batchSize    int
+	batchLatency time.Duration
+	flushers     int
+	// запускает таймер ожидания пачки, возвращает его
+	// канал и функцию остановки. Подменяется в тестах
+	timer func(d time.Duration) (<-chan time.Time, func() bool)
 	// когда установлен в true, логгирует промежуточные итоги,
 	// по-умолчанию false
 	debugMode bool
@@ -26,66 +63,512 @@ type StreamWriter struct {
 // NewStreamWriter возвращает новый объект *StreamWriter
 func NewStreamWriter(log *log.Logger, storage stor) *StreamWriter {
 	return &StreamWriter{
-		log:       log,
-		storage:   storage,
-		debugMode: false,
+		log:          log,
+		storage:      storage,
+		retryMin:     retryMin,
+		retryMax:     retryMax,
+		batchSize:    batchSize,
+		batchLatency: batchLatency,
+		flushers:     flushers,
+		timer:        newTimer,
+		debugMode:    false,
 	}
 }
 
+// newTimer запускает таймер на d
+func newTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	t := time.NewTimer(d)
+	return t.C, t.Stop
+}
+
 // DebugMode переключает debug режим у *StreamWriter
 func (sw *StreamWriter) DebugMode(on bool) *StreamWriter {
 	sw.debugMode = on
 	return sw
 }
 
+// Outbox подключает к *StreamWriter очередь на диске. Контейнеры,
+// которые не удалось записать в БД, сохраняются в очередь и
+// записываются повторно, когда БД снова станет доступна.
+// С очередью ошибки БД не останавливают запись из канала
+func (sw *StreamWriter) Outbox(ob *outbox.Outbox) *StreamWriter {
+	sw.outbox = ob
+	return sw
+}
+
+// DeadLetters подключает к *StreamWriter хранилище dead-letter.
+// Если пачку не удалось записать, а БД доступна, новости пачки
+// записываются по одной, а те, что записать не удалось, сохраняются
+// в хранилище, чтобы их можно было изучить и записать повторно.
+// Без хранилища такие новости отбрасываются
+func (sw *StreamWriter) DeadLetters(dl *deadletter.Store) *StreamWriter {
+	sw.dead = dl
+	return sw
+}
+
+// publisher - получатель новостей, записанных в БД,
+// например *broker.Broker
+type publisher interface {
+	Publish(items ...item)
+}
+
+// Publisher подключает к *StreamWriter получателя новостей.
+// Новости, которых не было в БД, передаются получателю
+// сразу после записи, уже с присвоенными БД идентификаторами
+func (sw *StreamWriter) Publisher(p publisher) *StreamWriter {
+	sw.pub = p
+	return sw
+}
+
+// Batching настраивает пакетную запись. Новости из разных контейнеров
+// собираются в пачку, которая записывается в БД, когда наберёт size
+// новостей или когда с прихода её первой новости пройдёт latency.
+// flushers - сколько пачек может записываться в БД одновременно.
+// Неположительные значения не меняют текущих настроек
+func (sw *StreamWriter) Batching(size int, latency time.Duration, flushers int) *StreamWriter {
+	if size > 0 {
+		sw.batchSize = size
+	}
+	if latency > 0 {
+		sw.batchLatency = latency
+	}
+	if flushers > 0 {
+		sw.flushers = flushers
+	}
+	return sw
+}
+
 // Stats - статистика работы *StreamWriter
 type Stats struct {
 	Containers uint // обработанные контейнеры
 	Items      uint // обработанные новости
+	New        uint // новые новости, которых не было в БД
+	Updated    uint // новости, обновлённые в БД
+	Duplicates uint // новости, которые уже были в БД
+	Buffered   uint // новости, отложенные в outbox
+	Replayed   uint // новости, записанные в БД из outbox
+	Dropped    uint // новости, которые не поместились в outbox или были отброшены
+	Dead       uint // новости, сохранённые в хранилище dead-letter
 	Errs       uint // полученные ошибки
 }
 
+// add добавляет к статистике итоги записи из outbox
+func (s *Stats) add(r Stats) {
+	s.New += r.New
+	s.Updated += r.Updated
+	s.Duplicates += r.Duplicates
+	s.Replayed += r.Replayed
+	s.Dropped += r.Dropped
+	s.Dead += r.Dead
+	s.Errs += r.Errs
+}
+
+// counter - статистика, которую одновременно
+// обновляют несколько записывающих горутин
+type counter struct {
+	mu sync.Mutex
+	Stats
+}
+
+// update изменяет статистику под блокировкой
+// и возвращает её копию
+func (c *counter) update(f func(s *Stats)) Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f(&c.Stats)
+	return c.Stats
+}
+
 // WriteToStorage пишет в БД поступающие данные из канала,
 // возвращает статистику своей работы и ошибку, если БД мертва
-// или все приходящие данные не удается записать
+// или все приходящие данные не удается записать.
+// Новости из контейнеров собираются в пачки, см. Batching.
+// Если подключен outbox, то ошибка не возвращается, а пачки
+// откладываются в очередь до восстановления БД
 func (sw *StreamWriter) WriteToStorage(ctx context.Context, in <-chan container) (Stats, error) {
-	var stats Stats
+	var cnt counter
 	var threshold = cap(in)
 
 	statsCh := make(chan Stats)
-	defer close(statsCh)
-	go sw.logDebug(statsCh, cap(in)) // логгирование промежуточных итогов
-
-	for v := range in {
-
-		dbctx, cancel := context.WithTimeout(ctx, 5*time.Second)
-		defer cancel()
-
-		err := sw.storage.AddItems(dbctx, v.Items)
-		if err != nil {
-			sw.log.Printf("[ERROR] db_error=%v", err) // логгируем ошибку
-			stats.Errs++
-			statsCh <- stats
-			// если беда со всей пачкой пришедших значений, то
-			// смысла продолжать нет
-			if stats.Errs >= uint(threshold) {
-				return stats, err
+	logged := make(chan struct{})
+	go func() {
+		sw.logDebug(statsCh, cap(in)) // логгирование промежуточных итогов
+		close(logged)
+	}()
+
+	// повторная запись из outbox работает,
+	// пока читается входной канал
+	replayed := make(chan Stats, 1)
+	rctx, rcancel := context.WithCancel(ctx)
+	defer rcancel()
+
+	if sw.outbox != nil {
+		go func() {
+			replayed <- sw.replay(rctx)
+		}()
+	} else {
+		replayed <- Stats{}
+	}
+
+	// при фатальной ошибке БД чтение канала прекращается
+	stop := make(chan struct{})
+	var fatal error
+	var once sync.Once
+
+	batches := make(chan []item)
+
+	var wg sync.WaitGroup
+	wg.Add(sw.flushers)
+
+	for i := 0; i < sw.flushers; i++ {
+		go func() {
+			defer wg.Done()
+
+			for b := range batches {
+				stats, err := sw.flush(ctx, b, &cnt)
+				statsCh <- stats
+
+				// если беда со всей пачкой пришедших значений, то
+				// смысла продолжать нет
+				if err != nil && sw.outbox == nil && stats.Errs >= uint(threshold) {
+					once.Do(func() {
+						fatal = err
+						close(stop)
+					})
+				}
 			}
-		}
+		}()
+	}
+
+	sw.collect(in, batches, stop, &cnt)
+	close(batches)
+	wg.Wait()
+	close(statsCh)
+	<-logged
 
-		stats.Containers++
-		stats.Items += uint(len(v.Items))
+	rcancel()
+	stats := cnt.update(func(s *Stats) { s.add(<-replayed) })
+
+	if fatal != nil {
+		return stats, fatal
+	}
 
-		statsCh <- stats
+	var queued, dropped int
+	if sw.outbox != nil {
+		queued, dropped = sw.outbox.Len(), int(sw.outbox.Dropped())
 	}
 
 	// лог общий итог
-	sw.log.Printf("[INFO] totals: received_containers=%d received_items=%d db_errors=%d",
-		stats.Containers, stats.Items, stats.Errs)
+	sw.log.Printf("[INFO] totals: received_containers=%d received_items=%d new_items=%d updated_items=%d duplicates=%d db_errors=%d",
+		stats.Containers, stats.Items, stats.New, stats.Updated, stats.Duplicates, stats.Errs)
+	sw.log.Printf("[INFO] outbox totals: buffered_items=%d replayed_items=%d dropped_items=%d dead_letters=%d queued_containers=%d overflowed_containers=%d",
+		stats.Buffered, stats.Replayed, stats.Dropped, stats.Dead, queued, dropped)
 
 	return stats, nil
 }
 
+// collect читает контейнеры из канала in и собирает их новости в пачки,
+// которые отправляет в канал out. Пачка отправляется, когда наберёт
+// batchSize новостей или когда пройдёт batchLatency с прихода её
+// первой новости. Возвращается, когда канал in закрыт или закрыт stop
+func (sw *StreamWriter) collect(in <-chan container, out chan<- []item, stop <-chan struct{}, cnt *counter) {
+	var batch []item
+	var timeout <-chan time.Time
+	var stopTimer func() bool
+
+	send := func(b []item) bool {
+		select {
+		case out <- b:
+			return true
+		case <-stop:
+			return false
+		}
+	}
+
+	// flush отправляет всё собранное, даже неполную пачку
+	flush := func() bool {
+		if stopTimer != nil {
+			stopTimer()
+			stopTimer, timeout = nil, nil
+		}
+		if len(batch) == 0 {
+			return true
+		}
+		b := batch
+		batch = nil
+		return send(b)
+	}
+
+	for {
+		select {
+		case v, ok := <-in:
+			if !ok {
+				flush()
+				return
+			}
+
+			cnt.update(func(s *Stats) {
+				s.Containers++
+				s.Items += uint(len(v.Items))
+			})
+
+			if len(v.Items) == 0 {
+				continue
+			}
+
+			if len(batch) == 0 {
+				timeout, stopTimer = sw.timer(sw.batchLatency)
+			}
+			batch = append(batch, v.Items...)
+
+			// отправляем полные пачки, остаток ждёт следующих контейнеров
+			for len(batch) >= sw.batchSize {
+				b := batch[:sw.batchSize:sw.batchSize]
+				batch = append([]item(nil), batch[sw.batchSize:]...)
+				if !send(b) {
+					return
+				}
+			}
+			if len(batch) == 0 && stopTimer != nil {
+				stopTimer()
+				stopTimer, timeout = nil, nil
+			}
+
+		case <-timeout:
+			stopTimer, timeout = nil, nil
+			if !flush() {
+				return
+			}
+
+		case <-stop:
+			return
+		}
+	}
+}
+
+// flush пишет пачку новостей в БД и обновляет статистику.
+// Если БД недоступна и подключен outbox, незаписанные
+// новости откладываются в очередь
+func (sw *StreamWriter) flush(ctx context.Context, b []item, cnt *counter) (Stats, error) {
+	var res Stats
+
+	rest, err := sw.persist(ctx, b, &res)
+	if err != nil && sw.outbox != nil {
+		sw.buffer(container{Items: rest}, &res)
+	}
+
+	stats := cnt.update(func(s *Stats) {
+		s.New += res.New
+		s.Updated += res.Updated
+		s.Duplicates += res.Duplicates
+		s.Buffered += res.Buffered
+		s.Dropped += res.Dropped
+		s.Dead += res.Dead
+		s.Errs += res.Errs
+	})
+
+	return stats, err
+}
+
+// persist пишет новости в БД. Если пачку записать не удалось, но БД
+// доступна, новости записываются по одной, чтобы найти те, из-за
+// которых не записывается вся пачка. Такие новости отправляются
+// в хранилище dead-letter. Если БД недоступна, возвращает
+// незаписанные новости и ошибку
+func (sw *StreamWriter) persist(ctx context.Context, items []item, stats *Stats) ([]item, error) {
+
+	written, err := sw.write(ctx, items, stats)
+	if err == nil {
+		return nil, nil
+	}
+
+	sw.log.Printf("[ERROR] db_error=%v batch_items=%d", err, len(items)) // логгируем ошибку
+	stats.Errs++
+
+	// новости, записанные до ошибки, уже опубликованы,
+	// повторно их записывать не нужно
+	if items = unwritten(items, written); len(items) == 0 {
+		return nil, nil
+	}
+
+	pinger, canPing := sw.storage.(storage.Pinger)
+	if canPing {
+		pctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		perr := pinger.Ping(pctx)
+		cancel()
+		if perr != nil {
+			return items, err // БД недоступна, искать нечего
+		}
+	}
+
+	var failed []item
+	var causes []error
+
+	for i := range items {
+		if _, werr := sw.write(ctx, items[i:i+1], stats); werr != nil {
+			failed = append(failed, items[i])
+			causes = append(causes, werr)
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil, nil
+	}
+
+	// запись прервана - новости не виноваты
+	if ctx.Err() != nil {
+		return failed, ctx.Err()
+	}
+
+	// если не записалась ни одна новость, а проверить соединение
+	// нельзя, то считаем, что недоступна БД
+	if len(failed) == len(items) && !canPing {
+		return failed, err
+	}
+
+	for i := range failed {
+		sw.bury(failed[i], causes[i], stats)
+	}
+
+	return nil, nil
+}
+
+// bury отправляет новость в хранилище dead-letter
+func (sw *StreamWriter) bury(it item, cause error, stats *Stats) {
+	if sw.dead == nil {
+		stats.Dropped++
+		sw.log.Printf("[ERROR] dropped item: link=%s db_error=%v", it.Link, cause)
+		return
+	}
+
+	l, err := sw.dead.Put(it, cause)
+	if err != nil {
+		sw.log.Printf("[ERROR] dead letter error=%v", err)
+	}
+	stats.Dead++
+	sw.log.Printf("[ERROR] dead letter #%d: link=%s db_error=%v", l.ID, it.Link, cause)
+}
+
+// write пишет новости в БД, обновляет статистику и публикует
+// новые новости. Возвращает записанные новости: если БД вернула
+// ошибку, это новости, которые она успела записать до ошибки
+func (sw *StreamWriter) write(ctx context.Context, items []item, stats *Stats) ([]item, error) {
+	dbctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	res, err := sw.storage.AddItems(dbctx, items)
+
+	stats.New += uint(len(res.Inserted))
+	if sw.pub != nil && len(res.Inserted) > 0 {
+		sw.pub.Publish(res.Inserted...)
+	}
+	if err != nil {
+		return res.Inserted, err
+	}
+
+	stats.Updated += uint(res.Updated)
+	stats.Duplicates += uint(res.Skipped)
+
+	return res.Inserted, nil
+}
+
+// unwritten возвращает новости items, которых нет среди written
+func unwritten(items, written []item) []item {
+	if len(written) == 0 {
+		return items
+	}
+
+	links := make(map[string]bool, len(written))
+	for _, it := range written {
+		links[it.Link] = true
+	}
+
+	rest := make([]item, 0, len(items)-len(written))
+	for _, it := range items {
+		if !links[it.Link] {
+			rest = append(rest, it)
+		}
+	}
+	return rest
+}
+
+// buffer откладывает контейнер в outbox
+func (sw *StreamWriter) buffer(v container, stats *Stats) {
+	if err := sw.outbox.Push(v); err != nil {
+		stats.Dropped += uint(len(v.Items))
+		sw.log.Printf("[ERROR] outbox_error=%v dropped_items=%d", err, len(v.Items))
+		return
+	}
+	stats.Buffered += uint(len(v.Items))
+}
+
+// replay записывает в БД контейнеры из outbox, пока не отменён
+// контекст. Если БД недоступна, повторяет попытки
+// с нарастающей задержкой
+func (sw *StreamWriter) replay(ctx context.Context) Stats {
+	var stats Stats
+	backoff := sw.retryMin
+
+	for {
+		select {
+		case <-ctx.Done():
+			return stats
+		case <-time.After(backoff):
+		}
+
+		for ctx.Err() == nil {
+			e, err := sw.outbox.Peek()
+			if errors.Is(err, outbox.ErrEmpty) {
+				backoff = sw.retryMin
+				break
+			}
+			if err != nil {
+				// повреждённую запись повторять бессмысленно
+				sw.log.Printf("[ERROR] outbox_error=%v", err)
+				stats.Errs++
+				if !sw.remove(e.Seq, &backoff, &stats) {
+					break
+				}
+				continue
+			}
+
+			if _, err := sw.persist(ctx, e.Container.Items, &stats); err != nil {
+				backoff = sw.slower(backoff)
+				sw.log.Printf("[ERROR] outbox replay: db_error=%v queued_containers=%d next_retry=%s",
+					err, sw.outbox.Len(), backoff)
+				break
+			}
+
+			stats.Replayed += uint(len(e.Container.Items))
+			if !sw.remove(e.Seq, &backoff, &stats) {
+				break
+			}
+		}
+	}
+}
+
+// remove удаляет запись seq из outbox. Если запись не удалилась,
+// увеличивает задержку *backoff и сообщает об этом: иначе
+// replay без остановки читал бы ту же запись
+func (sw *StreamWriter) remove(seq uint64, backoff *time.Duration, stats *Stats) bool {
+	err := sw.outbox.Remove(seq)
+	if err == nil {
+		return true
+	}
+	stats.Errs++
+	*backoff = sw.slower(*backoff)
+	sw.log.Printf("[ERROR] outbox remove: entry=%d outbox_error=%v next_retry=%s", seq, err, *backoff)
+	return false
+}
+
+// slower возвращает следующую задержку между попытками
+func (sw *StreamWriter) slower(backoff time.Duration) time.Duration {
+	if backoff *= 2; backoff > sw.retryMax {
+		backoff = sw.retryMax
+	}
+	return backoff
+}
+
 func (sw *StreamWriter) logDebug(in <-chan Stats, cycle int) {
 
 	logcycle := cycle
@@ -94,8 +577,8 @@ func (sw *StreamWriter) logDebug(in <-chan Stats, cycle int) {
 		for s := range in {
 
 			if logcycle <= 0 {
-				sw.log.Printf("[DEBUG] running totals: received_containers=%d received_items=%d db_errors=%d",
-					s.Containers, s.Items, s.Errs)
+				sw.log.Printf("[DEBUG] running totals: received_containers=%d received_items=%d new_items=%d updated_items=%d duplicates=%d db_errors=%d",
+					s.Containers, s.Items, s.New, s.Updated, s.Duplicates, s.Errs)
 			} else {
 				logcycle--
 			}
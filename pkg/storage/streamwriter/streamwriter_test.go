@@ -2,9 +2,17 @@ package streamwriter
 
 import (
 	"context"
+	"errors"
 	"io"
 	"log"
+	"news/pkg/storage"
+	"news/pkg/storage/deadletter"
 	"news/pkg/storage/memdb"
+	"news/pkg/storage/outbox"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
 	"testing"
 	"time"
 )
@@ -64,4 +72,425 @@ func TestStreamWriter_WriteToStorage(t *testing.T) {
 		t.Errorf("StreamWriter.WriteToStorage() got items = %d, want = %d",
 			stats.Items, want*2)
 	}
+
+	// memdb считает все новости новыми
+	if stats.New != want*2 {
+		t.Errorf("StreamWriter.WriteToStorage() got new items = %d, want = %d",
+			stats.New, want*2)
+	}
+}
+
+// flakyDB - хранилище, которое не отвечает
+// первые fails вызовов AddItems
+type flakyDB struct {
+	*memdb.MemDB
+	mu    sync.Mutex
+	fails int
+	added int
+	calls int
+	// если задан, получает число записанных новостей
+	// после каждой удачной записи
+	written chan int
+}
+
+func (db *flakyDB) AddItems(ctx context.Context, items []item) (storage.AddResult, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.calls++
+	if db.fails > 0 {
+		db.fails--
+		return storage.AddResult{}, errors.New("connection refused")
+	}
+	db.added += len(items)
+	if db.written != nil {
+		db.written <- db.added
+	}
+	return db.MemDB.AddItems(ctx, items)
+}
+
+func TestStreamWriter_Outbox(t *testing.T) {
+
+	ob, err := outbox.Open(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatalf("outbox.Open() error = %v", err)
+	}
+
+	// каждая пачка пишется дважды: целиком и по одной новости,
+	// первая попытка повторной записи из outbox тоже неудачна
+	db := &flakyDB{MemDB: memdb.New(), fails: 8}
+	sw := NewStreamWriter(log.New(io.Discard, "", 0), db).Outbox(ob).Batching(1, time.Millisecond, 1)
+	sw.retryMin, sw.retryMax = time.Millisecond, 5*time.Millisecond
+
+	cont := container{Items: []item{{Title: "sample item", PubDate: 5555555, Link: "https://test.com"}}}
+
+	ch := make(chan container)
+	go func() {
+		defer close(ch)
+		for i := 0; i < 3; i++ {
+			ch <- cont
+		}
+		// ждём, пока отложенное из outbox запишется
+		waitFor(t, func() bool {
+			db.mu.Lock()
+			defer db.mu.Unlock()
+			return db.added == 3 && ob.Len() == 0
+		})
+	}()
+
+	stats, err := sw.WriteToStorage(context.Background(), ch)
+	if err != nil {
+		t.Fatalf("StreamWriter.WriteToStorage() error = %v", err)
+	}
+
+	if stats.Buffered != 3 {
+		t.Errorf("StreamWriter.WriteToStorage() got buffered = %d, want = %d", stats.Buffered, 3)
+	}
+
+	if stats.Replayed != 3 || stats.New != 3 {
+		t.Errorf("StreamWriter.WriteToStorage() got replayed = %d, new = %d, want = %d, %d",
+			stats.Replayed, stats.New, 3, 3)
+	}
+
+	if db.added != 3 || ob.Len() != 0 {
+		t.Errorf("StreamWriter.WriteToStorage() got added = %d, queued = %d, want = %d, %d",
+			db.added, ob.Len(), 3, 0)
+	}
+}
+
+// waitFor ждёт, пока cond не станет истинным
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Errorf("condition is not met in time")
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestStreamWriter_replay(t *testing.T) {
+
+	dir := t.TempDir()
+	ob, err := outbox.Open(dir, 0, 0)
+	if err != nil {
+		t.Fatalf("outbox.Open() error = %v", err)
+	}
+	if err := ob.Push(container{Items: []item{memdb.SampleItem}}); err != nil {
+		t.Fatalf("Outbox.Push() error = %v", err)
+	}
+
+	// вместо файла записи - непустой каталог: запись
+	// не читается и не удаляется
+	files, err := os.ReadDir(dir)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("os.ReadDir() got = %v, error = %v", files, err)
+	}
+	path := filepath.Join(dir, files[0].Name())
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("os.Remove() error = %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(path, "entry"), 0o755); err != nil {
+		t.Fatalf("os.MkdirAll() error = %v", err)
+	}
+
+	sw := NewStreamWriter(log.New(io.Discard, "", 0), memdb.New()).Outbox(ob)
+	sw.retryMin, sw.retryMax = time.Millisecond, 10*time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// без задержки после ошибки удаления попыток были бы миллионы
+	stats := sw.replay(ctx)
+	if stats.Errs == 0 || stats.Errs > 40 {
+		t.Errorf("StreamWriter.replay() got errors = %d, want between 1 and 40", stats.Errs)
+	}
+	if ob.Len() != 1 {
+		t.Errorf("StreamWriter.replay() got queued = %d, want = %d", ob.Len(), 1)
+	}
+}
+
+func TestStreamWriter_Batching(t *testing.T) {
+
+	cont := container{Items: []item{
+		{Title: "sample item", PubDate: 5555555, Link: "https://test.com/1"},
+		{Title: "sample item", PubDate: 5555555, Link: "https://test.com/2"},
+	}}
+
+	t.Run("пачки_по_размеру", func(t *testing.T) {
+		db := &flakyDB{MemDB: memdb.New()}
+		sw := NewStreamWriter(log.New(io.Discard, "", 0), db).Batching(50, time.Hour, 3)
+
+		ch := make(chan container)
+		go func() {
+			defer close(ch)
+			for i := 0; i < 100; i++ {
+				ch <- cont
+			}
+		}()
+
+		stats, err := sw.WriteToStorage(context.Background(), ch)
+		if err != nil {
+			t.Fatalf("StreamWriter.WriteToStorage() error = %v", err)
+		}
+
+		// 200 новостей - 4 пачки по 50
+		if db.calls != 4 || db.added != 200 {
+			t.Errorf("StreamWriter.WriteToStorage() got db calls = %d, added = %d, want = %d, %d",
+				db.calls, db.added, 4, 200)
+		}
+
+		if stats.Containers != 100 || stats.New != 200 {
+			t.Errorf("StreamWriter.WriteToStorage() got containers = %d, new = %d, want = %d, %d",
+				stats.Containers, stats.New, 100, 200)
+		}
+	})
+
+	t.Run("пачка_по_времени", func(t *testing.T) {
+		db := &flakyDB{MemDB: memdb.New(), written: make(chan int, 1)}
+		sw := NewStreamWriter(log.New(io.Discard, "", 0), db).Batching(1000, time.Hour, 1)
+
+		// таймер пачки срабатывает по команде теста
+		fire := make(chan time.Time)
+		sw.timer = func(time.Duration) (<-chan time.Time, func() bool) {
+			return fire, func() bool { return true }
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		ch := make(chan container)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_, _ = sw.WriteToStorage(ctx, ch)
+		}()
+		t.Cleanup(func() {
+			cancel()
+			close(ch)
+			<-done
+		})
+
+		ch <- cont
+
+		// пачка не полная и без таймера не пишется
+		db.mu.Lock()
+		calls := db.calls
+		db.mu.Unlock()
+		if calls != 0 {
+			t.Fatalf("StreamWriter.WriteToStorage() got db calls = %d before timer, want = %d", calls, 0)
+		}
+
+		fire <- time.Now()
+
+		select {
+		case got := <-db.written:
+			if got != len(cont.Items) {
+				t.Errorf("StreamWriter.WriteToStorage() got added = %d, want = %d", got, len(cont.Items))
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("StreamWriter.WriteToStorage() batch is not written by timer")
+		}
+	})
+
+	t.Run("остановка_при_ошибках_бд", func(t *testing.T) {
+		db := &flakyDB{MemDB: memdb.New(), fails: 1000}
+		sw := NewStreamWriter(log.New(io.Discard, "", 0), db).Batching(2, time.Hour, 2)
+
+		ch := make(chan container, 2)
+		go func() {
+			defer close(ch)
+			for i := 0; i < 100; i++ {
+				select {
+				case ch <- cont:
+				case <-time.After(100 * time.Millisecond):
+					return // писатель больше не читает канал
+				}
+			}
+		}()
+
+		stats, err := sw.WriteToStorage(context.Background(), ch)
+		if err == nil {
+			t.Fatalf("StreamWriter.WriteToStorage() expected error, got nothing")
+		}
+
+		if stats.Errs < 2 {
+			t.Errorf("StreamWriter.WriteToStorage() got errors = %d, want at least = %d", stats.Errs, 2)
+		}
+	})
+}
+
+// poisonDB - доступная БД, которая не принимает
+// пачки с новостями без даты публикации
+type poisonDB struct {
+	*memdb.MemDB
+	mu    sync.Mutex
+	added int
+}
+
+func (db *poisonDB) Ping(context.Context) error { return nil }
+
+func (db *poisonDB) AddItems(ctx context.Context, items []item) (storage.AddResult, error) {
+	for i := range items {
+		if items[i].PubDate <= 0 {
+			return storage.AddResult{}, errors.New("violates check constraint")
+		}
+	}
+	db.mu.Lock()
+	db.added += len(items)
+	db.mu.Unlock()
+	return db.MemDB.AddItems(ctx, items)
+}
+
+func TestStreamWriter_DeadLetters(t *testing.T) {
+
+	dl, err := deadletter.Open("")
+	if err != nil {
+		t.Fatalf("deadletter.Open() error = %v", err)
+	}
+
+	db := &poisonDB{MemDB: memdb.New()}
+	sw := NewStreamWriter(log.New(io.Discard, "", 0), db).DeadLetters(dl).Batching(10, time.Hour, 1)
+
+	poison := item{Title: "poison", PubDate: 0, Link: "https://test.com/poison"}
+
+	ch := make(chan container, 1)
+	ch <- container{Items: []item{
+		{Title: "good 1", PubDate: 5555555, Link: "https://test.com/1"},
+		poison,
+		{Title: "good 2", PubDate: 5555555, Link: "https://test.com/2"},
+	}}
+	close(ch)
+
+	stats, err := sw.WriteToStorage(context.Background(), ch)
+	if err != nil {
+		t.Fatalf("StreamWriter.WriteToStorage() error = %v", err)
+	}
+
+	if db.added != 2 || stats.New != 2 || stats.Dead != 1 {
+		t.Errorf("StreamWriter.WriteToStorage() got added = %d, new = %d, dead = %d, want = %d, %d, %d",
+			db.added, stats.New, stats.Dead, 2, 2, 1)
+	}
+
+	letters := dl.List()
+	if len(letters) != 1 || !reflect.DeepEqual(letters[0].Item, poison) {
+		t.Fatalf("StreamWriter.WriteToStorage() got dead letters = %v, want = %v", letters, poison)
+	}
+}
+
+// recorder запоминает опубликованные новости
+type recorder struct {
+	mu    sync.Mutex
+	items []item
+}
+
+func (r *recorder) Publish(items ...item) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.items = append(r.items, items...)
+}
+
+func TestStreamWriter_Publisher(t *testing.T) {
+
+	// первая запись не удаётся, новости ждут в outbox
+	db := &flakyDB{MemDB: memdb.New(), fails: 1}
+	ob, err := outbox.Open(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatalf("outbox.Open() error = %v", err)
+	}
+
+	rec := &recorder{}
+	sw := NewStreamWriter(log.New(io.Discard, "", 0), db).Outbox(ob).Publisher(rec)
+	sw.retryMin = 10 * time.Millisecond
+
+	ch := make(chan container, 1)
+	ch <- container{Items: []item{{Link: "https://test.com/1"}, {Link: "https://test.com/2"}}}
+
+	go func() {
+		defer close(ch)
+		// ждём, пока отложенное из outbox запишется
+		waitFor(t, func() bool {
+			rec.mu.Lock()
+			defer rec.mu.Unlock()
+			return len(rec.items) >= 2
+		})
+	}()
+
+	if _, err := sw.WriteToStorage(context.Background(), ch); err != nil {
+		t.Fatalf("StreamWriter.WriteToStorage() error = %v", err)
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	// новости публикуются один раз, после записи из outbox
+	if len(rec.items) != 2 || rec.items[0].Link != "https://test.com/1" {
+		t.Errorf("StreamWriter.Publisher() got items = %v", rec.items)
+	}
+}
+
+// partialDB - доступная БД, которая, как mongodb при
+// неупорядоченной записи, записывает все новости пачки, кроме
+// новостей без даты публикации, и возвращает записанные с ошибкой
+type partialDB struct {
+	*memdb.MemDB
+	mu    sync.Mutex
+	calls int
+}
+
+func (db *partialDB) Ping(context.Context) error { return nil }
+
+func (db *partialDB) AddItems(ctx context.Context, items []item) (storage.AddResult, error) {
+	db.mu.Lock()
+	db.calls++
+	db.mu.Unlock()
+
+	var good []item
+	for i := range items {
+		if items[i].PubDate > 0 {
+			good = append(good, items[i])
+		}
+	}
+	res, err := db.MemDB.AddItems(ctx, good)
+	if err != nil || len(good) == len(items) {
+		return res, err
+	}
+	return storage.AddResult{Inserted: res.Inserted}, errors.New("bulk write exception")
+}
+
+func TestStreamWriter_partial(t *testing.T) {
+
+	dl, err := deadletter.Open("")
+	if err != nil {
+		t.Fatalf("deadletter.Open() error = %v", err)
+	}
+
+	db := &partialDB{MemDB: memdb.New()}
+	rec := &recorder{}
+	sw := NewStreamWriter(log.New(io.Discard, "", 0), db).DeadLetters(dl).Publisher(rec).Batching(10, time.Hour, 1)
+
+	ch := make(chan container, 1)
+	ch <- container{Items: []item{
+		{Title: "good 1", PubDate: 5555555, Link: "https://test.com/1"},
+		{Title: "poison", PubDate: 0, Link: "https://test.com/poison"},
+		{Title: "good 2", PubDate: 5555555, Link: "https://test.com/2"},
+	}}
+	close(ch)
+
+	stats, err := sw.WriteToStorage(context.Background(), ch)
+	if err != nil {
+		t.Fatalf("StreamWriter.WriteToStorage() error = %v", err)
+	}
+
+	// записанные до ошибки новости публикуются сразу, а по
+	// одной повторно записывается только отравленная новость
+	if stats.New != 2 || stats.Duplicates != 0 || stats.Dead != 1 || db.calls != 2 {
+		t.Errorf("StreamWriter.WriteToStorage() got new = %d, duplicates = %d, dead = %d, calls = %d, want = %d, %d, %d, %d",
+			stats.New, stats.Duplicates, stats.Dead, db.calls, 2, 0, 1, 2)
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.items) != 2 || rec.items[0].Link != "https://test.com/1" || rec.items[1].Link != "https://test.com/2" {
+		t.Errorf("StreamWriter.Publisher() got items = %v", rec.items)
+	}
 }